@@ -25,6 +25,7 @@ import { useConnectionStore } from '@/stores/connectionStore'
 import { useExecutionStore } from '@/stores/executionStore'
 import { useCollectionStore } from '@/stores/collectionStore'
 import type { SavedConnection } from '@/stores/savedConnectionStore'
+import { resolveParserMode } from '@/types/frame'
 
 
 function App() {
@@ -144,10 +145,9 @@ function App() {
       setRouteMappings((r.routes ?? []) as import('@/stores/protoStore').RouteMapping[])
     }).catch(() => {})
 
-    // 判断是否为 Due 协议 (存在 header 字段且 bytes 为 1), 仅 Due 协议启用内置心跳
-    const isDueProtocol = connection.frameConfig?.fields?.some(
-      (f) => f.name.toLowerCase() === 'header' && f.bytes === 1
-    ) ?? false
+    // 仅 Due 协议启用内置心跳
+    const parserMode = resolveParserMode(connection.frameConfig)
+    const isDueProtocol = parserMode === 'due'
 
     // 自动建立连接, 成功时 toast 提示, 失败时 toast 提示但不阻塞进入画布
     connectTCP(connection.host, connection.port, {
@@ -156,7 +156,7 @@ function App() {
       heartbeat: isDueProtocol,
       frameFields: connection.frameConfig?.fields,
       byteOrder: connection.frameConfig?.byteOrder,
-      parserMode: connection.frameConfig?.parserMode,
+      parserMode,
     }).then(() => {
       toast.success('连接成功', {
         description: `已连接到 ${connection.host}:${connection.port}`,
@@ -198,15 +198,15 @@ export function CreateConnectionDialog({
         const fields = cherryParser === 'simple'
           ? [{ name: 'mid', bytes: 4, isRoute: true }, { name: 'len', bytes: 4 }]
           : [{ name: 'type', bytes: 1 }, { name: 'length', bytes: 3 }]
-        return { type: 'template', templateId: tpl.id, fields, byteOrder: 'big', parserMode: cherryParser }
+        return { type: 'template', templateId: tpl.id, fields, byteOrder: 'big', parserMode: cherryParser === 'pomelo' ? 'cherry' : 'simple' }
       }
-      return { type: 'template', templateId: tpl.id, fields: tpl.fields, byteOrder: 'big' }
+      return { type: 'template', templateId: tpl.id, fields: tpl.fields, byteOrder: 'big', parserMode: tpl.parserMode ?? 'field' }
     }
     if (frameType === 'saved') {
       const tpl = savedTemplates.find((t) => t.id === selectedTemplateId)!
-      return { type: 'template', templateId: tpl.id, fields: tpl.fields, byteOrder: tpl.byteOrder ?? 'big' }
+      return { type: 'template', templateId: tpl.id, fields: tpl.fields, byteOrder: tpl.byteOrder ?? 'big', parserMode: 'field' }
     }
-    return { type: 'custom', fields: customFields, byteOrder }
+    return { type: 'custom', fields: customFields, byteOrder, parserMode: 'field' }
   }
 
   const handleSave = () => {
@@ -444,7 +444,7 @@ export function CreateConnectionDialog({
               <div className="flex flex-col divide-y rounded-lg border">
                 {FRAME_TEMPLATES.map((tpl) => {
                   const selected = selectedTemplateId === tpl.id
-                  const available = tpl.id === 'due' || tpl.id === 'cherry'
+                  const available = tpl.parserMode !== undefined || tpl.id === 'cherry'
                   return (
                     <button
                       key={tpl.id}
@@ -2,7 +2,7 @@ import { useCanvasStore, type RequestNodeData } from '@/stores/canvasStore'
 import { useProtoStore, type FieldInfo, type MessageInfo } from '@/stores/protoStore'
 import { useConnectionStore } from '@/stores/connectionStore'
 import { useSavedConnectionStore } from '@/stores/savedConnectionStore'
-import { combineRoute, splitRoute } from '@/types/frame'
+import { combineRoute, splitRoute, isPomeloFamily } from '@/types/frame'
 import { Input } from '@/components/ui/input'
 import { Label } from '@/components/ui/label'
 import { Switch } from '@/components/ui/switch'
@@ -26,7 +26,7 @@ export function FieldEditor({ nodeId }: FieldEditorProps) {
   const getConnection = useSavedConnectionStore((s) => s.getConnection)
 
   const isPomelo = activeConnectionId
-    ? getConnection(activeConnectionId)?.frameConfig?.parserMode === 'pomelo'
+    ? isPomeloFamily(getConnection(activeConnectionId)?.frameConfig?.parserMode)
     : false
 
   if (!node || !('messageName' in node.data)) return null
@@ -197,7 +197,7 @@ function FieldInput({ field, value, onChange, getMessage }: FieldInputProps) {
           {field.name}
         </Label>
         <EnumSelector
-          value={(value as number) ?? 0}
+          value={(value as number | string) ?? 0}
           onChange={onChange}
           enumType={field.type}
         />
@@ -257,8 +257,24 @@ function ScalarInput({
           onChange={(e) => onChange(e.target.value === '' ? 0 : Number(e.target.value))}
         />
       )
+    case 'int64':
+    case 'uint64':
+    case 'sint64':
+    case 'fixed64':
+    case 'sfixed64':
+      // 64 位整数以字符串传递, 避免超过 2^53 时 JS number 丢失精度
+      return (
+        <Input
+          value={value === undefined ? '' : String(value)}
+          onChange={(e) => {
+            const v = e.target.value.trim()
+            if (v === '' || /^-?\d+$/.test(v)) onChange(v === '' ? '0' : v)
+          }}
+          className="font-mono"
+        />
+      )
     default:
-      // int32, int64, uint32, uint64, sint32, sint64, fixed32, fixed64, sfixed32, sfixed64
+      // int32, uint32, sint32, fixed32, sfixed32
       return (
         <Input
           value={(value as number) ?? ''}
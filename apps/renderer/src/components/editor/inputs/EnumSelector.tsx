@@ -8,7 +8,8 @@ import {
 import { useProtoStore } from '@/stores/protoStore'
 
 interface EnumSelectorProps {
-  value: number
+  // 响应和回放流程中的枚举为值名称, 手动选择时为数字
+  value: number | string
   onChange: (value: unknown) => void
   enumType: string
 }
@@ -36,9 +37,13 @@ export function EnumSelector({ value, onChange, enumType }: EnumSelectorProps) {
     if (enumValues.length > 0) break
   }
 
+  const selected = typeof value === 'string'
+    ? enumValues.find((ev) => ev.name === value)?.number ?? value
+    : value
+
   return (
     <Select
-      value={String(value)}
+      value={String(selected)}
       onValueChange={(v) => onChange(parseInt(v))}
     >
       <SelectTrigger className="h-7 text-xs">
@@ -1,8 +1,14 @@
 import { useState, useMemo, useCallback } from 'react'
-import { ChevronRight, Copy, Check } from 'lucide-react'
+import { ChevronRight, Copy, Check, Wand2 } from 'lucide-react'
+import { toast } from 'sonner'
 import { cn } from '@/lib/utils'
 import { ScrollArea } from '@/components/ui/scroll-area'
+import { Popover, PopoverContent, PopoverTrigger } from '@/components/ui/popover'
 import { useExecutionStore, type LogEntry } from '@/stores/executionStore'
+import { useCanvasStore, type RequestNodeData } from '@/stores/canvasStore'
+import { useConnectionStore } from '@/stores/connectionStore'
+import { useProtoStore } from '@/stores/protoStore'
+import { guessResponseMessage, setRouteMapping } from '@/services/api'
 
 const typeColors: Record<string, string> = {
   request: 'var(--pin-int)',
@@ -88,6 +94,9 @@ function LogRow({ log }: { log: LogEntry }) {
             <ChevronRight className={cn('size-3 transition-transform', expanded && 'rotate-90')} />
           </button>
         )}
+        {log.type === 'response' && !log.messageName && typeof log.data._hex === 'string' && (
+          <GuessMessageButton log={log} />
+        )}
         {formatted && (
           <button
             onClick={handleCopy}
@@ -124,6 +133,82 @@ function LogRow({ log }: { log: LogEntry }) {
   )
 }
 
+type MessageCandidate = Awaited<ReturnType<typeof guessResponseMessage>>['candidates'][number]
+
+// GuessMessageButton 未映射响应消息的路由: 用已加载的消息试解码, 选中候选后保存为该路由的响应消息
+function GuessMessageButton({ log }: { log: LogEntry }) {
+  const [open, setOpen] = useState(false)
+  const [loading, setLoading] = useState(false)
+  const [candidates, setCandidates] = useState<MessageCandidate[] | null>(null)
+  const activeConnectionId = useConnectionStore((s) => s.activeConnectionId)
+  const node = useCanvasStore((s) => s.nodes.find((n) => n.id === log.nodeId))
+  const routeMappings = useProtoStore((s) => s.routeMappings)
+  const addMapping = useProtoStore((s) => s.addRouteMapping)
+
+  const handleOpenChange = async (next: boolean) => {
+    setOpen(next)
+    if (!next || candidates || !activeConnectionId) return
+    setLoading(true)
+    try {
+      const res = await guessResponseMessage(activeConnectionId, log.data._hex as string)
+      setCandidates(res.candidates)
+    } catch (err) {
+      toast.error('识别响应消息失败', { description: String(err) })
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  const handleApply = async (message: string) => {
+    if (!activeConnectionId || !node) return
+    const data = node.data as RequestNodeData
+    const key = data.stringRoute || String(data.route)
+    const existing = routeMappings.find((m) => (m.stringRoute || String(m.route)) === key)
+    const requestMsg = existing?.requestMsg || data.messageName || ''
+    try {
+      await setRouteMapping(data.route, requestMsg, message, activeConnectionId, data.stringRoute, existing?.bodyFormat)
+      addMapping({ route: data.route, stringRoute: data.stringRoute, requestMsg, responseMsg: message, bodyFormat: existing?.bodyFormat })
+      toast.message(`已将 ${shortName(message)} 设为响应消息`)
+      setOpen(false)
+    } catch (err) {
+      toast.error('保存路由映射失败', { description: String(err) })
+    }
+  }
+
+  return (
+    <Popover open={open} onOpenChange={handleOpenChange}>
+      <PopoverTrigger asChild>
+        <button
+          className="inline-flex items-center text-muted-foreground hover:text-foreground transition-colors"
+          title="识别响应消息"
+        >
+          <Wand2 className="size-3" />
+        </button>
+      </PopoverTrigger>
+      <PopoverContent className="w-72 p-1 font-mono text-[11px]">
+        {loading && <div className="p-2 text-muted-foreground">识别中...</div>}
+        {!loading && candidates?.length === 0 && (
+          <div className="p-2 text-muted-foreground">没有能解码该消息体的消息</div>
+        )}
+        {!loading && candidates?.map((c) => (
+          <button
+            key={c.message}
+            onClick={() => handleApply(c.message)}
+            disabled={!node}
+            className="flex w-full items-center gap-2 rounded px-2 py-1 text-left hover:bg-accent disabled:opacity-50"
+            title={formatCompact(c.fields)}
+          >
+            <span className="flex-1 truncate text-blue-400">{shortName(c.message)}</span>
+            <span className="shrink-0 text-muted-foreground">
+              {c.known} 字段{c.suspect > 0 && ` / ${c.suspect} 可疑`}
+            </span>
+          </button>
+        ))}
+      </PopoverContent>
+    </Popover>
+  )
+}
+
 function shortName(fullName: string): string {
   const parts = fullName.split('.')
   return parts[parts.length - 1]
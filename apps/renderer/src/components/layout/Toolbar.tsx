@@ -7,6 +7,7 @@ import { useExecutionStore } from '@/stores/executionStore'
 import { useSavedConnectionStore } from '@/stores/savedConnectionStore'
 import { executeFlow, connectTCP } from '@/services/api'
 import { useCanvasStore } from '@/stores/canvasStore'
+import { resolveParserMode } from '@/types/frame'
 import { toast } from 'sonner'
 
 const stateColors: Record<string, string> = {
@@ -45,9 +46,8 @@ export function Toolbar({ onBack }: ToolbarProps) {
 
     useConnectionStore.getState().setState('connecting')
 
-    const isDueProtocol = connection.frameConfig?.fields?.some(
-      (f) => f.name.toLowerCase() === 'header' && f.bytes === 1
-    ) ?? false
+    const parserMode = resolveParserMode(connection.frameConfig)
+    const isDueProtocol = parserMode === 'due'
 
     try {
       await connectTCP(connection.host, connection.port, {
@@ -56,7 +56,7 @@ export function Toolbar({ onBack }: ToolbarProps) {
         heartbeat: isDueProtocol,
         frameFields: connection.frameConfig?.fields,
         byteOrder: connection.frameConfig?.byteOrder,
-        parserMode: connection.frameConfig?.parserMode,
+        parserMode,
       })
       toast.success('重连成功', {
         description: `已连接到 ${connection.host}:${connection.port}`,
@@ -77,6 +77,8 @@ export function Toolbar({ onBack }: ToolbarProps) {
         route: n.data.route,
         stringRoute: n.data.stringRoute,
         fields: n.data.fields,
+        delay: n.data.delay,
+        header: n.data.header,
       }))
       const flowEdges = edges
         .filter((e) => e.type === 'execEdge')
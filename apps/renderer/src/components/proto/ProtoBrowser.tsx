@@ -33,7 +33,7 @@ import { useCanvasStore, type RequestNodeData } from '@/stores/canvasStore'
 import { useConnectionStore } from '@/stores/connectionStore'
 import { useSavedConnectionStore } from '@/stores/savedConnectionStore'
 import { setRouteMapping, deleteRouteMapping } from '@/services/api'
-import { combineRoute, splitRoute } from '@/types/frame'
+import { combineRoute, splitRoute, isPomeloFamily } from '@/types/frame'
 import { ProtoImport } from './ProtoImport'
 
 export function ProtoBrowser() {
@@ -153,7 +153,7 @@ function MessageNode({ message }: { message: MessageInfo }) {
   const updateNodes = useCanvasStore((s) => s.updateNodes)
 
   const isPomelo = activeConnectionId
-    ? getConnection(activeConnectionId)?.frameConfig?.parserMode === 'pomelo'
+    ? isPomeloFamily(getConnection(activeConnectionId)?.frameConfig?.parserMode)
     : false
 
   const existing = routeMappings.find((m) => m.requestMsg === message.Name)
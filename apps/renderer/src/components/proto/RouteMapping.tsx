@@ -3,12 +3,28 @@ import { Plus, Trash2 } from 'lucide-react'
 import { Button } from '@/components/ui/button'
 import { Input } from '@/components/ui/input'
 import { ScrollArea } from '@/components/ui/scroll-area'
-import { useProtoStore, type RouteMapping as RouteMappingType } from '@/stores/protoStore'
+import {
+  Select,
+  SelectContent,
+  SelectItem,
+  SelectTrigger,
+  SelectValue,
+} from '@/components/ui/select'
+import { useProtoStore, type BodyFormat, type RouteMapping as RouteMappingType } from '@/stores/protoStore'
 import { useConnectionStore } from '@/stores/connectionStore'
 import { useSavedConnectionStore } from '@/stores/savedConnectionStore'
-import { combineRoute, splitRoute } from '@/types/frame'
+import { combineRoute, splitRoute, isPomeloFamily } from '@/types/frame'
 import { setRouteMapping, deleteRouteMapping } from '@/services/api'
 
+// 可选的消息体格式, proto 为默认值, 添加映射时不下发
+const BODY_FORMATS: { value: BodyFormat; label: string }[] = [
+  { value: 'proto', label: 'Proto' },
+  { value: 'json', label: 'JSON' },
+  { value: 'msgpack', label: 'MsgPack' },
+  { value: 'hex', label: 'Hex' },
+  { value: 'utf8', label: 'UTF-8' },
+]
+
 export function RouteMapping() {
   const mappings = useProtoStore((s) => s.routeMappings)
   const addMapping = useProtoStore((s) => s.addRouteMapping)
@@ -19,23 +35,26 @@ export function RouteMapping() {
   const hasRouteFields = routeFields.length > 0
 
   const isPomelo = activeConnectionId
-    ? getConnection(activeConnectionId)?.frameConfig?.parserMode === 'pomelo'
+    ? isPomeloFamily(getConnection(activeConnectionId)?.frameConfig?.parserMode)
     : false
 
   const [newRoute, setNewRoute] = useState('')
   const [newRouteValues, setNewRouteValues] = useState<Record<string, number>>({})
   const [newReqMsg, setNewReqMsg] = useState('')
   const [newRespMsg, setNewRespMsg] = useState('')
+  const [newFormat, setNewFormat] = useState<BodyFormat>('proto')
 
   const handleAdd = async () => {
-    if (!newReqMsg || !activeConnectionId) return
+    // 非 proto 消息体的路由可以不指定 proto 消息
+    if ((!newReqMsg && newFormat === 'proto') || !activeConnectionId) return
+    const bodyFormat = newFormat === 'proto' ? undefined : newFormat
 
     if (isPomelo) {
       if (!newRoute.trim()) return
       const stringRoute = newRoute.trim()
       try {
-        await setRouteMapping(0, newReqMsg, newRespMsg, activeConnectionId, stringRoute)
-        addMapping({ route: 0, stringRoute, requestMsg: newReqMsg, responseMsg: newRespMsg })
+        await setRouteMapping(0, newReqMsg, newRespMsg, activeConnectionId, stringRoute, bodyFormat)
+        addMapping({ route: 0, stringRoute, requestMsg: newReqMsg, responseMsg: newRespMsg, bodyFormat })
         setNewRoute('')
         setNewReqMsg('')
         setNewRespMsg('')
@@ -48,8 +67,8 @@ export function RouteMapping() {
         : parseInt(newRoute)
       if (!route) return
       try {
-        await setRouteMapping(route, newReqMsg, newRespMsg, activeConnectionId)
-        addMapping({ route, requestMsg: newReqMsg, responseMsg: newRespMsg })
+        await setRouteMapping(route, newReqMsg, newRespMsg, activeConnectionId, undefined, bodyFormat)
+        addMapping({ route, requestMsg: newReqMsg, responseMsg: newRespMsg, bodyFormat })
         setNewRoute('')
         setNewRouteValues({})
         setNewReqMsg('')
@@ -117,6 +136,18 @@ export function RouteMapping() {
             onChange={(e) => setNewRespMsg(e.target.value)}
             className="h-6 text-xs flex-1"
           />
+          <Select value={newFormat} onValueChange={(v) => setNewFormat(v as BodyFormat)}>
+            <SelectTrigger className="h-6 w-20 shrink-0 px-1.5 text-[10px] font-mono" title="消息体格式">
+              <SelectValue />
+            </SelectTrigger>
+            <SelectContent>
+              {BODY_FORMATS.map((f) => (
+                <SelectItem key={f.value} value={f.value} className="text-xs">
+                  {f.label}
+                </SelectItem>
+              ))}
+            </SelectContent>
+          </Select>
           <Button variant="ghost" size="sm" className="h-6 px-2 shrink-0" onClick={handleAdd}>
             <Plus className="w-3.5 h-3.5" />
           </Button>
@@ -165,6 +196,11 @@ function MappingRow({
       <span className="flex-1 truncate text-foreground">
         {mapping.responseMsg}
       </span>
+      {mapping.bodyFormat && mapping.bodyFormat !== 'proto' && (
+        <span className="shrink-0 text-[10px] font-mono text-muted-foreground">
+          {BODY_FORMATS.find((f) => f.value === mapping.bodyFormat)?.label}
+        </span>
+      )}
       <Button
         variant="ghost"
         size="sm"
@@ -1,4 +1,5 @@
 import { sendRequest } from './ws'
+import type { BodyFormat } from '@/stores/protoStore'
 
 const API_BASE = () => {
   const port = (window as { __BACKEND_PORT__?: number }).__BACKEND_PORT__ || 3001
@@ -13,7 +14,7 @@ export async function connectTCP(host: string, port: number, options?: {
   heartbeat?: boolean
   frameFields?: { name: string; bytes: number; isRoute?: boolean; isSeq?: boolean }[]
   byteOrder?: 'big' | 'little'
-  parserMode?: 'simple' | 'pomelo'
+  parserMode?: 'simple' | 'field' | 'due' | 'pomelo' | 'cherry' | 'pitaya' | 'nano' | 'leaf' | 'skynet'
 }) {
   return sendRequest('conn.connect', { host, port, ...options })
 }
@@ -63,8 +64,15 @@ export async function getRouteList(connectionId: string) {
   return sendRequest('route.list', { connectionId })
 }
 
-export async function setRouteMapping(route: number, requestMsg: string, responseMsg: string, connectionId: string, stringRoute?: string) {
-  return sendRequest('route.set', { route, stringRoute, requestMsg, responseMsg, connectionId })
+export async function setRouteMapping(route: number, requestMsg: string, responseMsg: string, connectionId: string, stringRoute?: string, bodyFormat?: BodyFormat) {
+  return sendRequest('route.set', { route, stringRoute, requestMsg, responseMsg, connectionId, bodyFormat })
+}
+
+// guessResponseMessage 用已加载的全部消息试解码未映射的响应消息体, 返回按可信度排序的候选
+export async function guessResponseMessage(connectionId: string, data: string, limit?: number) {
+  return sendRequest('route.guess', { connectionId, data, limit }) as Promise<{
+    candidates: { message: string; score: number; known: number; suspect: number; fields: Record<string, unknown> }[]
+  }>
 }
 
 export async function deleteRouteMapping(route: number, connectionId: string, stringRoute?: string) {
@@ -30,6 +30,7 @@ export function initEventBindings(): () => void {
         responseMsg?: string
         request?: Record<string, unknown>
         response?: Record<string, unknown>
+        responseHeader?: Record<string, number>
         duration?: number
       }
       const store = useExecutionStore.getState()
@@ -55,6 +56,7 @@ export function initEventBindings(): () => void {
         type: 'response',
         messageName: data.responseMsg,
         data: data.response ?? {},
+        header: data.responseHeader,
         duration: data.duration,
       })
     })
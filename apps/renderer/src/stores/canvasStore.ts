@@ -6,6 +6,8 @@ export interface RequestNodeData {
   route: number
   stringRoute?: string
   fields: Record<string, unknown>
+  delay?: number
+  header?: Record<string, number>
   responseFields?: { name: string; type: string }[]
   [key: string]: unknown
 }
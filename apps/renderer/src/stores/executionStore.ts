@@ -9,6 +9,7 @@ export interface LogEntry {
   type: 'request' | 'response' | 'error' | 'info'
   messageName?: string
   data: Record<string, unknown>
+  header?: Record<string, number>
   duration?: number
 }
 
@@ -39,11 +39,16 @@ export interface FileInfo {
   Enums: EnumInfo[] | null
 }
 
+// BodyFormat 消息体格式: hex 的字段为 _hex(十六进制字符串), utf8 的字段为 _text
+export type BodyFormat = 'proto' | 'json' | 'msgpack' | 'hex' | 'utf8'
+
 export interface RouteMapping {
   route: number
   stringRoute?: string
   requestMsg: string
   responseMsg: string
+  // bodyFormat 该路由的消息体格式, 为空时沿用连接设置(默认 proto)
+  bodyFormat?: BodyFormat
 }
 
 interface ProtoStore {
@@ -5,6 +5,15 @@ export interface FrameField {
   bytes: number
   isRoute?: boolean
   isSeq?: boolean
+  role?: 'const' | 'checksum' | 'timestamp'
+  value?: number
+  checksum?: 'crc16' | 'crc32' | 'adler32' | 'xor'
+  checksumScope?: 'frame' | 'header' | 'body'
+  timestampUnit?: 's' | 'ms'
+  maxSkew?: number
+  trailer?: boolean
+  lengthField?: string
+  varint?: boolean
 }
 
 export type ByteOrder = 'big' | 'little'
@@ -14,7 +23,25 @@ export interface FrameConfig {
   templateId?: string
   fields: FrameField[]
   byteOrder: ByteOrder
-  parserMode?: 'simple' | 'pomelo'
+  parserMode?: ParserMode
+}
+
+// ParserMode 服务端帧解析模式, pomelo/cherry/pitaya/nano 同属 Pomelo 协议族(字符串路由、握手、路由字典)
+// due/leaf/skynet 为对应框架的预设帧格式, field 和 simple 按帧字段解析
+export type ParserMode = 'simple' | 'field' | 'due' | 'pomelo' | 'cherry' | 'pitaya' | 'nano' | 'leaf' | 'skynet'
+
+export function isPomeloFamily(mode?: ParserMode): boolean {
+  return mode === 'pomelo' || mode === 'cherry' || mode === 'pitaya' || mode === 'nano'
+}
+
+/**
+ * 返回连接使用的解析模式
+ * 旧版本保存的连接没有 parserMode, Due 模板按 due 处理, 其余按帧字段解析
+ */
+export function resolveParserMode(config?: FrameConfig): ParserMode | undefined {
+  if (!config) return undefined
+  if (config.parserMode) return config.parserMode
+  return config.templateId === 'due' ? 'due' : 'field'
 }
 
 export interface FrameTemplate {
@@ -23,6 +50,8 @@ export interface FrameTemplate {
   github: string
   fields: FrameField[]
   byteOrder?: ByteOrder
+  // parserMode 服务端内置的框架预设, 设置后帧字段仅用于展示
+  parserMode?: ParserMode
 }
 
 export async function loadCustomTemplates(): Promise<FrameTemplate[]> {
@@ -87,6 +116,7 @@ export const FRAME_TEMPLATES: FrameTemplate[] = [
       { name: 'route', bytes: 2, isRoute: true },
       { name: 'seq', bytes: 2, isSeq: true },
     ],
+    parserMode: 'due',
   },
   {
     id: 'skynet',
@@ -94,7 +124,39 @@ export const FRAME_TEMPLATES: FrameTemplate[] = [
     github: 'https://github.com/cloudwu/skynet',
     fields: [
       { name: 'size', bytes: 2 },
+      { name: 'type', bytes: 2, isRoute: true },
+    ],
+    parserMode: 'skynet',
+  },
+  {
+    id: 'leaf',
+    name: 'Leaf',
+    github: 'https://github.com/name5566/leaf',
+    fields: [
+      { name: 'len', bytes: 2 },
+      { name: 'id', bytes: 2, isRoute: true },
+    ],
+    parserMode: 'leaf',
+  },
+  {
+    id: 'pitaya',
+    name: 'Pitaya',
+    github: 'https://github.com/topfreegames/pitaya',
+    fields: [
+      { name: 'type', bytes: 1 },
+      { name: 'length', bytes: 3 },
+    ],
+    parserMode: 'pitaya',
+  },
+  {
+    id: 'nano',
+    name: 'Nano',
+    github: 'https://github.com/lonng/nano',
+    fields: [
+      { name: 'type', bytes: 1 },
+      { name: 'length', bytes: 3 },
     ],
+    parserMode: 'nano',
   },
   {
     id: 'tgf',
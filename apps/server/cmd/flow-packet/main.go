@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -19,12 +24,16 @@ import (
 )
 
 func main() {
-	// 工作目录
-	workDir, err := os.UserConfigDir()
-	if err != nil {
-		workDir = os.TempDir()
+	// 子命令: flow-packet mock -config mock.json
+	if len(os.Args) > 1 && os.Args[1] == "mock" {
+		if err := runMock(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "mock: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
-	dataDir := filepath.Join(workDir, "flow-packet")
+
+	dataDir := defaultDataDir()
 
 	// 协议配置
 	packetCfg := codec.PacketConfig{
@@ -36,13 +45,18 @@ func main() {
 	tcpClient := network.NewTCPClient(packetCfg)
 	wsClient := network.NewWSClient(packetCfg)
 
+	// 流量记录器, TCP 和 WebSocket 客户端共享
+	recorder := network.NewTrafficRecorder(network.DefaultTrafficCapacity, packetCfg)
+	tcpClient.SetRecorder(recorder)
+	wsClient.SetRecorder(recorder)
+
 	// activeClient 指向当前活跃的客户端, 默认为 TCP
 	var activeClient network.Client = tcpClient
 
 	// 初始化执行引擎
 	runner := engine.NewRunner(packetCfg)
-	runner.SetSendFunc(func(data []byte) error {
-		return activeClient.Send(data)
+	runner.SetSendFunc(func(pkt *codec.Packet) error {
+		return activeClient.SendPacket(pkt)
 	})
 
 	// 初始化 API 服务
@@ -51,9 +65,9 @@ func main() {
 	api.RegisterHandlers(srv, appState)
 
 	// 心跳模块
-	hb := network.NewHeartbeat(network.DefaultHeartbeatConfig(), packetCfg)
-	hb.OnSend(func(data []byte) error {
-		return activeClient.Send(data)
+	hb := network.NewHeartbeat(network.DefaultHeartbeatConfig())
+	hb.OnSend(func(pkt *codec.Packet) error {
+		return activeClient.SendPacket(pkt)
 	})
 	hb.OnTimeout(func() {
 		activeClient.Disconnect()
@@ -63,19 +77,21 @@ func main() {
 	pomeloHandshakeCh := make(chan []byte, 1)
 
 	// 注册连接管理 handlers
-	registerConnHandlers(srv, tcpClient, wsClient, &activeClient, &packetCfg, runner, hb, pomeloHandshakeCh)
+	applyConfig := registerConnHandlers(srv, tcpClient, wsClient, &activeClient, &packetCfg, runner, hb, recorder, pomeloHandshakeCh)
 
 	// 注册流程执行 handlers
 	registerFlowHandlers(srv, runner, appState)
 
+	// 注册流量记录 handlers
+	registerTrafficHandlers(srv, recorder, appState, &packetCfg, dataDir)
+
+	// 注册透明代理 handlers
+	proxyCtl := registerProxyHandlers(srv, recorder, appState, &packetCfg)
+
 	// 收包回调 -> 匹配 seq 响应
 	// 注意: 闭包捕获 packetCfg 变量(而非值), registerConnHandlers 通过指针更新后,
 	// 此处下次调用即使用新配置
-	onReceive := func(conn network.Conn, data []byte) {
-		pkt, err := codec.DecodeBytes(data, packetCfg)
-		if err != nil {
-			return
-		}
+	onReceive := func(conn network.Conn, pkt *codec.Packet) {
 		if pkt.IsHeartbeat() {
 			hb.Feed()
 			return
@@ -93,10 +109,18 @@ func main() {
 			}
 			return
 		}
-		// 先精确匹配 seq; 若服务端不回传 seq(seq=0), 回退到匹配最早的等待请求
-		if !runner.SeqCtx().Resolve(pkt.Seq, pkt.Data) {
-			runner.SeqCtx().ResolveFirst(pkt.Data)
+		// 密钥交换响应: 先装入新密钥再唤醒等待方, 保证后续请求使用新密钥
+		if cipher, ok := packetCfg.Cipher.ExchangeKey(pkt); ok {
+			cfg := packetCfg
+			cfg.Cipher = cipher
+			applyConfig(cfg)
+			srv.Broadcast(api.ServerMessage{
+				Event:   "conn.keyExchanged",
+				Payload: map[string]any{"algorithm": cipher.Algorithm, "route": pkt.Route, "stringRoute": pkt.StringRoute},
+			})
 		}
+		// 先精确匹配 seq; 若服务端不回传 seq(seq=0), 回退到匹配最早的等待请求
+		runner.SeqCtx().ResolvePacket(pkt)
 	}
 
 	// 连接状态推送
@@ -115,6 +139,20 @@ func main() {
 		})
 	}
 
+	// 协议错误推送: 重新同步成功时连接保持, 否则随后会收到断开事件
+	onProtocolError := func(conn network.Conn, err error) {
+		payload := map[string]any{"error": err.Error(), "resynced": false}
+		var resyncErr *codec.ResyncError
+		if errors.As(err, &resyncErr) {
+			payload["resynced"] = true
+			payload["skipped"] = resyncErr.Skipped
+		}
+		srv.Broadcast(api.ServerMessage{
+			Event:   "conn.protocolError",
+			Payload: payload,
+		})
+	}
+
 	// 为 TCP 和 WebSocket 客户端注册相同的回调
 	tcpClient.OnReceive(onReceive)
 	tcpClient.OnConnect(onConnect)
@@ -122,6 +160,8 @@ func main() {
 	wsClient.OnReceive(onReceive)
 	wsClient.OnConnect(onConnect)
 	wsClient.OnDisconnect(onDisconnect)
+	tcpClient.OnProtocolError(onProtocolError)
+	wsClient.OnProtocolError(onProtocolError)
 
 	// 启动 HTTP/WS 服务, 优先使用固定端口, 失败时回退到动态端口
 	actualPort, err := srv.Start(58996)
@@ -144,34 +184,239 @@ func main() {
 	// 优雅退出
 	tcpClient.Disconnect()
 	wsClient.Disconnect()
+	proxyCtl.stop()
 	srv.Stop()
 }
 
-func registerConnHandlers(srv *api.Server, tcpClient *network.TCPClient, wsClient *network.WSClient, activeClient *network.Client, packetCfg *codec.PacketConfig, runner *engine.Runner, hb *network.Heartbeat, pomeloHandshakeCh chan []byte) {
+// defaultDataDir 返回默认数据目录
+func defaultDataDir() string {
+	workDir, err := os.UserConfigDir()
+	if err != nil {
+		workDir = os.TempDir()
+	}
+	return filepath.Join(workDir, "flow-packet")
+}
+
+// frameField 前端传入的协议帧字段定义
+type frameField struct {
+	Name    string `json:"name"`
+	Bytes   int    `json:"bytes"`
+	IsRoute bool   `json:"isRoute"`
+	IsSeq   bool   `json:"isSeq"`
+	// 以下为特殊角色字段(固定值、校验和、时间戳)、trailer、变长和 varint 字段的配置, 含义见 codec.FieldDef
+	Role          string `json:"role"`
+	Value         uint64 `json:"value"`
+	Checksum      string `json:"checksum"`
+	ChecksumScope string `json:"checksumScope"`
+	TimestampUnit string `json:"timestampUnit"`
+	MaxSkew       uint64 `json:"maxSkew"`
+	Trailer       bool   `json:"trailer"`
+	LengthField   string `json:"lengthField"`
+	Varint        bool   `json:"varint"`
+}
+
+// frameSpec 前端和模拟网关配置共用的帧格式描述
+type frameSpec struct {
+	ParserMode  string       `json:"parserMode"`
+	ByteOrder   string       `json:"byteOrder"`
+	FrameFields []frameField `json:"frameFields"`
+	// SizeMode size 字段覆盖范围: body(默认) | frame | afterSize, 仅字段驱动模式生效
+	SizeMode string `json:"sizeMode"`
+	// SizeAdjustment size 值的修正量, 覆盖范围实际长度 = size + sizeAdjustment
+	SizeAdjustment int `json:"sizeAdjustment"`
+	// Compression 消息体压缩配置, 为空表示不压缩
+	Compression *codec.CompressionConfig `json:"compression"`
+	// Cipher 加密配置, 为空表示不加密
+	Cipher *cipherSpec `json:"cipher"`
+	// BodyFormat 消息体格式: proto(默认) | json | msgpack | hex | utf8, 路由映射可单独覆盖
+	BodyFormat string `json:"bodyFormat"`
+	// BytesBase64 proto bytes 字段以 base64 显示和输入, 默认为十六进制
+	BytesBase64 bool `json:"bytesBase64"`
+}
+
+// cipherSpec 前端传入的加密配置, 密钥和 IV 为十六进制, 含义见 codec.CipherConfig
+type cipherSpec struct {
+	Algorithm   string             `json:"algorithm"`
+	Key         string             `json:"key"`
+	IV          string             `json:"iv"`
+	Header      bool               `json:"header"`
+	KeyExchange *codec.KeyExchange `json:"keyExchange"`
+}
+
+// applyPayloadStages 校验 spec 中的消息体格式、压缩和加密配置并写入 cfg
+func applyPayloadStages(cfg *codec.PacketConfig, spec frameSpec) error {
+	if !codec.ValidBodyFormat(spec.BodyFormat) {
+		return fmt.Errorf("invalid body format: %q", spec.BodyFormat)
+	}
+	cfg.BodyFormat = spec.BodyFormat
+	cfg.ProtoJSON = codec.ProtoJSONOptions{BytesBase64: spec.BytesBase64}
+
+	cfg.Compression = nil
+	if spec.Compression != nil && spec.Compression.Algorithm != "" {
+		if err := spec.Compression.Validate(cfg.FieldDriven); err != nil {
+			return err
+		}
+		cfg.Compression = spec.Compression
+	}
+
+	cfg.Cipher = nil
+	if spec.Cipher != nil && spec.Cipher.Algorithm != "" {
+		key, err := hex.DecodeString(spec.Cipher.Key)
+		if err != nil {
+			return fmt.Errorf("invalid cipher key: %w", err)
+		}
+		iv, err := hex.DecodeString(spec.Cipher.IV)
+		if err != nil {
+			return fmt.Errorf("invalid cipher iv: %w", err)
+		}
+		c := &codec.CipherConfig{
+			Algorithm:   spec.Cipher.Algorithm,
+			Key:         key,
+			IV:          iv,
+			Header:      spec.Cipher.Header,
+			KeyExchange: spec.Cipher.KeyExchange,
+		}
+		if err := c.Validate(cfg.FieldDriven); err != nil {
+			return err
+		}
+		cfg.Cipher = c
+	}
+	return nil
+}
+
+// buildPacketConfig 根据解析模式和帧字段计算 PacketConfig
+//
+// 解析模式为框架预设(codec.Presets)或其他已注册的帧编解码器时按模式创建; 否则按帧字段创建字段驱动配置,
+// 没有帧字段时返回 nil, 表示沿用当前配置. 未指定模式的模板含 1 字节 header 字段时返回歧义错误
+func buildPacketConfig(spec frameSpec) (*codec.PacketConfig, error) {
+	parserMode, byteOrder, frameFields := spec.ParserMode, spec.ByteOrder, spec.FrameFields
+	// Due 帧的 route/seq 宽度可由帧字段指定
+	if parserMode == codec.ProtocolDue && len(frameFields) > 0 {
+		return buildDueConfig(frameFields)
+	}
+	// 框架预设(Due、Pomelo 协议族、Leaf、Skynet)的帧格式固定, 忽略帧字段
+	if cfg, ok := codec.Preset(parserMode); ok {
+		return &cfg, nil
+	}
+	// 其他已注册的帧编解码器(新框架协议)按名称选择, 帧格式由编解码器自身决定
+	switch parserMode {
+	case "", "simple", codec.ProtocolFieldDriven:
+	default:
+		if codec.HasFrameCodec(parserMode) {
+			return &codec.PacketConfig{Protocol: parserMode}, nil
+		}
+		return nil, fmt.Errorf("unknown parser mode: %q", parserMode)
+	}
+	if len(frameFields) == 0 {
+		return nil, nil
+	}
+
+	// 未指定解析模式时, 形如 Due 帧(1 字节 header 字段)的模板既可能是 Due 也可能是恰好同名的自定义字段, 要求显式选择
+	if parserMode == "" && looksLikeDue(frameFields) {
+		return nil, fmt.Errorf("ambiguous frame fields: 1-byte %q field matches the Due packet header; set parserMode to %q for the Due framework or %q for a custom field-driven frame",
+			"header", codec.ProtocolDue, codec.ProtocolFieldDriven)
+	}
+
+	// 字段驱动模式
+	fields := make([]codec.FieldDef, len(frameFields))
+	for i, f := range frameFields {
+		fields[i] = codec.FieldDef{
+			Name:          f.Name,
+			Bytes:         f.Bytes,
+			IsRoute:       f.IsRoute,
+			IsSeq:         f.IsSeq,
+			Role:          f.Role,
+			Value:         f.Value,
+			Checksum:      f.Checksum,
+			ChecksumScope: f.ChecksumScope,
+			TimestampUnit: f.TimestampUnit,
+			MaxSkew:       f.MaxSkew,
+			Trailer:       f.Trailer,
+			LengthField:   f.LengthField,
+			Varint:        f.Varint,
+		}
+	}
+	fdCfg, err := codec.NewFieldDrivenConfig(fields)
+	if err != nil {
+		return nil, fmt.Errorf("invalid frame fields: %w", err)
+	}
+	fdCfg.BigEndian = byteOrder == "big"
+	switch spec.SizeMode {
+	case "", codec.SizeModeBody, codec.SizeModeFrame, codec.SizeModeAfterSize:
+		fdCfg.SizeMode = spec.SizeMode
+	default:
+		return nil, fmt.Errorf("invalid size mode: %q", spec.SizeMode)
+	}
+	fdCfg.SizeAdjustment = spec.SizeAdjustment
+	return &codec.PacketConfig{
+		FieldDriven: fdCfg,
+	}, nil
+}
+
+// looksLikeDue 返回帧字段中是否存在 1 字节的 header 字段(旧版本据此推断 Due 模式)
+func looksLikeDue(frameFields []frameField) bool {
+	for _, f := range frameFields {
+		if strings.ToLower(f.Name) == "header" && f.Bytes == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// buildDueConfig 按帧字段中的 route/seq 字段宽度创建 Due 帧配置
+//
+// Due 帧的 size 和 header 固定, 只允许一个 route 字段和至多一个 seq 字段
+func buildDueConfig(frameFields []frameField) (*codec.PacketConfig, error) {
+	var routeBytes, seqBytes, routes, seqs int
+	for _, f := range frameFields {
+		if f.IsRoute {
+			routeBytes = f.Bytes
+			routes++
+		}
+		if f.IsSeq {
+			seqBytes = f.Bytes
+			seqs++
+		}
+	}
+	if routes != 1 || seqs > 1 {
+		return nil, fmt.Errorf("invalid due frame fields: need exactly one route field and at most one seq field, got %d and %d", routes, seqs)
+	}
+	cfg, err := codec.NewDueConfig(routeBytes, seqBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid due frame fields: %w", err)
+	}
+	return &cfg, nil
+}
+
+// registerConnHandlers 注册连接管理 handlers, 返回将新 PacketConfig 同步到所有组件的函数
+func registerConnHandlers(srv *api.Server, tcpClient *network.TCPClient, wsClient *network.WSClient, activeClient *network.Client, packetCfg *codec.PacketConfig, runner *engine.Runner, hb *network.Heartbeat, recorder *network.TrafficRecorder, pomeloHandshakeCh chan []byte) func(codec.PacketConfig) {
 	// applyConfig 将新的 PacketConfig 同步到所有组件
 	applyConfig := func(newCfg codec.PacketConfig) {
 		*packetCfg = newCfg
 		tcpClient.SetPacketConfig(newCfg)
 		wsClient.SetPacketConfig(newCfg)
 		runner.SetPacketConfig(newCfg)
+		recorder.SetPacketConfig(newCfg)
 	}
 
 	srv.Handle("conn.connect", func(payload json.RawMessage) (any, error) {
 		var req struct {
-			Host        string `json:"host"`
-			Port        int    `json:"port"`
-			Protocol    string `json:"protocol"`
-			Timeout     int    `json:"timeout"`
-			Reconnect   bool   `json:"reconnect"`
-			Heartbeat   bool   `json:"heartbeat"`
-			ByteOrder   string `json:"byteOrder"`
-			ParserMode  string `json:"parserMode"`
-			FrameFields []struct {
-				Name    string `json:"name"`
-				Bytes   int    `json:"bytes"`
-				IsRoute bool   `json:"isRoute"`
-				IsSeq   bool   `json:"isSeq"`
-			} `json:"frameFields"`
+			Host      string `json:"host"`
+			Port      int    `json:"port"`
+			Protocol  string `json:"protocol"`
+			Timeout   int    `json:"timeout"`
+			Reconnect bool   `json:"reconnect"`
+			Heartbeat bool   `json:"heartbeat"`
+			frameSpec
+			// Faults 故障注入配置, 为空表示不注入
+			Faults network.FaultConfig `json:"faults"`
+			// MaxFrameSize 单帧上限(字节), 0 使用默认值, 负数不限制
+			MaxFrameSize int `json:"maxFrameSize"`
+			// Resync 协议错误后的重新同步标记, 为空时协议错误直接断开
+			Resync *struct {
+				Magic  string `json:"magic"` // 十六进制
+				Offset int    `json:"offset"`
+			} `json:"resync"`
 		}
 		if err := json.Unmarshal(payload, &req); err != nil {
 			return nil, fmt.Errorf("invalid payload: %w", err)
@@ -187,61 +432,27 @@ func registerConnHandlers(srv *api.Server, tcpClient *network.TCPClient, wsClien
 		default:
 		}
 
-		// Pomelo 模式
-		if req.ParserMode == "pomelo" {
-			newCfg := codec.PacketConfig{
-				Pomelo: &codec.PomeloConfig{
-					UseRouteCompress: true,
-				},
-			}
-			applyConfig(newCfg)
-		} else if len(req.FrameFields) > 0 {
-			// 根据 frameFields 动态计算 PacketConfig
-			// Due 检测: 存在 name=="header" && bytes==1 -> legacy 模式
-			isDue := false
-			for _, f := range req.FrameFields {
-				if strings.ToLower(f.Name) == "header" && f.Bytes == 1 {
-					isDue = true
-					break
-				}
-			}
-
-			if isDue {
-				// Legacy Due 模式: 只计算 RouteBytes/SeqBytes
-				var routeBytes, seqBytes int
-				for _, f := range req.FrameFields {
-					if f.IsRoute {
-						routeBytes += f.Bytes
-					}
-					if f.IsSeq || strings.ToLower(f.Name) == "seq" {
-						seqBytes = f.Bytes
-					}
-				}
-				applyConfig(codec.PacketConfig{
-					RouteBytes: routeBytes,
-					SeqBytes:   seqBytes,
-				})
-			} else {
-				// 字段驱动模式
-				fields := make([]codec.FieldDef, len(req.FrameFields))
-				for i, f := range req.FrameFields {
-					fields[i] = codec.FieldDef{
-						Name:    f.Name,
-						Bytes:   f.Bytes,
-						IsRoute: f.IsRoute,
-						IsSeq:   f.IsSeq,
-					}
-				}
-				fdCfg, err := codec.NewFieldDrivenConfig(fields)
-				if err != nil {
-					return nil, fmt.Errorf("invalid frame fields: %w", err)
-				}
-				fdCfg.BigEndian = req.ByteOrder == "big"
-				applyConfig(codec.PacketConfig{
-					FieldDriven: fdCfg,
-				})
+		newCfg, err := buildPacketConfig(req.frameSpec)
+		if err != nil {
+			return nil, err
+		}
+		cfg := *packetCfg
+		if newCfg != nil {
+			cfg = *newCfg
+		}
+		cfg.MaxFrameSize = req.MaxFrameSize
+		cfg.Resync = nil
+		if req.Resync != nil && req.Resync.Magic != "" {
+			magic, err := hex.DecodeString(req.Resync.Magic)
+			if err != nil {
+				return nil, fmt.Errorf("invalid resync magic: %w", err)
 			}
+			cfg.Resync = &codec.ResyncConfig{Magic: magic, Offset: req.Resync.Offset}
+		}
+		if err := applyPayloadStages(&cfg, req.frameSpec); err != nil {
+			return nil, err
 		}
+		applyConfig(cfg)
 
 		addr := fmt.Sprintf("%s:%d", req.Host, req.Port)
 
@@ -256,11 +467,13 @@ func registerConnHandlers(srv *api.Server, tcpClient *network.TCPClient, wsClien
 		// 根据请求配置心跳
 		if req.Heartbeat || packetCfg.IsPomelo() {
 			hb.SetEnable(true)
-			hb.SetPacketConfig(*packetCfg)
 		} else {
 			hb.SetEnable(false)
 		}
 
+		tcpClient.SetFaultConfig(req.Faults)
+		wsClient.SetFaultConfig(req.Faults)
+
 		// 根据 protocol 选择客户端
 		if req.Protocol == "ws" {
 			*activeClient = wsClient
@@ -274,9 +487,9 @@ func registerConnHandlers(srv *api.Server, tcpClient *network.TCPClient, wsClien
 			return nil, fmt.Errorf("connect failed: %w", err)
 		}
 
-		// Pomelo 握手流程
+		// Pomelo 握手流程(Cherry、Pitaya、Nano 相同), sys 同时携带 Pitaya 握手校验使用的 platform/libVersion 等字段
 		if packetCfg.IsPomelo() {
-			hsPayload := []byte(`{"sys":{"type":"flow-packet","version":"1.0.0"},"user":{}}`)
+			hsPayload := []byte(`{"sys":{"type":"flow-packet","version":"1.0.0","platform":"flow-packet","libVersion":"1.0.0","clientBuildNumber":"1","clientVersion":"1.0.0"},"user":{}}`)
 			if err := (*activeClient).Send(codec.PomeloEncodeHandshake(hsPayload)); err != nil {
 				(*activeClient).Disconnect()
 				return nil, fmt.Errorf("pomelo handshake send failed: %w", err)
@@ -288,8 +501,10 @@ func registerConnHandlers(srv *api.Server, tcpClient *network.TCPClient, wsClien
 				var hsResp struct {
 					Code int `json:"code"`
 					Sys  struct {
-						Heartbeat int            `json:"heartbeat"`
-						Dict      map[string]int `json:"dict"`
+						// Heartbeat 心跳间隔(秒), Nano 下发小数秒
+						Heartbeat  float64        `json:"heartbeat"`
+						Dict       map[string]int `json:"dict"`
+						Serializer string         `json:"serializer"`
 					} `json:"sys"`
 				}
 				if err := json.Unmarshal(hsData, &hsResp); err != nil {
@@ -300,8 +515,28 @@ func registerConnHandlers(srv *api.Server, tcpClient *network.TCPClient, wsClien
 					(*activeClient).Disconnect()
 					return nil, fmt.Errorf("pomelo handshake rejected: code %d", hsResp.Code)
 				}
-				fmt.Printf("[pomelo] handshake ok, heartbeat=%ds, routes=%d\n",
-					hsResp.Sys.Heartbeat, len(hsResp.Sys.Dict))
+				fmt.Printf("[pomelo] handshake ok, heartbeat=%gs, routes=%d, serializer=%q\n",
+					hsResp.Sys.Heartbeat, len(hsResp.Sys.Dict), hsResp.Sys.Serializer)
+
+				// 按握手响应更新路由字典和心跳间隔
+				cfg := *packetCfg
+				if len(hsResp.Sys.Dict) > 0 {
+					var pc codec.PomeloConfig
+					if packetCfg.Pomelo != nil {
+						pc = *packetCfg.Pomelo
+					}
+					pc.RouteDict = make(map[string]uint16, len(hsResp.Sys.Dict))
+					for route, code := range hsResp.Sys.Dict {
+						pc.RouteDict[route] = uint16(code)
+					}
+					cfg.Pomelo = &pc
+				}
+				// Pitaya 握手响应声明 JSON 序列化时, 未显式指定格式的连接改用 JSON 消息体
+				if hsResp.Sys.Serializer == codec.BodyJSON && cfg.BodyFormat == "" {
+					cfg.BodyFormat = codec.BodyJSON
+				}
+				applyConfig(cfg)
+				hb.SetInterval(time.Duration(hsResp.Sys.Heartbeat * float64(time.Second)))
 
 			case <-time.After(10 * time.Second):
 				(*activeClient).Disconnect()
@@ -328,6 +563,8 @@ func registerConnHandlers(srv *api.Server, tcpClient *network.TCPClient, wsClien
 	srv.Handle("conn.status", func(payload json.RawMessage) (any, error) {
 		return map[string]string{"state": (*activeClient).State().String()}, nil
 	})
+
+	return applyConfig
 }
 
 func registerFlowHandlers(srv *api.Server, runner *engine.Runner, state *api.AppState) {
@@ -343,40 +580,22 @@ func registerFlowHandlers(srv *api.Server, runner *engine.Runner, state *api.App
 
 		cs := state.GetConnState(req.ConnectionID)
 
-		// 设置消息解析器
-		runner.SetResolver(func(messageName string) protoreflect.MessageDescriptor {
-			if cs == nil || cs.ParseResult == nil {
-				return nil
-			}
-			md := cs.ParseResult.FindMessageDescriptor(messageName)
-			return md
-		})
+		// 设置消息解析器, 同时用于解析 Any 内嵌的消息类型
+		runner.SetResolver(cs.MessageDescriptor)
 
 		// 设置响应解析器
-		runner.SetResponseResolver(func(route uint32) protoreflect.MessageDescriptor {
-			if cs == nil || cs.ParseResult == nil {
-				return nil
-			}
-			key := fmt.Sprintf("%d", route)
-			mapping, ok := cs.RouteMappings[key]
-			if !ok {
-				return nil
-			}
-			return cs.ParseResult.FindMessageDescriptor(mapping.ResponseMsg)
+		runner.SetResponseResolver(func(route uint64) protoreflect.MessageDescriptor {
+			return cs.ResponseDescriptor(route, "")
 		})
 
 		// 设置字符串路由响应解析器(Pomelo 模式)
 		runner.SetStringRouteResponseResolver(func(route string) protoreflect.MessageDescriptor {
-			if cs == nil || cs.ParseResult == nil {
-				return nil
-			}
-			mapping, ok := cs.RouteMappings[route]
-			if !ok {
-				return nil
-			}
-			return cs.ParseResult.FindMessageDescriptor(mapping.ResponseMsg)
+			return cs.ResponseDescriptor(0, route)
 		})
 
+		// 路由映射可单独指定消息体格式
+		runner.SetBodyFormatResolver(cs.BodyFormat)
+
 		// 异步执行
 		go func() {
 			srv.Broadcast(api.ServerMessage{
@@ -416,4 +635,426 @@ func registerFlowHandlers(srv *api.Server, runner *engine.Runner, state *api.App
 		runner.Stop()
 		return map[string]string{"status": "stopped"}, nil
 	})
+
+	srv.Handle("stats.routes", func(payload json.RawMessage) (any, error) {
+		return map[string]any{"routes": runner.Stats().Snapshot()}, nil
+	})
+
+	srv.Handle("stats.reset", func(payload json.RawMessage) (any, error) {
+		runner.Stats().Reset()
+		return map[string]string{"status": "ok"}, nil
+	})
+}
+
+func registerTrafficHandlers(srv *api.Server, recorder *network.TrafficRecorder, state *api.AppState, packetCfg *codec.PacketConfig, dataDir string) {
+	// 实时推送每一帧
+	recorder.OnFrame(func(frame network.TrafficFrame) {
+		srv.Broadcast(api.ServerMessage{
+			Event:   "traffic.frame",
+			Payload: frame,
+		})
+	})
+
+	srv.Handle("traffic.list", func(payload json.RawMessage) (any, error) {
+		var req struct {
+			AfterID uint64 `json:"afterId"`
+			Limit   int    `json:"limit"`
+		}
+		if len(payload) > 0 {
+			if err := json.Unmarshal(payload, &req); err != nil {
+				return nil, fmt.Errorf("invalid payload: %w", err)
+			}
+		}
+		return map[string]any{"frames": recorder.List(req.AfterID, req.Limit)}, nil
+	})
+
+	srv.Handle("traffic.clear", func(payload json.RawMessage) (any, error) {
+		recorder.Clear()
+		return map[string]string{"status": "ok"}, nil
+	})
+
+	srv.Handle("traffic.export", func(payload json.RawMessage) (any, error) {
+		var req struct {
+			Path   string `json:"path"`
+			Format string `json:"format"` // jsonl | pcapng
+		}
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+
+		ext := ".jsonl"
+		if req.Format == "pcapng" {
+			ext = ".pcapng"
+		} else if req.Format != "" && req.Format != "jsonl" {
+			return nil, fmt.Errorf("unsupported format: %s", req.Format)
+		}
+
+		// 未指定路径时导出到数据目录
+		path := req.Path
+		if path == "" {
+			dir := filepath.Join(dataDir, "traffic")
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("create export dir: %w", err)
+			}
+			path = filepath.Join(dir, fmt.Sprintf("traffic_%d%s", time.Now().UnixMilli(), ext))
+		}
+
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("create export file: %w", err)
+		}
+		defer f.Close()
+
+		frames := recorder.List(0, 0)
+		if ext == ".pcapng" {
+			local, remote := recorder.Endpoints()
+			err = network.WriteTrafficPcapng(f, frames, local, remote)
+		} else {
+			err = network.WriteTrafficJSONLines(f, frames)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("export traffic: %w", err)
+		}
+		return map[string]any{"path": path, "count": len(frames)}, nil
+	})
+
+	srv.Handle("traffic.replay", func(payload json.RawMessage) (any, error) {
+		var req struct {
+			ConnectionID string `json:"connectionId"`
+			FromID       uint64 `json:"fromId"`   // 起始帧 ID(含), 0 表示从头开始
+			ToID         uint64 `json:"toId"`     // 结束帧 ID(含), 0 表示到最新一帧
+			MaxDelay     int64  `json:"maxDelay"` // 单节点等待上限(毫秒), 0 表示不限制
+			Name         string `json:"name"`     // 非空时保存为集合
+			FolderID     string `json:"folderId"`
+		}
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		cs := state.GetConnState(req.ConnectionID)
+		if cs == nil {
+			return nil, fmt.Errorf("invalid connectionId")
+		}
+
+		var afterID uint64
+		if req.FromID > 0 {
+			afterID = req.FromID - 1
+		}
+
+		// 使用记录时解码的数据包, 过滤心跳和 Pomelo 控制包
+		var msgs []engine.RecordedMessage
+		for _, f := range recorder.List(afterID, 0) {
+			if req.ToID > 0 && f.ID > req.ToID {
+				break
+			}
+			pkt := f.Packet
+			if pkt == nil || pkt.IsHeartbeat() {
+				continue
+			}
+			if packetCfg.IsPomelo() && pkt.ExtCode != 0 {
+				continue
+			}
+			msgs = append(msgs, engine.RecordedMessage{
+				Time:   f.Time,
+				Sent:   f.Direction == network.TrafficSent,
+				Packet: pkt,
+			})
+		}
+
+		// 消息体格式和字段转换选项与执行流程时的 Runner 一致, 保证回放时按原格式重新编码
+		format := engine.BodyFormatResolver(cs.BodyFormat).Or(packetCfg.BodyFormat)
+		opts := packetCfg.ProtoJSON
+		if opts.Resolver == nil {
+			opts.Resolver = cs.MessageDescriptor
+		}
+		nodes, edges, err := engine.BuildReplayFlow(msgs, cs.RequestDescriptor, format, opts, time.Duration(req.MaxDelay)*time.Millisecond)
+		if err != nil {
+			return nil, err
+		}
+
+		result := map[string]any{"nodes": nodes, "edges": edges}
+		if req.Name != "" {
+			canvasNodes, canvasEdges, err := replayCanvas(nodes, edges)
+			if err != nil {
+				return nil, err
+			}
+			item, err := cs.SaveCollection(req.Name, req.FolderID, canvasNodes, canvasEdges)
+			if err != nil {
+				return nil, err
+			}
+			result["item"] = item
+		}
+		return result, nil
+	})
+}
+
+// proxyController 管理当前运行的透明代理, 同一时间只允许一个
+type proxyController struct {
+	mu    sync.Mutex
+	proxy *network.Proxy
+	cfg   network.ProxyConfig
+	addr  string
+}
+
+func (c *proxyController) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.proxy != nil {
+		c.proxy.Stop()
+		c.proxy = nil
+	}
+}
+
+func registerProxyHandlers(srv *api.Server, recorder *network.TrafficRecorder, state *api.AppState, packetCfg *codec.PacketConfig) *proxyController {
+	ctl := &proxyController{}
+
+	srv.Handle("proxy.start", func(payload json.RawMessage) (any, error) {
+		var req struct {
+			ConnectionID string `json:"connectionId"`
+			Protocol     string `json:"protocol"`   // tcp | ws, 默认 tcp
+			ListenAddr   string `json:"listenAddr"` // 本地监听地址
+			Upstream     string `json:"upstream"`   // 真实网关地址
+			Record       bool   `json:"record"`     // 是否同时写入流量记录器
+		}
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		if req.Upstream == "" {
+			return nil, fmt.Errorf("upstream is required")
+		}
+		if req.Protocol == "" {
+			req.Protocol = "tcp"
+		}
+		if req.Protocol != "tcp" && req.Protocol != "ws" {
+			return nil, fmt.Errorf("unsupported protocol: %s", req.Protocol)
+		}
+		if req.ListenAddr == "" {
+			req.ListenAddr = "127.0.0.1:0"
+		}
+		cs := state.GetConnState(req.ConnectionID)
+
+		ctl.mu.Lock()
+		defer ctl.mu.Unlock()
+		if ctl.proxy != nil {
+			return nil, fmt.Errorf("proxy already running on %s", ctl.addr)
+		}
+
+		cfg := network.ProxyConfig{
+			Protocol:     req.Protocol,
+			ListenAddr:   req.ListenAddr,
+			Upstream:     req.Upstream,
+			PacketConfig: *packetCfg,
+		}
+		proxy := network.NewProxy(cfg)
+		decoder := newProxyDecoder(cs, cfg.PacketConfig)
+
+		proxy.OnSession(func(session uint64, client net.Addr, err error) {
+			event := map[string]any{"session": session, "client": client.String(), "state": "open"}
+			if err != nil {
+				decoder.forget(session)
+				event["state"] = "closed"
+				if !errors.Is(err, io.EOF) {
+					event["error"] = err.Error()
+				}
+			}
+			srv.Broadcast(api.ServerMessage{Event: "proxy.session", Payload: event})
+		})
+		proxy.OnMessage(func(msg network.ProxyMessage) {
+			if req.Record {
+				recorder.Record(msg.Direction, msg.Raw)
+			}
+			srv.Broadcast(api.ServerMessage{Event: "proxy.message", Payload: decoder.decode(msg)})
+		})
+
+		addr, err := proxy.Start()
+		if err != nil {
+			return nil, fmt.Errorf("start proxy: %w", err)
+		}
+		ctl.proxy = proxy
+		ctl.cfg = cfg
+		ctl.addr = addr.String()
+		return map[string]any{"status": "running", "listenAddr": ctl.addr, "upstream": cfg.Upstream, "protocol": cfg.Protocol}, nil
+	})
+
+	srv.Handle("proxy.stop", func(payload json.RawMessage) (any, error) {
+		ctl.stop()
+		return map[string]string{"status": "stopped"}, nil
+	})
+
+	srv.Handle("proxy.status", func(payload json.RawMessage) (any, error) {
+		ctl.mu.Lock()
+		defer ctl.mu.Unlock()
+		if ctl.proxy == nil {
+			return map[string]any{"status": "stopped"}, nil
+		}
+		return map[string]any{"status": "running", "listenAddr": ctl.addr, "upstream": ctl.cfg.Upstream, "protocol": ctl.cfg.Protocol}, nil
+	})
+
+	return ctl
+}
+
+// proxyDecoder 按路由映射解码代理捕获的消息体
+//
+// 服务端响应可能不携带路由(如 Pomelo 响应), 因此按会话记录 seq → 请求路由,
+// 用于为响应查找消息类型
+type proxyDecoder struct {
+	cs  *api.ConnState
+	cfg codec.PacketConfig
+
+	mu       sync.Mutex
+	requests map[uint64]map[uint64]codec.Packet
+}
+
+func newProxyDecoder(cs *api.ConnState, cfg codec.PacketConfig) *proxyDecoder {
+	// Any 内嵌的消息类型从该连接加载的 proto 中查找
+	cfg.ProtoJSON.Resolver = cs.MessageDescriptor
+	return &proxyDecoder{cs: cs, cfg: cfg, requests: make(map[uint64]map[uint64]codec.Packet)}
+}
+
+// forget 会话结束时清理 seq 记录
+func (d *proxyDecoder) forget(session uint64) {
+	d.mu.Lock()
+	delete(d.requests, session)
+	d.mu.Unlock()
+}
+
+// decode 将捕获的帧转换为推送给前端的消息
+func (d *proxyDecoder) decode(msg network.ProxyMessage) map[string]any {
+	out := map[string]any{
+		"session":   msg.Session,
+		"direction": msg.Direction,
+		"time":      msg.Time.UnixMilli(),
+		"size":      len(msg.Raw),
+	}
+	if msg.Err != nil {
+		out["error"] = msg.Err.Error()
+		return out
+	}
+
+	pkt := msg.Packet
+	if pkt.IsHeartbeat() || (d.cfg.IsPomelo() && pkt.ExtCode != 0) {
+		out["control"] = true
+		out["extCode"] = pkt.ExtCode
+		if t, ok := codec.DueHeartbeatTime(pkt); ok && d.cfg.ProtocolName() == codec.ProtocolDue {
+			out["serverTime"] = t.UnixMilli()
+		}
+		return out
+	}
+
+	route, stringRoute := pkt.Route, pkt.StringRoute
+	var md protoreflect.MessageDescriptor
+	if msg.Direction == network.TrafficSent {
+		d.remember(msg.Session, pkt)
+		md = d.cs.RequestDescriptor(route, stringRoute)
+	} else {
+		if route == 0 && stringRoute == "" {
+			if req, ok := d.lookup(msg.Session, pkt.Seq); ok {
+				route, stringRoute = req.Route, req.StringRoute
+			}
+		}
+		md = d.cs.ResponseDescriptor(route, stringRoute)
+	}
+
+	out["route"] = route
+	out["seq"] = pkt.Seq
+	if stringRoute != "" {
+		out["stringRoute"] = stringRoute
+	}
+	format := d.cs.BodyFormat(route, stringRoute)
+	if format == "" {
+		format = d.cfg.BodyFormat
+	}
+	// proto 消息体未映射消息时以十六进制和线格式结构显示, 其他格式无需消息定义
+	if md == nil && codec.BodyRequiresDescriptor(format) {
+		out["data"] = fmt.Sprintf("%x", pkt.Data)
+		if wire, err := codec.DecodeWire(pkt.Data); err == nil && len(wire) > 0 {
+			out["wire"] = wire
+		}
+		return out
+	}
+	if md != nil {
+		out["messageName"] = string(md.FullName())
+	}
+	fields, err := codec.DecodeBody(format, pkt.Data, md, d.cfg.ProtoJSON)
+	if err != nil {
+		out["error"] = err.Error()
+		out["data"] = fmt.Sprintf("%x", pkt.Data)
+		// 消息定义与数据不符时附上线格式结构, 便于对照字段号排查
+		if wire, werr := codec.DecodeWire(pkt.Data); werr == nil && len(wire) > 0 && codec.BodyRequiresDescriptor(format) {
+			out["wire"] = wire
+		}
+		return out
+	}
+	out["fields"] = fields
+	return out
+}
+
+func (d *proxyDecoder) remember(session uint64, pkt *codec.Packet) {
+	if pkt.Seq == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	seqs := d.requests[session]
+	if seqs == nil {
+		seqs = make(map[uint64]codec.Packet)
+		d.requests[session] = seqs
+	}
+	seqs[pkt.Seq] = codec.Packet{Route: pkt.Route, StringRoute: pkt.StringRoute}
+}
+
+func (d *proxyDecoder) lookup(session uint64, seq uint64) (codec.Packet, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	req, ok := d.requests[session][seq]
+	if ok {
+		delete(d.requests[session], seq)
+	}
+	return req, ok
+}
+
+// replayCanvas 将回放流程转换为前端画布格式, 节点横向排列
+func replayCanvas(nodes []engine.FlowNode, edges []engine.FlowEdge) (json.RawMessage, json.RawMessage, error) {
+	canvasNodes := make([]map[string]any, len(nodes))
+	for i, n := range nodes {
+		data := map[string]any{
+			"messageName": n.MessageName,
+			"route":       n.Route,
+			"fields":      n.Fields,
+		}
+		if n.StringRoute != "" {
+			data["stringRoute"] = n.StringRoute
+		}
+		if n.Delay > 0 {
+			data["delay"] = n.Delay
+		}
+		if len(n.Header) > 0 {
+			data["header"] = n.Header
+		}
+		canvasNodes[i] = map[string]any{
+			"id":       n.ID,
+			"type":     "requestNode",
+			"position": map[string]int{"x": i * 320, "y": 0},
+			"data":     data,
+		}
+	}
+
+	canvasEdges := make([]map[string]any, len(edges))
+	for i, e := range edges {
+		canvasEdges[i] = map[string]any{
+			"id":     fmt.Sprintf("edge_%s_%s", e.Source, e.Target),
+			"source": e.Source,
+			"target": e.Target,
+			"type":   "execEdge",
+		}
+	}
+
+	nodesJSON, err := json.Marshal(canvasNodes)
+	if err != nil {
+		return nil, nil, err
+	}
+	edgesJSON, err := json.Marshal(canvasEdges)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nodesJSON, edgesJSON, nil
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/flow-packet/server/internal/codec"
+	"github.com/flow-packet/server/internal/engine"
+)
+
+func TestReplayCanvasKeepsHeader(t *testing.T) {
+	nodes := []engine.FlowNode{
+		{ID: "replay_1", Route: 1001, Header: map[string]uint64{"uid": 42}},
+		{ID: "replay_2", Route: 1002},
+	}
+	nodesJSON, _, err := replayCanvas(nodes, nil)
+	if err != nil {
+		t.Fatalf("replayCanvas error: %v", err)
+	}
+
+	var canvas []struct {
+		Data struct {
+			Header map[string]uint64 `json:"header"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(nodesJSON, &canvas); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if len(canvas) != 2 {
+		t.Fatalf("got %d nodes, want 2", len(canvas))
+	}
+	if got := canvas[0].Data.Header["uid"]; got != 42 {
+		t.Fatalf("header uid = %d, want 42", got)
+	}
+	if canvas[1].Data.Header != nil {
+		t.Fatalf("header = %v, want none", canvas[1].Data.Header)
+	}
+}
+
+func TestMockConfigExample(t *testing.T) {
+	// 与 mockFileConfig 文档中的示例保持一致
+	example := `{
+	  "protocol": "tcp",
+	  "listen": "127.0.0.1:9000",
+	  "connectionId": "conn_1700000000_abc",
+	  "parserMode": "due",
+	  "frameFields": [{"name": "header", "bytes": 1}, {"name": "route", "bytes": 2, "isRoute": true}, {"name": "seq", "bytes": 2, "isSeq": true}],
+	  "responses": {
+	    "1001": {"fields": {"code": 0, "nickname": "mock"},
+	             "then": [{"delay": 300, "route": 2001, "message": "game.BagUpdateNotify", "fields": {"count": 1}}]},
+	    "1002": {"script": "node reply.js"}
+	  },
+	  "schedule": [{"delay": 60000, "action": "kick", "reason": "maintenance"}]
+	}`
+	var cfg mockFileConfig
+	if err := json.Unmarshal([]byte(example), &cfg); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	packetCfg, err := buildPacketConfig(cfg.frameSpec)
+	if err != nil {
+		t.Fatalf("buildPacketConfig error: %v", err)
+	}
+	if packetCfg == nil || packetCfg.ProtocolName() != codec.ProtocolDue {
+		t.Fatalf("packet config = %+v, want due", packetCfg)
+	}
+	if packetCfg.RouteBytes != 2 || packetCfg.SeqBytes != 2 {
+		t.Fatalf("route/seq bytes = %d/%d, want 2/2", packetCfg.RouteBytes, packetCfg.SeqBytes)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/flow-packet/server/internal/api"
+	"github.com/flow-packet/server/internal/codec"
+	"github.com/flow-packet/server/internal/network"
+)
+
+// mockFileConfig 模拟网关配置文件
+//
+// 示例:
+//
+//	{
+//	  "protocol": "tcp",
+//	  "listen": "127.0.0.1:9000",
+//	  "connectionId": "conn_1700000000_abc",
+//	  "parserMode": "due",
+//	  "frameFields": [{"name": "header", "bytes": 1}, {"name": "route", "bytes": 2, "isRoute": true}, {"name": "seq", "bytes": 2, "isSeq": true}],
+//	  "responses": {
+//	    "1001": {"fields": {"code": 0, "nickname": "mock"},
+//	             "then": [{"delay": 300, "route": 2001, "message": "game.BagUpdateNotify", "fields": {"count": 1}}]},
+//	    "1002": {"script": "node reply.js"}
+//	  },
+//	  "schedule": [{"delay": 60000, "action": "kick", "reason": "maintenance"}]
+//	}
+type mockFileConfig struct {
+	Protocol     string `json:"protocol"`     // tcp | ws, 默认 tcp
+	Listen       string `json:"listen"`       // 监听地址
+	DataDir      string `json:"dataDir"`      // 数据目录, 默认与桌面端一致
+	ConnectionID string `json:"connectionId"` // 使用该连接的 proto 文件和路由映射
+	frameSpec
+	Responses map[string]api.MockRule `json:"responses"` // 路由键 → 响应规则
+	Schedule  []api.MockEventRule     `json:"schedule"`  // 每个连接建立后触发的事件
+}
+
+// runMock 以模拟网关模式运行, 直到收到退出信号
+func runMock(args []string) error {
+	fs := flag.NewFlagSet("mock", flag.ContinueOnError)
+	configPath := fs.String("config", "mock.json", "mock config file")
+	listen := fs.String("listen", "", "override listen address")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(*configPath)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	var cfg mockFileConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("parse config: %w", err)
+	}
+	if *listen != "" {
+		cfg.Listen = *listen
+	}
+	if cfg.Listen == "" {
+		cfg.Listen = "127.0.0.1:9000"
+	}
+	if cfg.DataDir == "" {
+		cfg.DataDir = defaultDataDir()
+	}
+
+	packetCfg := codec.DefaultPacketConfig()
+	if built, err := buildPacketConfig(cfg.frameSpec); err != nil {
+		return err
+	} else if built != nil {
+		packetCfg = *built
+	}
+	if err := applyPayloadStages(&packetCfg, cfg.frameSpec); err != nil {
+		return err
+	}
+
+	cs := api.NewAppState(cfg.DataDir).GetConnState(cfg.ConnectionID)
+	if cs == nil {
+		return fmt.Errorf("invalid connectionId: %q", cfg.ConnectionID)
+	}
+	if cs.ParseResult == nil && codec.BodyRequiresDescriptor(packetCfg.BodyFormat) {
+		return fmt.Errorf("no proto files loaded for %s", cfg.ConnectionID)
+	}
+
+	// 启动前编码所有事件, 配置错误尽早暴露
+	schedule, err := buildMockEvents(cs, cfg.Schedule, packetCfg)
+	if err != nil {
+		return fmt.Errorf("schedule: %w", err)
+	}
+	followUps := make(map[string][]network.MockEvent)
+	for key, rule := range cfg.Responses {
+		events, err := buildMockEvents(cs, rule.Then, packetCfg)
+		if err != nil {
+			return fmt.Errorf("responses[%s].then: %w", key, err)
+		}
+		followUps[key] = events
+	}
+
+	server := network.NewMockServer(network.MockConfig{
+		Protocol:     cfg.Protocol,
+		ListenAddr:   cfg.Listen,
+		PacketConfig: packetCfg,
+	}, func(session *network.MockSession, req *codec.Packet) (*codec.Packet, []network.MockEvent, error) {
+		resp, err := cs.MockReply(req, cfg.Responses, packetCfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		key := req.StringRoute
+		if key == "" {
+			key = fmt.Sprintf("%d", req.Route)
+		}
+		return resp, followUps[key], nil
+	})
+	server.OnConnect(func(session *network.MockSession) {
+		fmt.Printf("[mock] session %d connected from %s\n", session.ID, session.Remote)
+		for _, ev := range schedule {
+			session.Schedule(ev)
+		}
+	})
+	server.OnError(func(session *network.MockSession, err error) {
+		fmt.Fprintf(os.Stderr, "[mock] session %d: %v\n", session.ID, err)
+	})
+
+	addr, err := server.Start()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[mock] listening on %s (%d routes mapped)\n", addr, len(cs.RouteMappings))
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+
+	return server.Stop()
+}
+
+// buildMockEvents 将配置中的事件转换为模拟网关事件
+func buildMockEvents(cs *api.ConnState, rules []api.MockEventRule, packetCfg codec.PacketConfig) ([]network.MockEvent, error) {
+	events := make([]network.MockEvent, 0, len(rules))
+	for i, r := range rules {
+		ev := network.MockEvent{
+			Delay:  time.Duration(r.Delay) * time.Millisecond,
+			Every:  time.Duration(r.Every) * time.Millisecond,
+			Action: network.MockAction(r.Action),
+		}
+		switch ev.Action {
+		case "", network.MockActionPush:
+			ev.Action = network.MockActionPush
+			pkt, err := cs.MockPushPacket(r, packetCfg)
+			if err != nil {
+				return nil, fmt.Errorf("event %d: %w", i, err)
+			}
+			ev.Packet = pkt
+		case network.MockActionKick:
+			payload, err := json.Marshal(map[string]string{"reason": r.Reason})
+			if err != nil {
+				return nil, err
+			}
+			ev.Payload = payload
+		case network.MockActionDisconnect:
+		default:
+			return nil, fmt.Errorf("event %d: unknown action %q", i, r.Action)
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
@@ -4,7 +4,12 @@ go 1.24.0
 
 require (
 	github.com/bufbuild/protocompile v0.14.1 // indirect
+	github.com/golang/snappy v1.0.0
 	github.com/gorilla/websocket v1.5.3 // indirect
+	github.com/klauspost/compress v1.18.0
+	github.com/pierrec/lz4/v4 v4.1.33
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	golang.org/x/sync v0.8.0 // indirect
 	google.golang.org/protobuf v1.36.11 // indirect
 )
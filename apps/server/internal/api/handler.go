@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,7 +13,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/flow-packet/server/internal/codec"
 	"github.com/flow-packet/server/internal/parser"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // ConnState 单个连接的隔离状态, 持有该连接专属的 Proto 文件、路由映射和集合数据
@@ -24,6 +27,54 @@ type ConnState struct {
 	RouteMappings  map[string]RouteMapping
 }
 
+// routeKey 返回路由在 RouteMappings 中的键, 字符串路由优先
+func routeKey(route uint64, stringRoute string) string {
+	if stringRoute != "" {
+		return stringRoute
+	}
+	return fmt.Sprintf("%d", route)
+}
+
+// MessageDescriptor 根据全限定名查找已加载的消息描述符, 未加载 proto 时返回 nil
+func (cs *ConnState) MessageDescriptor(fullName string) protoreflect.MessageDescriptor {
+	if cs == nil || cs.ParseResult == nil {
+		return nil
+	}
+	return cs.ParseResult.FindMessageDescriptor(fullName)
+}
+
+// RequestDescriptor 根据路由映射查找请求消息描述符, 未映射或未加载 proto 时返回 nil
+func (cs *ConnState) RequestDescriptor(route uint64, stringRoute string) protoreflect.MessageDescriptor {
+	if cs == nil || cs.ParseResult == nil {
+		return nil
+	}
+	mapping, ok := cs.RouteMappings[routeKey(route, stringRoute)]
+	if !ok {
+		return nil
+	}
+	return cs.ParseResult.FindMessageDescriptor(mapping.RequestMsg)
+}
+
+// ResponseDescriptor 根据路由映射查找响应消息描述符, 未映射或未加载 proto 时返回 nil
+func (cs *ConnState) ResponseDescriptor(route uint64, stringRoute string) protoreflect.MessageDescriptor {
+	if cs == nil || cs.ParseResult == nil {
+		return nil
+	}
+	mapping, ok := cs.RouteMappings[routeKey(route, stringRoute)]
+	if !ok {
+		return nil
+	}
+	return cs.ParseResult.FindMessageDescriptor(mapping.ResponseMsg)
+}
+
+// BodyFormat 返回路由映射设置的消息体格式, 未映射或未设置时返回空字符串
+func (cs *ConnState) BodyFormat(route uint64, stringRoute string) string {
+	if cs == nil {
+		return ""
+	}
+	return cs.RouteMappings[routeKey(route, stringRoute)].BodyFormat
+}
+
 // AppState 应用状态, 在各 handler 间共享
 type AppState struct {
 	DataDir      string // 数据根目录
@@ -96,6 +147,16 @@ type FrameField struct {
 	Bytes   int    `json:"bytes"`
 	IsRoute bool   `json:"isRoute,omitempty"`
 	IsSeq   bool   `json:"isSeq,omitempty"`
+
+	Role          string `json:"role,omitempty"`          // const | checksum | timestamp
+	Value         uint64 `json:"value,omitempty"`         // const 字段的固定值
+	Checksum      string `json:"checksum,omitempty"`      // crc16 | crc32 | adler32 | xor
+	ChecksumScope string `json:"checksumScope,omitempty"` // frame | header | body
+	TimestampUnit string `json:"timestampUnit,omitempty"` // s | ms
+	MaxSkew       uint64 `json:"maxSkew,omitempty"`       // timestamp 字段允许的最大偏差, 0 表示不校验
+	Trailer       bool   `json:"trailer,omitempty"`       // 位于 body 之后
+	LengthField   string `json:"lengthField,omitempty"`   // 变长字段的长度字段名
+	Varint        bool   `json:"varint,omitempty"`        // protobuf 风格 varint 编码
 }
 
 // FrameTemplate 自定义协议帧模板
@@ -108,18 +169,17 @@ type FrameTemplate struct {
 
 // RouteMapping route 值到 message 名称的映射
 type RouteMapping struct {
-	Route       uint32 `json:"route"`
+	Route       uint64 `json:"route"`
 	StringRoute string `json:"stringRoute,omitempty"`
 	RequestMsg  string `json:"requestMsg"`
 	ResponseMsg string `json:"responseMsg"`
+	// BodyFormat 该路由的消息体格式(见 codec.Body* 常量), 为空时沿用连接的设置
+	BodyFormat string `json:"bodyFormat,omitempty"`
 }
 
 // Key 返回路由映射的唯一标识, 字符串路由优先
 func (rm RouteMapping) Key() string {
-	if rm.StringRoute != "" {
-		return rm.StringRoute
-	}
-	return fmt.Sprintf("%d", rm.Route)
+	return routeKey(rm.Route, rm.StringRoute)
 }
 
 // NewAppState 创建应用状态
@@ -147,6 +207,7 @@ func RegisterHandlers(srv *Server, state *AppState) {
 	srv.Handle("route.list", makeRouteListHandler(state))
 	srv.Handle("route.set", makeRouteSetHandler(state))
 	srv.Handle("route.delete", makeRouteDeleteHandler(state))
+	srv.Handle("route.guess", makeRouteGuessHandler(state))
 	srv.Handle("template.list", makeTemplateListHandler(state))
 	srv.Handle("template.save", makeTemplateSaveHandler(state))
 	srv.Handle("template.delete", makeTemplateDeleteHandler(state))
@@ -336,6 +397,9 @@ func makeRouteSetHandler(state *AppState) HandlerFunc {
 		if req.ConnectionID == "" {
 			return nil, fmt.Errorf("connectionId is required")
 		}
+		if !codec.ValidBodyFormat(req.BodyFormat) {
+			return nil, fmt.Errorf("invalid body format: %q", req.BodyFormat)
+		}
 
 		cs := state.GetConnState(req.ConnectionID)
 		if cs == nil {
@@ -355,7 +419,7 @@ func makeRouteDeleteHandler(state *AppState) HandlerFunc {
 	return func(payload json.RawMessage) (any, error) {
 		var req struct {
 			ConnectionID string `json:"connectionId"`
-			Route        uint32 `json:"route"`
+			Route        uint64 `json:"route"`
 			StringRoute  string `json:"stringRoute"`
 		}
 		if err := json.Unmarshal(payload, &req); err != nil {
@@ -370,11 +434,7 @@ func makeRouteDeleteHandler(state *AppState) HandlerFunc {
 			return nil, fmt.Errorf("invalid connectionId")
 		}
 
-		key := req.StringRoute
-		if key == "" {
-			key = fmt.Sprintf("%d", req.Route)
-		}
-		delete(cs.RouteMappings, key)
+		delete(cs.RouteMappings, routeKey(req.Route, req.StringRoute))
 		if err := writeRouteMappings(cs.RouteFile, cs.RouteMappings); err != nil {
 			return nil, fmt.Errorf("failed to save routes: %w", err)
 		}
@@ -382,6 +442,57 @@ func makeRouteDeleteHandler(state *AppState) HandlerFunc {
 	}
 }
 
+// defaultGuessLimit route.guess 默认返回的候选数
+const defaultGuessLimit = 5
+
+// makeRouteGuessHandler 创建 route.guess 处理函数
+//
+// 用连接加载的全部消息试解码未映射路由的响应消息体(十六进制), 返回按可信度排序的候选;
+// 前端选定后通过 route.set 保存为路由映射
+func makeRouteGuessHandler(state *AppState) HandlerFunc {
+	return func(payload json.RawMessage) (any, error) {
+		var req struct {
+			ConnectionID string `json:"connectionId"`
+			Data         string `json:"data"`
+			Limit        int    `json:"limit"`
+		}
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		if req.ConnectionID == "" {
+			return nil, fmt.Errorf("connectionId is required")
+		}
+		data, err := hex.DecodeString(req.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data: %w", err)
+		}
+		if req.Limit <= 0 {
+			req.Limit = defaultGuessLimit
+		}
+
+		cs := state.GetConnState(req.ConnectionID)
+		if cs == nil {
+			return nil, fmt.Errorf("invalid connectionId")
+		}
+		if cs.ParseResult == nil {
+			return nil, fmt.Errorf("no proto files loaded")
+		}
+
+		var candidates []protoreflect.MessageDescriptor
+		for _, m := range cs.ParseResult.AllMessages() {
+			if md := cs.ParseResult.FindMessageDescriptor(m.Name); md != nil {
+				candidates = append(candidates, md)
+			}
+		}
+		opts := codec.ProtoJSONOptions{Resolver: cs.MessageDescriptor}
+		guesses := codec.GuessMessage(data, candidates, req.Limit, opts)
+		if guesses == nil {
+			guesses = []codec.MessageGuess{}
+		}
+		return map[string]any{"candidates": guesses}, nil
+	}
+}
+
 // readRouteMappings 从文件读取路由映射列表, 文件不存在时返回空列表
 func readRouteMappings(path string) ([]RouteMapping, error) {
 	data, err := os.ReadFile(path)
@@ -637,28 +748,48 @@ func makeCollectionSaveHandler(state *AppState) HandlerFunc {
 			return nil, fmt.Errorf("name is required")
 		}
 
-		col, err := readCollections(colFile)
+		item, err := saveCollectionItem(colFile, req.Name, req.FolderID, req.Nodes, req.Edges)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read collections: %w", err)
+			return nil, err
 		}
+		return map[string]any{"item": item}, nil
+	}
+}
 
-		now := time.Now().UnixMilli()
-		item := CollectionItem{
-			ID:        fmt.Sprintf("col_%d", now),
-			Name:      req.Name,
-			FolderID:  req.FolderID,
-			Nodes:     req.Nodes,
-			Edges:     req.Edges,
-			CreatedAt: now,
-			UpdatedAt: now,
-		}
-		col.Items = append(col.Items, item)
+// saveCollectionItem 向集合文件追加一个画布
+func saveCollectionItem(colFile, name, folderID string, nodes, edges json.RawMessage) (CollectionItem, error) {
+	col, err := readCollections(colFile)
+	if err != nil {
+		return CollectionItem{}, fmt.Errorf("failed to read collections: %w", err)
+	}
 
-		if err := writeCollections(colFile, col); err != nil {
-			return nil, fmt.Errorf("failed to save collections: %w", err)
-		}
-		return map[string]any{"item": item}, nil
+	now := time.Now().UnixMilli()
+	item := CollectionItem{
+		ID:        fmt.Sprintf("col_%d", now),
+		Name:      name,
+		FolderID:  folderID,
+		Nodes:     nodes,
+		Edges:     edges,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	col.Items = append(col.Items, item)
+
+	if err := writeCollections(colFile, col); err != nil {
+		return CollectionItem{}, fmt.Errorf("failed to save collections: %w", err)
+	}
+	return item, nil
+}
+
+// SaveCollection 将画布保存到连接的集合中, 供非前端来源(如流量回放)生成画布
+//
+// 参数：
+//   - nodes, edges: 前端画布格式的节点与边
+func (cs *ConnState) SaveCollection(name, folderID string, nodes, edges json.RawMessage) (CollectionItem, error) {
+	if name == "" {
+		return CollectionItem{}, fmt.Errorf("name is required")
 	}
+	return saveCollectionItem(cs.CollectionFile, name, folderID, nodes, edges)
 }
 
 // makeCollectionUpdateHandler 创建 collection.update 处理函数, 更新已有集合的画布数据
@@ -239,3 +239,47 @@ func TestRouteSetInvalid(t *testing.T) {
 		t.Fatalf("event = %q, want %q", resp.Event, "error")
 	}
 }
+
+func TestRouteGuess(t *testing.T) {
+	_, _, port := setupTestServer(t)
+	connID := "conn_1_abc"
+
+	body, contentType := createMultipartBody(t, map[string]string{
+		"test.proto": `syntax = "proto3";
+package test;
+message Ping { int64 timestamp = 1; }
+message Pong { int64 timestamp = 1; string message = 2; }
+`,
+	})
+	resp, err := http.Post(fmt.Sprintf("http://127.0.0.1:%d/api/proto/upload?connectionId=%s", port, connID), contentType, body)
+	if err != nil {
+		t.Fatalf("POST error: %v", err)
+	}
+	resp.Body.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://127.0.0.1:%d/ws", port), nil)
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	defer ws.Close()
+
+	// {timestamp: 1, message: "ok"} 只能按 Pong 完整解码
+	guess := wsRequest(t, ws, "1", "route.guess", map[string]any{"connectionId": connID, "data": "080112026f6b"})
+	if guess.Event != "route.guess" {
+		t.Fatalf("event = %q, payload = %v", guess.Event, guess.Payload)
+	}
+	payload, _ := json.Marshal(guess.Payload)
+	var result struct {
+		Candidates []struct {
+			Message string `json:"message"`
+		} `json:"candidates"`
+	}
+	json.Unmarshal(payload, &result)
+	if len(result.Candidates) == 0 || result.Candidates[0].Message != "test.Pong" {
+		t.Fatalf("candidates = %s", payload)
+	}
+
+	if resp := wsRequest(t, ws, "2", "route.guess", map[string]any{"connectionId": connID, "data": "zz"}); resp.Event != "error" {
+		t.Fatalf("event = %q, want error for invalid hex", resp.Event)
+	}
+}
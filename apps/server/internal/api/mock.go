@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/flow-packet/server/internal/codec"
+)
+
+// mockScriptTimeout 单次脚本执行超时
+const mockScriptTimeout = 5 * time.Second
+
+// MockRule 单个路由的模拟响应规则
+type MockRule struct {
+	Fields  map[string]any  `json:"fields,omitempty"`  // 静态响应字段, 按路由的消息体格式(proto 时为映射的响应消息)编码
+	Script  string          `json:"script,omitempty"`  // 外部命令, 从 stdin 读取请求 JSON, 向 stdout 输出响应字段 JSON
+	NoReply bool            `json:"noReply,omitempty"` // 不回复该路由
+	Then    []MockEventRule `json:"then,omitempty"`    // 响应后触发的事件
+}
+
+// MockEventRule 模拟网关的推送/踢下线/断开事件
+//
+// 作为路由规则的 then 时从响应发送后开始计时, 作为全局 schedule 时从连接建立开始计时
+type MockEventRule struct {
+	Action      string         `json:"action"`                // push | kick | disconnect, 默认 push
+	Delay       int64          `json:"delay,omitempty"`       // 等待时间(毫秒)
+	Every       int64          `json:"every,omitempty"`       // 重复间隔(毫秒), 仅 push 生效, 0 表示只触发一次
+	Route       uint64         `json:"route,omitempty"`       // 推送路由
+	StringRoute string         `json:"stringRoute,omitempty"` // 推送字符串路由(Pomelo)
+	Message     string         `json:"message,omitempty"`     // 推送消息全名, 为空时使用路由映射的响应消息
+	Fields      map[string]any `json:"fields,omitempty"`      // 推送字段
+	Reason      string         `json:"reason,omitempty"`      // kick 原因
+}
+
+// mockScriptInput 传给脚本的请求信息
+type mockScriptInput struct {
+	Route       uint64         `json:"route"`
+	StringRoute string         `json:"stringRoute,omitempty"`
+	Seq         uint64         `json:"seq"`
+	Request     map[string]any `json:"request"`
+}
+
+// bodyCodec 返回路由使用的消息体格式和 proto 字段转换选项, 规则与 engine.Runner 一致:
+// 路由映射设置的格式优先, 其次为 cfg.BodyFormat; 未指定 Any 类型解析器时使用已加载的消息
+func (cs *ConnState) bodyCodec(route uint64, stringRoute string, cfg codec.PacketConfig) (string, codec.ProtoJSONOptions) {
+	format := cs.BodyFormat(route, stringRoute)
+	if format == "" {
+		format = cfg.BodyFormat
+	}
+	opts := cfg.ProtoJSON
+	if opts.Resolver == nil {
+		opts.Resolver = cs.MessageDescriptor
+	}
+	return format, opts
+}
+
+// MockReply 根据路由映射和模拟规则生成请求的响应包
+//
+// 未配置规则的已映射路由回复空的响应消息; 响应包的 seq 与请求一致
+//
+// 参数：
+//   - req: 已解码的请求包
+//   - rules: 路由键(数字路由或字符串路由) → 模拟规则
+//   - cfg: 模拟网关的协议帧配置, 提供默认消息体格式和 proto 字段转换选项
+//
+// 返回值：
+//   - *codec.Packet: 响应包, 规则为 NoReply 时返回 nil
+//   - error: proto 路由未映射响应消息、脚本执行失败或字段编码失败时返回错误
+func (cs *ConnState) MockReply(req *codec.Packet, rules map[string]MockRule, cfg codec.PacketConfig) (*codec.Packet, error) {
+	key := routeKey(req.Route, req.StringRoute)
+	rule := rules[key]
+	if rule.NoReply {
+		return nil, nil
+	}
+
+	format, opts := cs.bodyCodec(req.Route, req.StringRoute, cfg)
+	md := cs.ResponseDescriptor(req.Route, req.StringRoute)
+	if md == nil && codec.BodyRequiresDescriptor(format) {
+		return nil, fmt.Errorf("no response message mapped for route %s", key)
+	}
+
+	fields := rule.Fields
+	if rule.Script != "" {
+		input := mockScriptInput{Route: req.Route, StringRoute: req.StringRoute, Seq: req.Seq}
+		if reqMD := cs.RequestDescriptor(req.Route, req.StringRoute); reqMD != nil || !codec.BodyRequiresDescriptor(format) {
+			decoded, err := codec.DecodeBody(format, req.Data, reqMD, opts)
+			if err != nil {
+				return nil, fmt.Errorf("decode request for route %s: %w", key, err)
+			}
+			input.Request = decoded
+		}
+		out, err := runMockScript(rule.Script, input)
+		if err != nil {
+			return nil, err
+		}
+		fields = out
+	}
+
+	data, err := codec.EncodeBody(format, md, fields, opts)
+	if err != nil {
+		return nil, fmt.Errorf("encode response for route %s: %w", key, err)
+	}
+	return &codec.Packet{Route: req.Route, StringRoute: req.StringRoute, Seq: req.Seq, Data: data}, nil
+}
+
+// runMockScript 执行响应脚本, 命令按空白分割, 不经过 shell
+func runMockScript(script string, input mockScriptInput) (map[string]any, error) {
+	args := strings.Fields(script)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty script")
+	}
+	stdin, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mockScriptTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("run script %q: %w: %s", script, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &fields); err != nil {
+		return nil, fmt.Errorf("parse script output: %w", err)
+	}
+	return fields, nil
+}
+
+// MockPushPacket 将推送事件编码为数据包, 消息体格式规则同 MockReply
+//
+// proto 消息类型优先使用 ev.Message, 为空时使用推送路由映射的响应消息
+func (cs *ConnState) MockPushPacket(ev MockEventRule, cfg codec.PacketConfig) (*codec.Packet, error) {
+	key := routeKey(ev.Route, ev.StringRoute)
+	format, opts := cs.bodyCodec(ev.Route, ev.StringRoute, cfg)
+	md := cs.ResponseDescriptor(ev.Route, ev.StringRoute)
+	if ev.Message != "" {
+		if cs == nil || cs.ParseResult == nil {
+			return nil, fmt.Errorf("no proto files loaded")
+		}
+		md = cs.ParseResult.FindMessageDescriptor(ev.Message)
+		if md == nil {
+			return nil, fmt.Errorf("message %s not found", ev.Message)
+		}
+	}
+	if md == nil && codec.BodyRequiresDescriptor(format) {
+		return nil, fmt.Errorf("no message for push route %s", key)
+	}
+
+	data, err := codec.EncodeBody(format, md, ev.Fields, opts)
+	if err != nil {
+		return nil, fmt.Errorf("encode push for route %s: %w", key, err)
+	}
+	return &codec.Packet{Route: ev.Route, StringRoute: ev.StringRoute, Data: data}, nil
+}
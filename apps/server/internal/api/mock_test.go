@@ -0,0 +1,141 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flow-packet/server/internal/codec"
+	"github.com/flow-packet/server/internal/parser"
+)
+
+// newMockConnState 创建带登录路由映射的连接状态
+func newMockConnState(t *testing.T) *ConnState {
+	t.Helper()
+	dir := t.TempDir()
+	proto := `syntax = "proto3";
+package game;
+message LoginReq { string account = 1; }
+message LoginResp { int32 code = 1; string nickname = 2; }`
+	if err := os.WriteFile(filepath.Join(dir, "login.proto"), []byte(proto), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := parser.ParseProtoDir(dir)
+	if err != nil {
+		t.Fatalf("ParseProtoDir error: %v", err)
+	}
+	return &ConnState{
+		ParseResult: result,
+		RouteMappings: map[string]RouteMapping{
+			"1001": {Route: 1001, RequestMsg: "game.LoginReq", ResponseMsg: "game.LoginResp"},
+		},
+	}
+}
+
+func TestMockReplyStaticFields(t *testing.T) {
+	cs := newMockConnState(t)
+	rules := map[string]MockRule{
+		"1001": {Fields: map[string]any{"code": 0, "nickname": "mock"}},
+	}
+
+	resp, err := cs.MockReply(&codec.Packet{Route: 1001, Seq: 7}, rules, codec.PacketConfig{})
+	if err != nil {
+		t.Fatalf("MockReply error: %v", err)
+	}
+	if resp.Route != 1001 || resp.Seq != 7 {
+		t.Fatalf("resp route/seq = %d/%d, want 1001/7", resp.Route, resp.Seq)
+	}
+	fields, err := codec.DynamicDecode(resp.Data, cs.ResponseDescriptor(1001, ""))
+	if err != nil {
+		t.Fatalf("DynamicDecode error: %v", err)
+	}
+	if fields["nickname"] != "mock" {
+		t.Fatalf("fields = %v, want nickname=mock", fields)
+	}
+
+	// 未配置规则的已映射路由回复空消息
+	empty, err := cs.MockReply(&codec.Packet{Route: 1001, Seq: 8}, nil, codec.PacketConfig{})
+	if err != nil || empty == nil || len(empty.Data) != 0 {
+		t.Fatalf("default reply = %+v, %v", empty, err)
+	}
+}
+
+func TestMockReplyRules(t *testing.T) {
+	cs := newMockConnState(t)
+
+	resp, err := cs.MockReply(&codec.Packet{Route: 1001}, map[string]MockRule{"1001": {NoReply: true}}, codec.PacketConfig{})
+	if err != nil || resp != nil {
+		t.Fatalf("NoReply = %+v, %v; want nil, nil", resp, err)
+	}
+
+	if _, err := cs.MockReply(&codec.Packet{Route: 2002}, nil, codec.PacketConfig{}); err == nil {
+		t.Fatal("expected error for unmapped route")
+	}
+}
+
+func TestMockPushPacket(t *testing.T) {
+	cs := newMockConnState(t)
+
+	pkt, err := cs.MockPushPacket(MockEventRule{Route: 2001, Message: "game.LoginResp", Fields: map[string]any{"code": 5}}, codec.PacketConfig{})
+	if err != nil {
+		t.Fatalf("MockPushPacket error: %v", err)
+	}
+	if pkt.Route != 2001 || pkt.Seq != 0 {
+		t.Fatalf("push route/seq = %d/%d, want 2001/0", pkt.Route, pkt.Seq)
+	}
+
+	// 未指定消息时使用路由映射的响应消息
+	if _, err := cs.MockPushPacket(MockEventRule{Route: 1001}, codec.PacketConfig{}); err != nil {
+		t.Fatalf("MockPushPacket by mapping error: %v", err)
+	}
+	if _, err := cs.MockPushPacket(MockEventRule{Route: 2001}, codec.PacketConfig{}); err == nil {
+		t.Fatal("expected error for push without message")
+	}
+}
+
+func TestMockReplyJSONRoute(t *testing.T) {
+	cs := newMockConnState(t)
+	cs.RouteMappings["connector.entry"] = RouteMapping{BodyFormat: codec.BodyJSON}
+	rules := map[string]MockRule{
+		"connector.entry": {Fields: map[string]any{"code": 200}},
+	}
+
+	// 路由映射为 JSON 时不需要响应消息
+	resp, err := cs.MockReply(&codec.Packet{StringRoute: "connector.entry", Seq: 3}, rules, codec.PacketConfig{})
+	if err != nil {
+		t.Fatalf("MockReply error: %v", err)
+	}
+	if string(resp.Data) != `{"code":200}` {
+		t.Fatalf("resp body = %s", resp.Data)
+	}
+
+	// 连接默认 JSON 时未映射的推送路由同样按 JSON 编码
+	pkt, err := cs.MockPushPacket(MockEventRule{StringRoute: "onChat", Fields: map[string]any{"msg": "hi"}}, codec.PacketConfig{BodyFormat: codec.BodyJSON})
+	if err != nil {
+		t.Fatalf("MockPushPacket error: %v", err)
+	}
+	if string(pkt.Data) != `{"msg":"hi"}` {
+		t.Fatalf("push body = %s", pkt.Data)
+	}
+}
+
+func TestMockReplyMsgpackRoute(t *testing.T) {
+	cs := newMockConnState(t)
+	cs.RouteMappings["3001"] = RouteMapping{Route: 3001, BodyFormat: codec.BodyMsgpack}
+	rules := map[string]MockRule{
+		"3001": {Fields: map[string]any{"gold": 100}},
+	}
+
+	resp, err := cs.MockReply(&codec.Packet{Route: 3001, Seq: 4}, rules, codec.PacketConfig{})
+	if err != nil {
+		t.Fatalf("MockReply error: %v", err)
+	}
+	fields, err := codec.DecodeBody(codec.BodyMsgpack, resp.Data, nil, codec.ProtoJSONOptions{})
+	if err != nil {
+		t.Fatalf("DecodeBody error: %v", err)
+	}
+	if fmt.Sprint(fields["gold"]) != "100" {
+		t.Fatalf("fields = %v, want gold=100", fields)
+	}
+}
@@ -0,0 +1,191 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// 消息体格式, 可按连接(PacketConfig.BodyFormat)或按路由映射设置
+const (
+	BodyProto   = "proto"   // Protobuf, 需要消息描述符(默认)
+	BodyJSON    = "json"    // JSON 对象, 消息描述符可选, 仅用于显示消息名
+	BodyMsgpack = "msgpack" // MessagePack, 字段值与 JSON 相同
+	BodyHex     = "hex"     // 原始字节, 字段 _hex 为十六进制字符串
+	BodyUTF8    = "utf8"    // UTF-8 文本, 字段 _text 为消息体内容
+)
+
+// 非结构化消息体在字段值中使用的键
+const (
+	bodyValueKey = "_value" // JSON/MessagePack 消息体顶层不是对象时保存其值
+	bodyHexKey   = "_hex"   // 原始字节的十六进制, 与 DynamicDecode 未映射消息时一致
+	bodyTextKey  = "_text"  // UTF-8 文本
+)
+
+// ValidBodyFormat 返回是否为已知的消息体格式, 空字符串视为默认格式
+func ValidBodyFormat(format string) bool {
+	switch format {
+	case "", BodyProto, BodyJSON, BodyMsgpack, BodyHex, BodyUTF8:
+		return true
+	}
+	return false
+}
+
+// BodyRequiresDescriptor 返回该消息体格式是否必须有消息描述符
+func BodyRequiresDescriptor(format string) bool {
+	return format == "" || format == BodyProto
+}
+
+// EncodeBody 按消息体格式将字段值编码为消息体
+//
+// 参数：
+//   - format: 消息体格式, 见 Body* 常量, 为空时为 BodyProto
+//   - md: 消息描述符, BodyProto 时必须非 nil
+//   - fields: 字段值, BodyJSON/BodyMsgpack 时原样序列化为对象, BodyHex 取 _hex, BodyUTF8 取 _text
+//   - opts: BodyProto 时字段值的转换选项
+//
+// 返回值：
+//   - []byte: 编码后的消息体
+//   - error: 格式未知、缺少消息描述符或字段无法编码时返回错误
+func EncodeBody(format string, md protoreflect.MessageDescriptor, fields map[string]any, opts ProtoJSONOptions) ([]byte, error) {
+	switch format {
+	case "", BodyProto:
+		if md == nil {
+			return nil, fmt.Errorf("proto body requires a message descriptor")
+		}
+		return DynamicEncodeWith(md, fields, opts)
+	case BodyJSON:
+		if fields == nil {
+			fields = map[string]any{}
+		}
+		return json.Marshal(fields)
+	case BodyMsgpack:
+		if fields == nil {
+			fields = map[string]any{}
+		}
+		return msgpack.Marshal(msgpackValue(fields))
+	case BodyHex:
+		s, _ := fields[bodyHexKey].(string)
+		data, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("hex body: %w", err)
+		}
+		return data, nil
+	case BodyUTF8:
+		s, _ := fields[bodyTextKey].(string)
+		return []byte(s), nil
+	default:
+		return nil, fmt.Errorf("unknown body format %q", format)
+	}
+}
+
+// DecodeBody 按消息体格式将消息体解码为 JSON 友好的 map
+//
+// BodyProto 时行为同 DynamicDecodeWith(md 为 nil 时返回 _hex); BodyJSON 时数字保留为 json.Number
+// 避免 64 位整数丢失精度; BodyMsgpack 的非字符串键转为字符串, bin 转为十六进制.
+// JSON/MessagePack 顶层不是对象的值保存在 _value 中, 空消息体返回空 map
+func DecodeBody(format string, data []byte, md protoreflect.MessageDescriptor, opts ProtoJSONOptions) (map[string]any, error) {
+	switch format {
+	case "", BodyProto:
+		return DynamicDecodeWith(data, md, opts)
+	case BodyJSON:
+		if len(bytes.TrimSpace(data)) == 0 {
+			return map[string]any{}, nil
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("unmarshal json: %w", err)
+		}
+		if obj, ok := v.(map[string]any); ok {
+			return obj, nil
+		}
+		return map[string]any{bodyValueKey: v}, nil
+	case BodyMsgpack:
+		if len(data) == 0 {
+			return map[string]any{}, nil
+		}
+		dec := msgpack.NewDecoder(bytes.NewReader(data))
+		dec.SetMapDecoder(func(d *msgpack.Decoder) (any, error) { return d.DecodeUntypedMap() })
+		v, err := dec.DecodeInterface()
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal msgpack: %w", err)
+		}
+		if obj, ok := jsonValue(v).(map[string]any); ok {
+			return obj, nil
+		}
+		return map[string]any{bodyValueKey: jsonValue(v)}, nil
+	case BodyHex:
+		return map[string]any{bodyHexKey: hex.EncodeToString(data)}, nil
+	case BodyUTF8:
+		if !utf8.Valid(data) {
+			return nil, fmt.Errorf("body is not valid UTF-8")
+		}
+		return map[string]any{bodyTextKey: string(data)}, nil
+	default:
+		return nil, fmt.Errorf("unknown body format %q", format)
+	}
+}
+
+// msgpackValue 将 JSON 解码得到的值转换为 MessagePack 编码值: 整数值的 float64 和 json.Number 转为 int64,
+// 使其编码为 MessagePack 整数而不是 float64
+func msgpackValue(v any) any {
+	switch x := v.(type) {
+	case float64:
+		if x == math.Trunc(x) && x >= math.MinInt64 && x < math.MaxInt64 {
+			return int64(x)
+		}
+		return x
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return n
+		}
+		if f, err := x.Float64(); err == nil {
+			return f
+		}
+		return x.String()
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, e := range x {
+			out[k] = msgpackValue(e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = msgpackValue(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// jsonValue 将 MessagePack 解码得到的值转换为 JSON 友好的值
+func jsonValue(v any) any {
+	switch x := v.(type) {
+	case map[any]any:
+		out := make(map[string]any, len(x))
+		for k, e := range x {
+			out[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = jsonValue(e)
+		}
+		return out
+	case []byte:
+		return hex.EncodeToString(x)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,118 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestJSONBody(t *testing.T) {
+	body, err := EncodeBody(BodyJSON, nil, map[string]any{"account": "alice", "zone": 3}, ProtoJSONOptions{})
+	if err != nil {
+		t.Fatalf("EncodeBody error: %v", err)
+	}
+	if string(body) != `{"account":"alice","zone":3}` {
+		t.Fatalf("body = %s", body)
+	}
+
+	// 64 位整数不经过 float64, 保留精度
+	fields, err := DecodeBody(BodyJSON, []byte(`{"uid":9007199254740993,"ok":true}`), nil, ProtoJSONOptions{})
+	if err != nil {
+		t.Fatalf("DecodeBody error: %v", err)
+	}
+	if fields["uid"] != json.Number("9007199254740993") || fields["ok"] != true {
+		t.Fatalf("fields = %v", fields)
+	}
+
+	fields, err = DecodeBody(BodyJSON, []byte(`[1,2]`), nil, ProtoJSONOptions{})
+	if err != nil {
+		t.Fatalf("DecodeBody error: %v", err)
+	}
+	if list, ok := fields[bodyValueKey].([]any); !ok || len(list) != 2 {
+		t.Fatalf("fields = %v, want _value list", fields)
+	}
+
+	if fields, err := DecodeBody(BodyJSON, nil, nil, ProtoJSONOptions{}); err != nil || len(fields) != 0 {
+		t.Fatalf("empty body = %v, %v", fields, err)
+	}
+	if _, err := DecodeBody(BodyJSON, []byte(`{"a":`), nil, ProtoJSONOptions{}); err == nil {
+		t.Fatal("expected error for truncated json")
+	}
+}
+
+func TestBodyFormatErrors(t *testing.T) {
+	if _, err := EncodeBody(BodyProto, nil, nil, ProtoJSONOptions{}); err == nil {
+		t.Fatal("expected error for proto body without descriptor")
+	}
+	if _, err := EncodeBody("xml", nil, nil, ProtoJSONOptions{}); err == nil || ValidBodyFormat("xml") {
+		t.Fatal("expected error for unknown format")
+	}
+	// proto 格式未映射消息时与 DynamicDecode 一致返回十六进制
+	fields, err := DecodeBody("", []byte{0x08, 0x01}, nil, ProtoJSONOptions{})
+	if err != nil || fields["_hex"] != "0801" {
+		t.Fatalf("fields = %v, %v", fields, err)
+	}
+}
+
+func TestMsgpackBody(t *testing.T) {
+	// JSON 解析得到的整数值 float64 编码为 MessagePack 整数
+	body, err := EncodeBody(BodyMsgpack, nil, map[string]any{"id": float64(300), "rate": 1.5}, ProtoJSONOptions{})
+	if err != nil {
+		t.Fatalf("EncodeBody error: %v", err)
+	}
+	fields, err := DecodeBody(BodyMsgpack, body, nil, ProtoJSONOptions{})
+	if err != nil {
+		t.Fatalf("DecodeBody error: %v", err)
+	}
+	if fmt.Sprint(fields["id"]) != "300" || fields["rate"] != 1.5 {
+		t.Fatalf("fields = %#v", fields)
+	}
+	if _, ok := fields["id"].(float64); ok {
+		t.Fatal("id encoded as float, want msgpack integer")
+	}
+
+	// {1: bin(ab cd)}: 非字符串键转为字符串, bin 转为十六进制
+	fields, err = DecodeBody(BodyMsgpack, []byte{0x81, 0x01, 0xc4, 0x02, 0xab, 0xcd}, nil, ProtoJSONOptions{})
+	if err != nil {
+		t.Fatalf("DecodeBody error: %v", err)
+	}
+	if fields["1"] != "abcd" {
+		t.Fatalf("fields = %#v", fields)
+	}
+
+	fields, err = DecodeBody(BodyMsgpack, []byte{0x92, 0x01, 0x02}, nil, ProtoJSONOptions{})
+	if err != nil {
+		t.Fatalf("DecodeBody error: %v", err)
+	}
+	if list, ok := fields[bodyValueKey].([]any); !ok || len(list) != 2 {
+		t.Fatalf("fields = %v, want _value list", fields)
+	}
+	if _, err := DecodeBody(BodyMsgpack, []byte{0x81, 0x01}, nil, ProtoJSONOptions{}); err == nil {
+		t.Fatal("expected error for truncated msgpack")
+	}
+}
+
+func TestHexAndUTF8Body(t *testing.T) {
+	body, err := EncodeBody(BodyHex, nil, map[string]any{"_hex": "0a0B"}, ProtoJSONOptions{})
+	if err != nil || !bytes.Equal(body, []byte{0x0a, 0x0b}) {
+		t.Fatalf("hex body = % x, %v", body, err)
+	}
+	if _, err := EncodeBody(BodyHex, nil, map[string]any{"_hex": "zz"}, ProtoJSONOptions{}); err == nil {
+		t.Fatal("expected error for invalid hex")
+	}
+	if fields, err := DecodeBody(BodyHex, body, nil, ProtoJSONOptions{}); err != nil || fields["_hex"] != "0a0b" {
+		t.Fatalf("fields = %v, %v", fields, err)
+	}
+
+	body, err = EncodeBody(BodyUTF8, nil, map[string]any{"_text": "你好"}, ProtoJSONOptions{})
+	if err != nil || string(body) != "你好" {
+		t.Fatalf("utf8 body = %q, %v", body, err)
+	}
+	if fields, err := DecodeBody(BodyUTF8, body, nil, ProtoJSONOptions{}); err != nil || fields["_text"] != "你好" {
+		t.Fatalf("fields = %v, %v", fields, err)
+	}
+	if _, err := DecodeBody(BodyUTF8, []byte{0xff, 0xfe}, nil, ProtoJSONOptions{}); err == nil {
+		t.Fatal("expected error for invalid UTF-8")
+	}
+}
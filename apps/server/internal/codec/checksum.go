@@ -0,0 +1,109 @@
+package codec
+
+import (
+	"fmt"
+	"hash/adler32"
+	"hash/crc32"
+)
+
+// 校验和算法
+const (
+	ChecksumCRC16   = "crc16"   // CRC-16/CCITT-FALSE(多项式 0x1021, 初始值 0xFFFF)
+	ChecksumCRC32   = "crc32"   // CRC-32/IEEE
+	ChecksumAdler32 = "adler32" // Adler-32
+	ChecksumXOR     = "xor"     // 逐字节异或
+)
+
+// 校验和覆盖范围, 校验和字段自身的字节始终不参与计算
+const (
+	ChecksumScopeFrame  = "frame"  // header + body(默认)
+	ChecksumScopeHeader = "header" // 仅 header
+	ChecksumScopeBody   = "body"   // 仅 body
+)
+
+// ErrChecksumMismatch 校验和不一致, 属于协议错误
+var ErrChecksumMismatch = fmt.Errorf("%w: checksum mismatch", ErrInvalidFrame)
+
+// ErrConstMismatch 固定值字段(魔数、版本号)不一致, 属于协议错误
+var ErrConstMismatch = fmt.Errorf("%w: constant mismatch", ErrInvalidFrame)
+
+// ErrTimestampSkew 时间戳字段与本地时间的偏差超过 MaxSkew, 属于协议错误
+var ErrTimestampSkew = fmt.Errorf("%w: timestamp skew", ErrInvalidFrame)
+
+// FieldMismatchError 解码时字段值校验失败
+type FieldMismatchError struct {
+	Field string // 字段名
+	Got   uint64 // 帧中的值
+	Want  uint64 // 期望值, 时间戳字段为本地时间
+	Err   error  // ErrChecksumMismatch、ErrConstMismatch 或 ErrTimestampSkew
+}
+
+func (e *FieldMismatchError) Error() string {
+	return fmt.Sprintf("field %s: %v: got 0x%x, want 0x%x", e.Field, e.Err, e.Got, e.Want)
+}
+
+func (e *FieldMismatchError) Unwrap() error {
+	return e.Err
+}
+
+// checksumWidth 返回算法结果的字节数
+func checksumWidth(algo string) (int, error) {
+	switch algo {
+	case ChecksumCRC16:
+		return 2, nil
+	case ChecksumCRC32, ChecksumAdler32:
+		return 4, nil
+	case ChecksumXOR:
+		return 1, nil
+	default:
+		return 0, fmt.Errorf("unknown checksum algorithm %q", algo)
+	}
+}
+
+// computeChecksum 依次对 parts 计算校验和
+func computeChecksum(algo string, parts ...[]byte) uint32 {
+	switch algo {
+	case ChecksumCRC16:
+		crc := uint16(0xFFFF)
+		for _, p := range parts {
+			crc = crc16CCITT(crc, p)
+		}
+		return uint32(crc)
+	case ChecksumCRC32:
+		var crc uint32
+		for _, p := range parts {
+			crc = crc32.Update(crc, crc32.IEEETable, p)
+		}
+		return crc
+	case ChecksumAdler32:
+		h := adler32.New()
+		for _, p := range parts {
+			h.Write(p)
+		}
+		return h.Sum32()
+	case ChecksumXOR:
+		var x byte
+		for _, p := range parts {
+			for _, b := range p {
+				x ^= b
+			}
+		}
+		return uint32(x)
+	}
+	return 0
+}
+
+// crc16CCITT 按位计算 CRC-16/CCITT-FALSE
+func crc16CCITT(crc uint16, data []byte) uint16 {
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
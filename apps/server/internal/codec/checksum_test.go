@@ -0,0 +1,232 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// gatewayConfig 魔数 + 校验和帧
+// magic(2, 0xCAFE) + len(4) + cmd(2,route) + seq(2) + crc(4, crc32 覆盖整帧), 大端序
+func gatewayConfig(t *testing.T) PacketConfig {
+	t.Helper()
+	fdCfg, err := NewFieldDrivenConfig([]FieldDef{
+		{Name: "magic", Bytes: 2, Role: FieldRoleConst, Value: 0xCAFE},
+		{Name: "len", Bytes: 4},
+		{Name: "cmd", Bytes: 2, IsRoute: true},
+		{Name: "seq", Bytes: 2, IsSeq: true},
+		{Name: "crc", Bytes: 4, Role: FieldRoleChecksum, Checksum: ChecksumCRC32},
+	})
+	if err != nil {
+		t.Fatalf("NewFieldDrivenConfig error: %v", err)
+	}
+	fdCfg.BigEndian = true
+	return PacketConfig{FieldDriven: fdCfg}
+}
+
+func TestChecksumAlgorithms(t *testing.T) {
+	// 标准校验向量
+	data := []byte("123456789")
+	tests := []struct {
+		algo string
+		want uint32
+	}{
+		{ChecksumCRC16, 0x29B1},
+		{ChecksumCRC32, 0xCBF43926},
+		{ChecksumAdler32, 0x091E01DE},
+		{ChecksumXOR, 0x31},
+	}
+	for _, tt := range tests {
+		if got := computeChecksum(tt.algo, data[:4], data[4:]); got != tt.want {
+			t.Errorf("%s = 0x%x, want 0x%x", tt.algo, got, tt.want)
+		}
+	}
+}
+
+func TestConstAndChecksumFields(t *testing.T) {
+	cfg := gatewayConfig(t)
+	pkt := &Packet{Route: 1001, Seq: 3, Data: []byte("hello")}
+
+	buf, err := Encode(pkt, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if magic := binary.BigEndian.Uint16(buf[0:2]); magic != 0xCAFE {
+		t.Fatalf("magic = 0x%x, want 0xCAFE", magic)
+	}
+	wantCRC := computeChecksum(ChecksumCRC32, buf[:10], buf[14:])
+	if crc := binary.BigEndian.Uint32(buf[10:14]); crc != wantCRC {
+		t.Fatalf("crc = 0x%x, want 0x%x", crc, wantCRC)
+	}
+
+	decoded, err := DecodeBytes(buf, cfg)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if decoded.Route != 1001 || decoded.Seq != 3 || !bytes.Equal(decoded.Data, pkt.Data) {
+		t.Fatalf("decoded = %+v", decoded)
+	}
+
+	decoded, err = NewDecoder(bytes.NewReader(buf), cfg).Decode()
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if !bytes.Equal(decoded.Data, pkt.Data) {
+		t.Fatalf("stream data = %q, want %q", decoded.Data, pkt.Data)
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	cfg := gatewayConfig(t)
+	buf, _ := Encode(&Packet{Route: 1001, Seq: 3, Data: []byte("hello")}, cfg)
+	buf[len(buf)-1] ^= 0xFF
+
+	_, err := DecodeBytes(buf, cfg)
+	var mismatch *FieldMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Field != "crc" {
+		t.Fatalf("DecodeBytes err = %v, want crc mismatch", err)
+	}
+	if !errors.Is(err, ErrChecksumMismatch) || !IsProtocolError(err) {
+		t.Fatalf("err = %v, want ErrChecksumMismatch protocol error", err)
+	}
+
+	if _, err := NewDecoder(bytes.NewReader(buf), cfg).Decode(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Decode err = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestConstMismatch(t *testing.T) {
+	cfg := gatewayConfig(t)
+	buf, _ := Encode(&Packet{Route: 1001, Data: []byte("hello")}, cfg)
+	buf[0] = 0xBE
+
+	if _, err := DecodeBytes(buf, cfg); !errors.Is(err, ErrConstMismatch) {
+		t.Fatalf("DecodeBytes err = %v, want ErrConstMismatch", err)
+	}
+	if _, err := NewDecoder(bytes.NewReader(buf), cfg).Decode(); !errors.Is(err, ErrConstMismatch) || !IsProtocolError(err) {
+		t.Fatalf("Decode err = %v, want ErrConstMismatch", err)
+	}
+}
+
+func TestChecksumScopes(t *testing.T) {
+	// 两个校验和字段互不覆盖: xor 只覆盖 header, crc16 只覆盖 body
+	fdCfg, err := NewFieldDrivenConfig([]FieldDef{
+		{Name: "len", Bytes: 2},
+		{Name: "cmd", Bytes: 1, IsRoute: true},
+		{Name: "hsum", Bytes: 1, Role: FieldRoleChecksum, Checksum: ChecksumXOR, ChecksumScope: ChecksumScopeHeader},
+		{Name: "bsum", Bytes: 2, Role: FieldRoleChecksum, Checksum: ChecksumCRC16, ChecksumScope: ChecksumScopeBody},
+	})
+	if err != nil {
+		t.Fatalf("NewFieldDrivenConfig error: %v", err)
+	}
+	cfg := PacketConfig{FieldDriven: fdCfg}
+
+	buf, err := Encode(&Packet{Route: 7, Data: []byte("abc")}, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if want := byte(computeChecksum(ChecksumXOR, buf[:3])); buf[3] != want {
+		t.Fatalf("hsum = 0x%x, want 0x%x", buf[3], want)
+	}
+	if got, want := binary.LittleEndian.Uint16(buf[4:6]), uint16(computeChecksum(ChecksumCRC16, []byte("abc"))); got != want {
+		t.Fatalf("bsum = 0x%x, want 0x%x", got, want)
+	}
+	if _, err := DecodeBytes(buf, cfg); err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+}
+
+func TestTimestampField(t *testing.T) {
+	now := time.Unix(1700000000, 123000000)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	fdCfg, err := NewFieldDrivenConfig([]FieldDef{
+		{Name: "len", Bytes: 2},
+		{Name: "cmd", Bytes: 2, IsRoute: true},
+		{Name: "ts", Bytes: 4, Role: FieldRoleTimestamp},
+	})
+	if err != nil {
+		t.Fatalf("NewFieldDrivenConfig error: %v", err)
+	}
+	buf, err := Encode(&Packet{Route: 1}, PacketConfig{FieldDriven: fdCfg})
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if ts := binary.LittleEndian.Uint32(buf[4:8]); ts != 1700000000 {
+		t.Fatalf("timestamp = %d, want 1700000000", ts)
+	}
+}
+
+func TestTimestampSkew(t *testing.T) {
+	defer func() { timeNow = time.Now }()
+
+	fdCfg, err := NewFieldDrivenConfig([]FieldDef{
+		{Name: "len", Bytes: 2},
+		{Name: "cmd", Bytes: 2, IsRoute: true},
+		{Name: "ts", Bytes: 2, Role: FieldRoleTimestamp, MaxSkew: 60},
+	})
+	if err != nil {
+		t.Fatalf("NewFieldDrivenConfig error: %v", err)
+	}
+	cfg := PacketConfig{FieldDriven: fdCfg}
+
+	// 本地时间截断到 2 字节为 0xFFF0, 回绕后的偏差仍按较近方向计算
+	const now = 0x6553FFF0
+	tests := []struct {
+		name string
+		sent int64
+		ok   bool
+	}{
+		{"now", now, true},
+		{"within skew", now - 60, true},
+		{"wraps within skew", now + 30, true},
+		{"too old", now - 61, false},
+		{"wraps too new", now + 3600, false},
+	}
+	for _, tt := range tests {
+		timeNow = func() time.Time { return time.Unix(tt.sent, 0) }
+		buf, err := Encode(&Packet{Route: 1}, cfg)
+		if err != nil {
+			t.Fatalf("Encode error: %v", err)
+		}
+
+		timeNow = func() time.Time { return time.Unix(now, 0) }
+		_, err = DecodeBytes(buf, cfg)
+		if tt.ok && err != nil {
+			t.Errorf("%s: DecodeBytes error: %v", tt.name, err)
+		}
+		if !tt.ok {
+			var mismatch *FieldMismatchError
+			if !errors.As(err, &mismatch) || !errors.Is(err, ErrTimestampSkew) || mismatch.Field != "ts" {
+				t.Errorf("%s: DecodeBytes err = %v, want ErrTimestampSkew", tt.name, err)
+			}
+			if _, err := NewDecoder(bytes.NewReader(buf), cfg).Decode(); !errors.Is(err, ErrTimestampSkew) || !IsProtocolError(err) {
+				t.Errorf("%s: Decode err = %v, want ErrTimestampSkew", tt.name, err)
+			}
+		}
+	}
+}
+
+func TestFieldRoleValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		field FieldDef
+	}{
+		{"unknown role", FieldDef{Name: "x", Bytes: 1, Role: "magic"}},
+		{"const overflow", FieldDef{Name: "magic", Bytes: 1, Role: FieldRoleConst, Value: 0xCAFE}},
+		{"unknown algorithm", FieldDef{Name: "sum", Bytes: 4, Role: FieldRoleChecksum, Checksum: "md5"}},
+		{"checksum width", FieldDef{Name: "sum", Bytes: 2, Role: FieldRoleChecksum, Checksum: ChecksumCRC32}},
+		{"unknown scope", FieldDef{Name: "sum", Bytes: 1, Role: FieldRoleChecksum, Checksum: ChecksumXOR, ChecksumScope: "all"}},
+		{"role with route", FieldDef{Name: "ts", Bytes: 4, Role: FieldRoleTimestamp, IsRoute: true}},
+		{"skew without timestamp", FieldDef{Name: "x", Bytes: 4, MaxSkew: 60}},
+	}
+	for _, tt := range tests {
+		fields := []FieldDef{{Name: "len", Bytes: 2}, tt.field}
+		if _, err := NewFieldDrivenConfig(fields); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
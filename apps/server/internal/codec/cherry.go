@@ -0,0 +1,12 @@
+package codec
+
+// ProtocolCherry Cherry 框架的 pomelo 协议
+//
+// 外层包与 Pomelo 相同(handshake/handshakeAck/heartbeat/data/kick), 内层消息 flag 额外使用
+// gzip(0x10, 消息体 zlib 压缩)和 error(0x20, 错误响应)位; 握手响应的 sys.dict 为路由字典,
+// sys.heartbeat 为心跳间隔(秒). Cherry 的 simple 协议为 mid(4) + len(4) 大端字段驱动帧, 无需单独注册
+const ProtocolCherry = "cherry"
+
+func init() {
+	RegisterFrameCodec(ProtocolCherry, pomeloFamilyCodec(true))
+}
@@ -0,0 +1,105 @@
+package codec
+
+import (
+	"bytes"
+	"compress/zlib"
+	"testing"
+)
+
+// cherryPomeloConfig 返回 Cherry pomelo 协议配置, 路由字典取自握手响应 sys.dict
+func cherryPomeloConfig() PacketConfig {
+	return PacketConfig{
+		Protocol: ProtocolCherry,
+		Pomelo: &PomeloConfig{
+			UseRouteCompress: true,
+			RouteDict:        map[string]uint16{"game.room.join": 0x0105, "game.room.onEnter": 0x0106},
+		},
+	}
+}
+
+func TestCherryProtocolSelected(t *testing.T) {
+	cfg := cherryPomeloConfig()
+	if cfg.ProtocolName() != ProtocolCherry || !cfg.IsPomelo() {
+		t.Fatalf("protocol = %s, IsPomelo = %v", cfg.ProtocolName(), cfg.IsPomelo())
+	}
+
+	// 心跳和握手包与 Pomelo 一致: type(1) + length(3)
+	hb, err := Encode(&Packet{Heartbeat: true}, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if !bytes.Equal(hb, []byte{0x03, 0x00, 0x00, 0x00}) {
+		t.Fatalf("heartbeat = % x", hb)
+	}
+	hs, err := DecodeBytes(PomeloEncodeHandshake([]byte(`{"code":200}`)), cfg)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if hs.ExtCode != PomeloPacketHandshake {
+		t.Fatalf("handshake ext = %d", hs.ExtCode)
+	}
+}
+
+func TestCherryRouteDict(t *testing.T) {
+	cfg := cherryPomeloConfig()
+
+	// 字典中的字符串路由编码为压缩码: flag(request, route compress) + msgId(5) + code(0x0105) + body
+	buf, err := Encode(&Packet{Seq: 5, StringRoute: "game.room.join", Data: []byte{0x08, 0x01}}, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	want := []byte{0x04, 0x00, 0x00, 0x06, 0x01, 0x05, 0x01, 0x05, 0x08, 0x01}
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("frame = % x, want % x", buf, want)
+	}
+
+	// 不在字典中的路由仍使用字符串
+	buf, err = Encode(&Packet{Seq: 6, StringRoute: "game.chat.say"}, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if buf[4] != 0x00 || buf[6] != byte(len("game.chat.say")) {
+		t.Fatalf("frame = % x, want string route", buf)
+	}
+
+	// 推送的压缩码还原为字符串路由
+	pkt, err := DecodeBytes(PomeloEncodePush(0x0106, "", []byte{0x10}), cfg)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if pkt.StringRoute != "game.room.onEnter" || pkt.Route != 0x0106 {
+		t.Fatalf("pkt = %+v, want game.room.onEnter", pkt)
+	}
+}
+
+func TestCherryErrorAndGzipFlags(t *testing.T) {
+	var zbuf bytes.Buffer
+	zw := zlib.NewWriter(&zbuf)
+	zw.Write([]byte(`{"code":500}`))
+	zw.Close()
+
+	// flag = response(0x02<<1) | gzip(0x10) | error(0x20)
+	msg := append([]byte{PomeloMsgResponse<<1 | pomeloGzipMask | pomeloErrorMask}, encodeVarint(9)...)
+	msg = append(msg, zbuf.Bytes()...)
+	frame := pomeloEncodePacket(PomeloPacketData, msg)
+
+	pkt, err := NewDecoder(bytes.NewReader(frame), cherryPomeloConfig()).Decode()
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if pkt.Seq != 9 || string(pkt.Data) != `{"code":500}` {
+		t.Fatalf("pkt = %+v, data = %q", pkt, pkt.Data)
+	}
+	if pkt.Fields[PomeloFieldError] != 1 {
+		t.Fatalf("fields = %v, want error flag", pkt.Fields)
+	}
+
+	// 标准 Pomelo 不识别扩展标志, 消息体原样返回
+	plain, err := DecodeBytes(frame, PacketConfig{Pomelo: &PomeloConfig{}})
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if !bytes.Equal(plain.Data, zbuf.Bytes()) || plain.Fields != nil {
+		t.Fatalf("pomelo pkt = %+v", plain)
+	}
+}
@@ -0,0 +1,359 @@
+package codec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rc4"
+	"crypto/sha256"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 加密算法, 除 aes-gcm 外均为流加密: 绑定连接会话(见 PacketConfig.WithCipherSession)时
+// 密钥流在同一方向上跨帧连续, 否则每帧从密钥(和 IV)重新开始
+const (
+	CipherXOR    = "xor"     // 循环异或密钥
+	CipherRC4    = "rc4"     // RC4
+	CipherAESCTR = "aes-ctr" // AES-CTR, 初始计数器块为 IV
+	CipherAESGCM = "aes-gcm" // AES-GCM, 密文格式为 nonce(12) + ciphertext + tag(16)
+)
+
+// ErrDecrypt 消息体解密失败, 包装 ErrInvalidFrame 使 IsProtocolError 成立
+var ErrDecrypt = fmt.Errorf("%w: decrypt body", ErrInvalidFrame)
+
+// CipherConfig 加密配置, 加密位于压缩之后、帧编码之前, 解密位于帧解码之后、解压之前
+//
+// Header 为 true 时改为加密 size 字段之后的整帧(header 其余字段 + body + trailer),
+// 校验和按明文计算; 该模式要求字段驱动模式、size 为首个定长字段且为流加密算法
+type CipherConfig struct {
+	Algorithm   string       // 见 Cipher* 常量
+	Key         []byte       // 密钥, 为空时加密阶段不生效(等待密钥交换)
+	IV          []byte       // aes-ctr 的初始计数器块, 为空时全 0
+	Header      bool         // 同时加密帧头
+	KeyExchange *KeyExchange // 非 nil 时从指定响应中提取密钥
+
+	session *cipherSession // 连接会话的密钥流, nil 时每帧新建
+}
+
+// cipherSession 一条连接上跨帧连续的加密、解密密钥流, 首次使用时创建
+type cipherSession struct {
+	enc cipher.Stream
+	dec cipher.Stream
+}
+
+// WithCipherSession 返回绑定一条连接的配置副本: 流加密算法的加密和解密各使用一条
+// 从密钥(和 IV)开始、跨帧连续的密钥流; 未配置加密或为 aes-gcm(每帧随机 nonce)时返回 c 本身
+//
+// 会话中的密钥流有状态, 同一方向的帧必须按其在连接上的顺序逐个编码或解码;
+// 连接重建或密钥交换后应重新调用以从头开始
+func (c PacketConfig) WithCipherSession() PacketConfig {
+	if c.Cipher == nil || c.Cipher.Algorithm == CipherAESGCM {
+		return c
+	}
+	next := *c.Cipher
+	next.session = &cipherSession{}
+	c.Cipher = &next
+	return c
+}
+
+// KeyExchange 描述携带密钥的握手响应, FieldNumber 和 VarField 二选一
+//
+// 提取的内容作为密钥: aes 算法长度不是 16/24/32 字节、rc4 超过 256 字节时取其 SHA-256
+type KeyExchange struct {
+	Route       uint64 `json:"route"`                 // 响应路由
+	StringRoute string `json:"stringRoute,omitempty"` // 字符串路由, 非空时优先
+	FieldNumber int    `json:"fieldNumber,omitempty"` // 响应消息体中 bytes/string 字段的 protobuf 字段号
+	VarField    string `json:"varField,omitempty"`    // 字段驱动模式下的变长字段名
+}
+
+// Validate 校验加密配置, fd 为当前的字段驱动配置(非字段驱动模式传 nil)
+func (c *CipherConfig) Validate(fd *FieldDrivenConfig) error {
+	switch c.Algorithm {
+	case CipherXOR, CipherRC4, CipherAESCTR, CipherAESGCM:
+	default:
+		return fmt.Errorf("cipher: unknown algorithm %q", c.Algorithm)
+	}
+	if len(c.Key) == 0 && c.KeyExchange == nil {
+		return fmt.Errorf("cipher: key or key exchange required")
+	}
+	if len(c.IV) > 0 && (c.Algorithm != CipherAESCTR || len(c.IV) != aes.BlockSize) {
+		return fmt.Errorf("cipher: iv must be %d bytes and only applies to %s", aes.BlockSize, CipherAESCTR)
+	}
+	if len(c.Key) > 0 {
+		var err error
+		if c.Algorithm == CipherAESGCM {
+			_, err = newGCM(c.Key)
+		} else {
+			_, err = newCipherStream(c.Algorithm, c.Key, c.IV)
+		}
+		if err != nil {
+			return fmt.Errorf("cipher: %w", err)
+		}
+	}
+
+	if c.Header {
+		if c.Algorithm == CipherAESGCM {
+			return fmt.Errorf("cipher: %s cannot encrypt the header", c.Algorithm)
+		}
+		if fd == nil || fd.SizeIndex != 0 || fd.Fields[0].Varint {
+			return fmt.Errorf("cipher: header encryption requires a fixed-width size field at the start of a field-driven frame")
+		}
+	}
+
+	if kx := c.KeyExchange; kx != nil {
+		if (kx.FieldNumber > 0) == (kx.VarField != "") {
+			return fmt.Errorf("cipher: key exchange needs exactly one of fieldNumber and varField")
+		}
+		if kx.FieldNumber > 0 && !protowire.Number(kx.FieldNumber).IsValid() {
+			return fmt.Errorf("cipher: invalid key exchange field number %d", kx.FieldNumber)
+		}
+		if kx.VarField != "" {
+			found := false
+			if fd != nil {
+				for i := range fd.lengthFor {
+					if fd.Fields[i].Name == kx.VarField {
+						found = true
+					}
+				}
+			}
+			if !found {
+				return fmt.Errorf("cipher: key exchange var field %s not found", kx.VarField)
+			}
+		}
+	}
+	return nil
+}
+
+// active 返回加密阶段是否生效
+func (c *CipherConfig) active() bool {
+	return c != nil && len(c.Key) > 0
+}
+
+// ExchangeKey 若 pkt 是密钥交换响应, 返回装入新密钥的配置副本
+//
+// pkt 应为已按当前配置解码的数据包; 不匹配或未找到密钥内容时返回 false
+func (c *CipherConfig) ExchangeKey(pkt *Packet) (*CipherConfig, bool) {
+	if c == nil || c.KeyExchange == nil || pkt == nil || pkt.Heartbeat {
+		return nil, false
+	}
+	kx := c.KeyExchange
+	if kx.StringRoute != "" {
+		if pkt.StringRoute != kx.StringRoute {
+			return nil, false
+		}
+	} else if pkt.Route != kx.Route {
+		return nil, false
+	}
+
+	var material []byte
+	if kx.VarField != "" {
+		material = pkt.VarFields[kx.VarField]
+	} else {
+		material = protoBytesField(pkt.Data, protowire.Number(kx.FieldNumber))
+	}
+	if len(material) == 0 {
+		return nil, false
+	}
+
+	next := *c
+	next.Key = deriveKey(c.Algorithm, material)
+	next.session = nil
+	return &next, true
+}
+
+// deriveKey 将密钥内容转换为算法可用的密钥
+func deriveKey(algo string, material []byte) []byte {
+	switch algo {
+	case CipherAESCTR, CipherAESGCM:
+		switch len(material) {
+		case 16, 24, 32:
+		default:
+			sum := sha256.Sum256(material)
+			return sum[:]
+		}
+	case CipherRC4:
+		if len(material) > 256 {
+			sum := sha256.Sum256(material)
+			return sum[:]
+		}
+	}
+	return append([]byte(nil), material...)
+}
+
+// protoBytesField 在 protobuf 编码的 data 中查找字段号为 num 的 length-delimited 字段
+func protoBytesField(data []byte, num protowire.Number) []byte {
+	for len(data) > 0 {
+		n, typ, l := protowire.ConsumeTag(data)
+		if l < 0 {
+			return nil
+		}
+		data = data[l:]
+		if n == num && typ == protowire.BytesType {
+			v, m := protowire.ConsumeBytes(data)
+			if m < 0 {
+				return nil
+			}
+			return v
+		}
+		m := protowire.ConsumeFieldValue(n, typ, data)
+		if m < 0 {
+			return nil
+		}
+		data = data[m:]
+	}
+	return nil
+}
+
+// xorStream 循环异或密钥流
+type xorStream struct {
+	key []byte
+	pos int
+}
+
+func (s *xorStream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		dst[i] = src[i] ^ s.key[s.pos]
+		s.pos = (s.pos + 1) % len(s.key)
+	}
+}
+
+// newCipherStream 为一帧创建流加密器
+func newCipherStream(algo string, key, iv []byte) (cipher.Stream, error) {
+	switch algo {
+	case CipherXOR:
+		return &xorStream{key: key}, nil
+	case CipherRC4:
+		return rc4.NewCipher(key)
+	case CipherAESCTR:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(iv) == 0 {
+			iv = make([]byte, aes.BlockSize)
+		}
+		return cipher.NewCTR(block, iv), nil
+	}
+	return nil, fmt.Errorf("%s is not a stream cipher", algo)
+}
+
+// stream 返回加密(encrypt 为 true)或解密使用的流加密器: 绑定会话时为跨帧连续的会话密钥流,
+// 否则为本帧新建
+func (c *CipherConfig) stream(encrypt bool) (cipher.Stream, error) {
+	s := c.session
+	if s == nil {
+		return newCipherStream(c.Algorithm, c.Key, c.IV)
+	}
+	cur := &s.dec
+	if encrypt {
+		cur = &s.enc
+	}
+	if *cur == nil {
+		stream, err := newCipherStream(c.Algorithm, c.Key, c.IV)
+		if err != nil {
+			return nil, err
+		}
+		*cur = stream
+	}
+	return *cur, nil
+}
+
+// frameStream 返回整帧加密使用的流加密器, 未启用整帧加密时返回 nil
+func (c *CipherConfig) frameStream(encrypt bool) (cipher.Stream, error) {
+	if !c.active() || !c.Header {
+		return nil, nil
+	}
+	return c.stream(encrypt)
+}
+
+// encryptPacket 按配置加密 pkt 的消息体, 返回待编码的数据包副本; 无需处理时返回 pkt 本身
+func (c PacketConfig) encryptPacket(pkt *Packet) (*Packet, error) {
+	cc := c.Cipher
+	if !cc.active() || cc.Header || len(pkt.Data) == 0 {
+		return pkt, nil
+	}
+
+	out := *pkt
+	if cc.Algorithm == CipherAESGCM {
+		aead, err := newGCM(cc.Key)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt body: %w", err)
+		}
+		nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(pkt.Data)+aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, fmt.Errorf("encrypt body: %w", err)
+		}
+		out.Data = aead.Seal(nonce, nonce, pkt.Data, nil)
+		return &out, nil
+	}
+
+	stream, err := cc.stream(true)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt body: %w", err)
+	}
+	out.Data = make([]byte, len(pkt.Data))
+	stream.XORKeyStream(out.Data, pkt.Data)
+	return &out, nil
+}
+
+// decryptPacket 按配置就地解密 pkt 的消息体
+func (c PacketConfig) decryptPacket(pkt *Packet) error {
+	cc := c.Cipher
+	if !cc.active() || cc.Header || len(pkt.Data) == 0 {
+		return nil
+	}
+
+	if cc.Algorithm == CipherAESGCM {
+		aead, err := newGCM(cc.Key)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrDecrypt, err)
+		}
+		if len(pkt.Data) < aead.NonceSize()+aead.Overhead() {
+			return fmt.Errorf("%w: body too short: %d bytes", ErrDecrypt, len(pkt.Data))
+		}
+		nonce, sealed := pkt.Data[:aead.NonceSize()], pkt.Data[aead.NonceSize():]
+		plain, err := aead.Open(nil, nonce, sealed, nil)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrDecrypt, err)
+		}
+		pkt.Data = plain
+		return nil
+	}
+
+	stream, err := cc.stream(false)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	plain := make([]byte, len(pkt.Data))
+	stream.XORKeyStream(plain, pkt.Data)
+	pkt.Data = plain
+	return nil
+}
+
+// newGCM 创建 AES-GCM 加密器
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// cryptFrame 对字段驱动帧 size 字段之后的字节做整帧加密(encrypt 为 true)或解密, 返回处理后的副本;
+// 未启用整帧加密时返回 buf 本身
+func (c PacketConfig) cryptFrame(buf []byte, encrypt bool) ([]byte, error) {
+	if c.FieldDriven == nil {
+		return buf, nil
+	}
+	stream, err := c.Cipher.frameStream(encrypt)
+	if err != nil || stream == nil {
+		return buf, err
+	}
+	skip := min(c.FieldDriven.SizeBytes, len(buf))
+	out := make([]byte, len(buf))
+	copy(out[:skip], buf[:skip])
+	stream.XORKeyStream(out[skip:], buf[skip:])
+	return out, nil
+}
@@ -0,0 +1,253 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var testCipherKey = []byte("0123456789abcdef")
+
+func TestCipherAlgorithms(t *testing.T) {
+	body := []byte("attack at dawn")
+	for _, algo := range []string{CipherXOR, CipherRC4, CipherAESCTR, CipherAESGCM} {
+		cfg := DefaultPacketConfig()
+		cfg.Cipher = &CipherConfig{Algorithm: algo, Key: testCipherKey}
+		if err := cfg.Cipher.Validate(nil); err != nil {
+			t.Fatalf("%s: Validate error: %v", algo, err)
+		}
+
+		buf, err := Encode(&Packet{Route: 1, Seq: 2, Data: body}, cfg)
+		if err != nil {
+			t.Fatalf("%s: Encode error: %v", algo, err)
+		}
+		if bytes.Contains(buf, body) {
+			t.Fatalf("%s: body sent in plaintext", algo)
+		}
+
+		pkt, err := DecodeBytes(buf, cfg)
+		if err != nil {
+			t.Fatalf("%s: DecodeBytes error: %v", algo, err)
+		}
+		if !bytes.Equal(pkt.Data, body) {
+			t.Fatalf("%s: body = %q, want %q", algo, pkt.Data, body)
+		}
+	}
+}
+
+func TestCipherAfterCompression(t *testing.T) {
+	cfg := DefaultPacketConfig()
+	cfg.Compression = &CompressionConfig{Algorithm: CompressZlib}
+	cfg.Cipher = &CipherConfig{Algorithm: CipherAESCTR, Key: testCipherKey}
+
+	body := bytes.Repeat([]byte("abc"), 500)
+	buf, err := Encode(&Packet{Route: 1, Data: body}, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if len(buf) >= len(body) {
+		t.Fatalf("frame %d bytes, body not compressed before encryption", len(buf))
+	}
+
+	pkt, err := NewDecoder(bytes.NewReader(buf), cfg).Decode()
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if !bytes.Equal(pkt.Data, body) {
+		t.Fatal("decoded body mismatch")
+	}
+}
+
+func TestCipherHeader(t *testing.T) {
+	cfg := gatewayConfig(t)
+	// size 字段需位于帧首, 去掉魔数字段
+	fdCfg, err := NewFieldDrivenConfig(cfg.FieldDriven.Fields[1:])
+	if err != nil {
+		t.Fatalf("NewFieldDrivenConfig error: %v", err)
+	}
+	fdCfg.BigEndian = true
+	cfg = PacketConfig{FieldDriven: fdCfg, Cipher: &CipherConfig{Algorithm: CipherRC4, Key: testCipherKey, Header: true}}
+	if err := cfg.Cipher.Validate(fdCfg); err != nil {
+		t.Fatalf("Validate error: %v", err)
+	}
+
+	var stream bytes.Buffer
+	for seq := uint64(1); seq <= 2; seq++ {
+		buf, err := Encode(&Packet{Route: 1001, Seq: seq, Data: []byte("hello")}, cfg)
+		if err != nil {
+			t.Fatalf("Encode error: %v", err)
+		}
+		// size 字段保持明文, cmd 字段被加密
+		if buf[3] != 5 || (buf[4] == 0x03 && buf[5] == 0xE9) {
+			t.Fatalf("frame = % x, want plaintext size and encrypted header", buf)
+		}
+		stream.Write(buf)
+	}
+
+	frames := stream.Bytes()
+	pkt, err := DecodeBytes(frames, cfg)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if pkt.Route != 1001 || pkt.Seq != 1 || string(pkt.Data) != "hello" {
+		t.Fatalf("pkt = %+v", pkt)
+	}
+
+	dec := NewDecoder(&stream, cfg)
+	for seq := uint64(1); seq <= 2; seq++ {
+		pkt, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("Decode error: %v", err)
+		}
+		if pkt.Route != 1001 || pkt.Seq != seq || string(pkt.Data) != "hello" {
+			t.Fatalf("pkt = %+v", pkt)
+		}
+	}
+}
+
+func TestCipherKeyExchange(t *testing.T) {
+	cfg := DefaultPacketConfig()
+	cfg.Cipher = &CipherConfig{
+		Algorithm:   CipherAESGCM,
+		KeyExchange: &KeyExchange{Route: 100, FieldNumber: 2},
+	}
+	if err := cfg.Cipher.Validate(nil); err != nil {
+		t.Fatalf("Validate error: %v", err)
+	}
+
+	// 握手响应: code(1)=0, key(2)="session-key", 在密钥交换前以明文发送
+	var body []byte
+	body = protowire.AppendTag(body, 1, protowire.VarintType)
+	body = protowire.AppendVarint(body, 0)
+	body = protowire.AppendTag(body, 2, protowire.BytesType)
+	body = protowire.AppendString(body, "session-key")
+	buf, err := Encode(&Packet{Route: 100, Data: body}, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if !bytes.Contains(buf, body) {
+		t.Fatal("handshake encrypted before key exchange")
+	}
+
+	pkt, err := DecodeBytes(buf, cfg)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if _, ok := cfg.Cipher.ExchangeKey(&Packet{Route: 101, Data: body}); ok {
+		t.Fatal("key exchanged on unrelated route")
+	}
+	next, ok := cfg.Cipher.ExchangeKey(pkt)
+	if !ok {
+		t.Fatal("key not exchanged")
+	}
+	// 非 AES 密钥长度的内容取 SHA-256
+	if len(next.Key) != 32 {
+		t.Fatalf("key length = %d, want 32", len(next.Key))
+	}
+
+	keyed := cfg
+	keyed.Cipher = next
+	buf, err = Encode(&Packet{Route: 1, Data: []byte("after login")}, keyed)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if _, err := DecodeBytes(buf, cfg); err != nil {
+		t.Fatalf("DecodeBytes without key error: %v", err)
+	}
+	pkt, err = DecodeBytes(buf, keyed)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if string(pkt.Data) != "after login" {
+		t.Fatalf("body = %q", pkt.Data)
+	}
+
+	// 篡改密文
+	buf[len(buf)-1] ^= 0xFF
+	if _, err := DecodeBytes(buf, keyed); !errors.Is(err, ErrDecrypt) || !IsProtocolError(err) {
+		t.Fatalf("err = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestCipherDecoderSetConfig(t *testing.T) {
+	cfg := DefaultPacketConfig()
+	keyed := cfg
+	keyed.Cipher = &CipherConfig{Algorithm: CipherXOR, Key: []byte{0x5A}}
+
+	var stream bytes.Buffer
+	for i, c := range []PacketConfig{cfg, keyed} {
+		buf, err := Encode(&Packet{Route: uint64(i), Data: []byte("body")}, c)
+		if err != nil {
+			t.Fatalf("Encode error: %v", err)
+		}
+		stream.Write(buf)
+	}
+
+	dec := NewDecoder(&stream, cfg)
+	for _, c := range []PacketConfig{cfg, keyed} {
+		dec.SetConfig(c)
+		pkt, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("Decode error: %v", err)
+		}
+		if string(pkt.Data) != "body" {
+			t.Fatalf("body = %q, want body", pkt.Data)
+		}
+	}
+}
+
+func TestCipherSessionContinuesKeystream(t *testing.T) {
+	body := []byte("attack at dawn")
+	for _, algo := range []string{CipherXOR, CipherRC4, CipherAESCTR} {
+		cfg := DefaultPacketConfig()
+		cfg.Cipher = &CipherConfig{Algorithm: algo, Key: testCipherKey}
+		sender, receiver := cfg.WithCipherSession(), cfg.WithCipherSession()
+
+		// 同一连接上的两帧共用一条密钥流, 第二帧接续第一帧之后的密钥流
+		stream, _ := newCipherStream(algo, testCipherKey, nil)
+		want := make([]byte, 2*len(body))
+		stream.XORKeyStream(want, append(append([]byte(nil), body...), body...))
+
+		for i := 0; i < 2; i++ {
+			buf, err := Encode(&Packet{Route: 1, Data: body}, sender)
+			if err != nil {
+				t.Fatalf("%s: Encode error: %v", algo, err)
+			}
+			if got := buf[len(buf)-len(body):]; !bytes.Equal(got, want[i*len(body):(i+1)*len(body)]) {
+				t.Fatalf("%s: frame %d ciphertext = %x, want %x", algo, i, got, want[i*len(body):(i+1)*len(body)])
+			}
+			pkt, err := DecodeBytes(buf, receiver)
+			if err != nil {
+				t.Fatalf("%s: DecodeBytes error: %v", algo, err)
+			}
+			if !bytes.Equal(pkt.Data, body) {
+				t.Fatalf("%s: frame %d body = %q, want %q", algo, i, pkt.Data, body)
+			}
+		}
+	}
+}
+
+func TestCipherValidation(t *testing.T) {
+	fdCfg := flagGatewayConfig(t).FieldDriven
+	tests := []struct {
+		name string
+		cc   CipherConfig
+		fd   *FieldDrivenConfig
+	}{
+		{"unknown algorithm", CipherConfig{Algorithm: "des", Key: testCipherKey}, nil},
+		{"no key", CipherConfig{Algorithm: CipherXOR}, nil},
+		{"bad aes key", CipherConfig{Algorithm: CipherAESCTR, Key: []byte("short")}, nil},
+		{"iv on gcm", CipherConfig{Algorithm: CipherAESGCM, Key: testCipherKey, IV: make([]byte, 16)}, nil},
+		{"gcm header", CipherConfig{Algorithm: CipherAESGCM, Key: testCipherKey, Header: true}, fdCfg},
+		{"header without field-driven", CipherConfig{Algorithm: CipherRC4, Key: testCipherKey, Header: true}, nil},
+		{"key exchange without source", CipherConfig{Algorithm: CipherRC4, KeyExchange: &KeyExchange{Route: 1}}, nil},
+		{"key exchange missing var field", CipherConfig{Algorithm: CipherRC4, KeyExchange: &KeyExchange{Route: 1, VarField: "key"}}, fdCfg},
+	}
+	for _, tt := range tests {
+		if err := tt.cc.Validate(tt.fd); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
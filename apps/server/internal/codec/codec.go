@@ -1,2 +1,228 @@
 // Package codec 提供协议帧编解码和 Protobuf 动态编解码
 package codec
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// 内置帧编解码器名称
+const (
+	ProtocolDue         = "due"    // Legacy Due 帧, 见 dueCodec
+	ProtocolFieldDriven = "field"  // 按 FieldDrivenConfig 定义的字段驱动帧
+	ProtocolPomelo      = "pomelo" // Pomelo 帧
+)
+
+// FrameCodec 帧格式编解码器, 只负责封帧和拆帧, 消息体变换由 Transform 链完成
+type FrameCodec interface {
+	// Encode 将数据包编码为一帧
+	Encode(pkt *Packet) ([]byte, error)
+	// DecodeBytes 从完整的字节数组中解码一帧
+	DecodeBytes(data []byte) (*Packet, error)
+	// Decode 从流中读取并解码下一帧, 帧非法时返回包装 ErrInvalidFrame 的错误
+	Decode(r io.Reader) (*Packet, error)
+}
+
+// RawDecoder 可选接口, 从流中读取一帧的原始字节而不解析, Decoder.DecodeRaw 优先使用
+type RawDecoder interface {
+	DecodeRaw(r io.Reader) ([]byte, error)
+}
+
+// FrameCodecFactory 根据协议帧配置创建编解码器
+type FrameCodecFactory func(cfg PacketConfig) (FrameCodec, error)
+
+var (
+	frameCodecsMu sync.RWMutex
+	frameCodecs   = make(map[string]FrameCodecFactory)
+)
+
+func init() {
+	RegisterFrameCodec(ProtocolDue, func(cfg PacketConfig) (FrameCodec, error) {
+		return &dueCodec{cfg: cfg}, nil
+	})
+	RegisterFrameCodec(ProtocolFieldDriven, func(cfg PacketConfig) (FrameCodec, error) {
+		if cfg.FieldDriven == nil {
+			return nil, fmt.Errorf("field-driven codec requires FieldDriven config")
+		}
+		return &fieldDrivenCodec{cfg: cfg}, nil
+	})
+	RegisterFrameCodec(ProtocolPomelo, func(cfg PacketConfig) (FrameCodec, error) {
+		pc := cfg.Pomelo
+		if pc == nil {
+			pc = &PomeloConfig{}
+		}
+		return &pomeloCodec{cfg: pc, maxSize: cfg.EffectiveMaxFrameSize()}, nil
+	})
+}
+
+// RegisterFrameCodec 注册帧编解码器, 名称重复时 panic
+//
+// 新的框架协议在自身文件的 init 中注册, 通过 PacketConfig.Protocol 按名称选择
+func RegisterFrameCodec(name string, factory FrameCodecFactory) {
+	frameCodecsMu.Lock()
+	defer frameCodecsMu.Unlock()
+	if _, ok := frameCodecs[name]; ok {
+		panic(fmt.Sprintf("codec: frame codec %q registered twice", name))
+	}
+	frameCodecs[name] = factory
+}
+
+// HasFrameCodec 返回是否已注册该名称的帧编解码器
+func HasFrameCodec(name string) bool {
+	frameCodecsMu.RLock()
+	defer frameCodecsMu.RUnlock()
+	_, ok := frameCodecs[name]
+	return ok
+}
+
+// FrameCodecs 返回已注册的帧编解码器名称(按字母序)
+func FrameCodecs() []string {
+	frameCodecsMu.RLock()
+	defer frameCodecsMu.RUnlock()
+	names := make([]string, 0, len(frameCodecs))
+	for name := range frameCodecs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NewFrameCodec 按 cfg.ProtocolName() 创建帧编解码器
+func NewFrameCodec(cfg PacketConfig) (FrameCodec, error) {
+	name := cfg.ProtocolName()
+	frameCodecsMu.RLock()
+	factory, ok := frameCodecs[name]
+	frameCodecsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown frame codec %q", name)
+	}
+	return factory(cfg)
+}
+
+// Transform 消息体变换阶段, 位于 DynamicEncode 与封帧之间
+//
+// 编码时按链的顺序执行 Encode, 解码时逆序执行 Decode; 心跳和控制包不经过变换
+type Transform interface {
+	// Encode 返回变换后的数据包, 不应修改传入的 pkt
+	Encode(pkt *Packet) (*Packet, error)
+	// Decode 就地还原数据包
+	Decode(pkt *Packet) error
+}
+
+// compressTransform 按 PacketConfig.Compression 压缩消息体
+type compressTransform struct {
+	cfg PacketConfig
+}
+
+func (t compressTransform) Encode(pkt *Packet) (*Packet, error) { return t.cfg.compressPacket(pkt) }
+func (t compressTransform) Decode(pkt *Packet) error            { return t.cfg.decompressPacket(pkt) }
+
+// cipherTransform 按 PacketConfig.Cipher 加密消息体, 整帧加密由字段驱动编解码器处理
+type cipherTransform struct {
+	cfg PacketConfig
+}
+
+func (t cipherTransform) Encode(pkt *Packet) (*Packet, error) { return t.cfg.encryptPacket(pkt) }
+func (t cipherTransform) Decode(pkt *Packet) error            { return t.cfg.decryptPacket(pkt) }
+
+// transforms 返回生效的变换链: 压缩、加密, 然后是 Transforms 中的自定义变换
+func (c PacketConfig) transforms() []Transform {
+	var chain []Transform
+	if c.Compression != nil {
+		chain = append(chain, compressTransform{c})
+	}
+	if c.Cipher != nil {
+		chain = append(chain, cipherTransform{c})
+	}
+	return append(chain, c.Transforms...)
+}
+
+// isControl 返回是否为心跳或 Pomelo 控制包(握手等), 这些包不经过变换
+func (c PacketConfig) isControl(pkt *Packet) bool {
+	return pkt.Heartbeat || (c.IsPomelo() && pkt.ExtCode != 0)
+}
+
+// encodeTransforms 依次执行变换链的 Encode
+func (c PacketConfig) encodeTransforms(pkt *Packet) (*Packet, error) {
+	if c.isControl(pkt) {
+		return pkt, nil
+	}
+	for _, t := range c.transforms() {
+		var err error
+		if pkt, err = t.Encode(pkt); err != nil {
+			return nil, err
+		}
+	}
+	return pkt, nil
+}
+
+// decodeTransforms 逆序执行变换链的 Decode
+func (c PacketConfig) decodeTransforms(pkt *Packet) error {
+	if c.isControl(pkt) {
+		return nil
+	}
+	chain := c.transforms()
+	for i := len(chain) - 1; i >= 0; i-- {
+		if err := chain[i].Decode(pkt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BodyChecksum 在消息体末尾追加校验和的变换, 用于帧格式本身没有校验和字段的协议
+//
+// 解码时校验并去掉末尾的校验和, 不一致时返回包装 ErrChecksumMismatch 的 *FieldMismatchError
+type BodyChecksum struct {
+	Algorithm string // 见 Checksum* 常量
+	BigEndian bool   // true 时校验和以大端序写入, 默认小端序
+}
+
+// bodyChecksumField 校验失败时报告的字段名
+const bodyChecksumField = "bodyChecksum"
+
+func (t BodyChecksum) Encode(pkt *Packet) (*Packet, error) {
+	width, err := checksumWidth(t.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	out := *pkt
+	out.Data = make([]byte, len(pkt.Data)+width)
+	copy(out.Data, pkt.Data)
+	t.put(out.Data[len(pkt.Data):], uint64(computeChecksum(t.Algorithm, pkt.Data)), width)
+	return &out, nil
+}
+
+func (t BodyChecksum) Decode(pkt *Packet) error {
+	width, err := checksumWidth(t.Algorithm)
+	if err != nil {
+		return err
+	}
+	if len(pkt.Data) < width {
+		return fmt.Errorf("%w: body %d bytes shorter than %s checksum", ErrInvalidFrame, len(pkt.Data), t.Algorithm)
+	}
+	body, sum := pkt.Data[:len(pkt.Data)-width], pkt.Data[len(pkt.Data)-width:]
+	got := t.read(sum, width)
+	if want := uint64(computeChecksum(t.Algorithm, body)); got != want {
+		return &FieldMismatchError{Field: bodyChecksumField, Got: got, Want: want, Err: ErrChecksumMismatch}
+	}
+	pkt.Data = body
+	return nil
+}
+
+func (t BodyChecksum) put(buf []byte, v uint64, n int) {
+	if t.BigEndian {
+		putUintN(buf, v, n)
+	} else {
+		putUintNLE(buf, v, n)
+	}
+}
+
+func (t BodyChecksum) read(buf []byte, n int) uint64 {
+	if t.BigEndian {
+		return readUintN(buf, n)
+	}
+	return readUintNLE(buf, n)
+}
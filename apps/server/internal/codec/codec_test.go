@@ -0,0 +1,184 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+// lineCodec 测试用帧格式: route(2) + len(2) + body, 大端序
+type lineCodec struct{}
+
+func (lineCodec) Encode(pkt *Packet) ([]byte, error) {
+	buf := make([]byte, 4+len(pkt.Data))
+	binary.BigEndian.PutUint16(buf[0:2], uint16(pkt.Route))
+	binary.BigEndian.PutUint16(buf[2:4], uint16(len(pkt.Data)))
+	copy(buf[4:], pkt.Data)
+	return buf, nil
+}
+
+func (c lineCodec) DecodeBytes(data []byte) (*Packet, error) {
+	return c.Decode(bytes.NewReader(data))
+}
+
+func (lineCodec) Decode(r io.Reader) (*Packet, error) {
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint16(head[2:4]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return &Packet{Route: uint64(binary.BigEndian.Uint16(head[0:2])), Data: body}, nil
+}
+
+func init() {
+	RegisterFrameCodec("test-line", func(PacketConfig) (FrameCodec, error) { return lineCodec{}, nil })
+}
+
+// markTransform 测试用变换: 编码时在 body 前加标记字节
+type markTransform byte
+
+func (t markTransform) Encode(pkt *Packet) (*Packet, error) {
+	out := *pkt
+	out.Data = append([]byte{byte(t)}, pkt.Data...)
+	return &out, nil
+}
+
+func (t markTransform) Decode(pkt *Packet) error {
+	if len(pkt.Data) == 0 || pkt.Data[0] != byte(t) {
+		return errors.New("mark mismatch")
+	}
+	pkt.Data = pkt.Data[1:]
+	return nil
+}
+
+func TestFrameCodecRegistry(t *testing.T) {
+	names := FrameCodecs()
+	for _, want := range []string{ProtocolDue, ProtocolFieldDriven, ProtocolPomelo, "test-line"} {
+		if !slices.Contains(names, want) || !HasFrameCodec(want) {
+			t.Fatalf("codecs = %v, missing %s", names, want)
+		}
+	}
+
+	tests := []struct {
+		cfg  PacketConfig
+		want string
+	}{
+		{DefaultPacketConfig(), ProtocolDue},
+		{PacketConfig{Pomelo: &PomeloConfig{}}, ProtocolPomelo},
+		{PacketConfig{FieldDriven: &FieldDrivenConfig{}}, ProtocolFieldDriven},
+		{PacketConfig{Protocol: "test-line", FieldDriven: &FieldDrivenConfig{}}, "test-line"},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.ProtocolName(); got != tt.want {
+			t.Errorf("ProtocolName() = %s, want %s", got, tt.want)
+		}
+	}
+
+	if _, err := NewFrameCodec(PacketConfig{Protocol: "nope"}); err == nil {
+		t.Fatal("expected error for unknown codec")
+	}
+	if _, err := Encode(&Packet{}, PacketConfig{Protocol: ProtocolFieldDriven}); err == nil {
+		t.Fatal("expected error for field-driven codec without config")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for duplicate registration")
+		}
+	}()
+	RegisterFrameCodec(ProtocolDue, nil)
+}
+
+func TestCustomFrameCodec(t *testing.T) {
+	cfg := PacketConfig{Protocol: "test-line", Transforms: []Transform{markTransform('!')}}
+
+	var stream bytes.Buffer
+	for route := uint64(1); route <= 2; route++ {
+		buf, err := Encode(&Packet{Route: route, Data: []byte("hi")}, cfg)
+		if err != nil {
+			t.Fatalf("Encode error: %v", err)
+		}
+		stream.Write(buf)
+	}
+	if !bytes.Equal(stream.Bytes()[:7], []byte{0, 1, 0, 3, '!', 'h', 'i'}) {
+		t.Fatalf("frame = % x", stream.Bytes()[:7])
+	}
+
+	dec := NewDecoder(&stream, cfg)
+	pkt, raw, err := dec.DecodeFrame()
+	if err != nil {
+		t.Fatalf("DecodeFrame error: %v", err)
+	}
+	if pkt.Route != 1 || string(pkt.Data) != "hi" || len(raw) != 7 {
+		t.Fatalf("pkt = %+v, raw = % x", pkt, raw)
+	}
+	pkt, err = dec.Decode()
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if pkt.Route != 2 || string(pkt.Data) != "hi" {
+		t.Fatalf("pkt = %+v", pkt)
+	}
+}
+
+func TestTransformChainOrder(t *testing.T) {
+	// 压缩 → 加密 → 自定义变换: 校验和覆盖密文, 解码时逆序还原
+	cfg := DefaultPacketConfig()
+	cfg.Compression = &CompressionConfig{Algorithm: CompressGzip}
+	cfg.Cipher = &CipherConfig{Algorithm: CipherXOR, Key: []byte{0x33}}
+	cfg.Transforms = []Transform{BodyChecksum{Algorithm: ChecksumCRC32, BigEndian: true}}
+
+	body := bytes.Repeat([]byte("order "), 100)
+	buf, err := Encode(&Packet{Route: 1, Data: body}, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	sealed := buf[headerSize+4 : len(buf)-4]
+	if crc := binary.BigEndian.Uint32(buf[len(buf)-4:]); crc != computeChecksum(ChecksumCRC32, sealed) {
+		t.Fatalf("checksum 0x%x does not cover encrypted body", crc)
+	}
+	if sealed[0]^0x33 != 0x1f {
+		t.Fatalf("body = % x, want gzip encrypted with xor", sealed[:2])
+	}
+
+	pkt, err := DecodeBytes(buf, cfg)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if !bytes.Equal(pkt.Data, body) {
+		t.Fatal("decoded body mismatch")
+	}
+
+	buf[headerSize+4] ^= 0xFF
+	var mismatch *FieldMismatchError
+	if _, err := DecodeBytes(buf, cfg); !errors.As(err, &mismatch) || mismatch.Field != bodyChecksumField || !IsProtocolError(err) {
+		t.Fatalf("err = %v, want body checksum mismatch", err)
+	}
+}
+
+func TestTransformSkipsControlPackets(t *testing.T) {
+	cfg := PacketConfig{Pomelo: &PomeloConfig{}, Transforms: []Transform{markTransform('!')}}
+
+	hs := PomeloEncodeHandshake([]byte(`{"code":200}`))
+	pkt, err := DecodeBytes(hs, cfg)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if string(pkt.Data) != `{"code":200}` {
+		t.Fatalf("handshake body = %q", pkt.Data)
+	}
+
+	raw, err := NewDecoder(bytes.NewReader(hs), cfg).DecodeRaw()
+	if err != nil {
+		t.Fatalf("DecodeRaw error: %v", err)
+	}
+	if !bytes.Equal(raw, hs) {
+		t.Fatalf("raw = % x, want % x", raw, hs)
+	}
+}
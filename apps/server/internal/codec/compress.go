@@ -0,0 +1,238 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// 消息体压缩算法
+const (
+	CompressZlib   = "zlib"
+	CompressGzip   = "gzip"
+	CompressSnappy = "snappy" // snappy block 格式, 无魔数
+	CompressLZ4    = "lz4"    // lz4 frame 格式
+	CompressZstd   = "zstd"
+)
+
+// ErrDecompress 消息体解压失败, 包装 ErrInvalidFrame 使 IsProtocolError 成立
+var ErrDecompress = fmt.Errorf("%w: decompress body", ErrInvalidFrame)
+
+// CompressionConfig 消息体压缩配置, 压缩位于 DynamicEncode 之后、帧编码之前,
+// 解压位于帧解码之后、DynamicDecode 之前
+//
+// 编码时 body 长度超过 Threshold 才压缩, 空 body 始终不压缩;
+// FlagField 非空时压缩与否记录在该 header 字段的 FlagBit 位, 解码时据此判断,
+// 否则解码时对非空 body 解压(Threshold > 0 时仅解压带有算法魔数的 body)
+type CompressionConfig struct {
+	Algorithm string `json:"algorithm"`           // 见 Compress* 常量
+	Threshold int    `json:"threshold,omitempty"` // 压缩阈值(字节), 0 表示非空 body 全部压缩
+	FlagField string `json:"flagField,omitempty"` // 标记压缩的字段名, 仅字段驱动模式可用
+	FlagBit   uint   `json:"flagBit,omitempty"`   // 标记位, 0 为最低位
+}
+
+// Validate 校验压缩配置, fd 为当前的字段驱动配置(非字段驱动模式传 nil)
+func (c *CompressionConfig) Validate(fd *FieldDrivenConfig) error {
+	switch c.Algorithm {
+	case CompressZlib, CompressGzip, CompressSnappy, CompressLZ4, CompressZstd:
+	default:
+		return fmt.Errorf("compression: unknown algorithm %q", c.Algorithm)
+	}
+	if c.Threshold < 0 {
+		return fmt.Errorf("compression: negative threshold %d", c.Threshold)
+	}
+	if c.FlagField == "" {
+		if c.Algorithm == CompressSnappy && c.Threshold > 0 {
+			return fmt.Errorf("compression: snappy body has no magic, threshold needs a flag field")
+		}
+		return nil
+	}
+	if fd == nil {
+		return fmt.Errorf("compression: flag field %s requires field-driven mode", c.FlagField)
+	}
+	for i, f := range fd.Fields {
+		if f.Name != c.FlagField {
+			continue
+		}
+		if !fd.isCustom(i) || f.Role != "" {
+			return fmt.Errorf("compression: flag field %s must be a plain header field", c.FlagField)
+		}
+		if c.FlagBit >= uint(f.width())*8 {
+			return fmt.Errorf("compression: flag bit %d exceeds field %s", c.FlagBit, c.FlagField)
+		}
+		return nil
+	}
+	return fmt.Errorf("compression: flag field %s not found", c.FlagField)
+}
+
+// compressPacket 按配置压缩 pkt 的消息体, 返回待编码的数据包副本; 无需处理时返回 pkt 本身
+func (c PacketConfig) compressPacket(pkt *Packet) (*Packet, error) {
+	cc := c.Compression
+	if cc == nil {
+		return pkt, nil
+	}
+	compressed := len(pkt.Data) > cc.Threshold
+	if !compressed && cc.FlagField == "" {
+		return pkt, nil
+	}
+
+	out := *pkt
+	if compressed {
+		data, err := compressBody(cc.Algorithm, pkt.Data)
+		if err != nil {
+			return nil, fmt.Errorf("compress body: %w", err)
+		}
+		out.Data = data
+	}
+	if cc.FlagField != "" {
+		// 复制一份, 避免修改调用方(如回放节点)的 header 字段
+		out.Fields = make(map[string]uint64, len(pkt.Fields)+1)
+		for k, v := range pkt.Fields {
+			out.Fields[k] = v
+		}
+		bit := uint64(1) << cc.FlagBit
+		if compressed {
+			out.Fields[cc.FlagField] |= bit
+		} else {
+			out.Fields[cc.FlagField] &^= bit
+		}
+	}
+	return &out, nil
+}
+
+// decompressPacket 按配置就地解压 pkt 的消息体
+func (c PacketConfig) decompressPacket(pkt *Packet) error {
+	cc := c.Compression
+	if cc == nil || len(pkt.Data) == 0 {
+		return nil
+	}
+	if cc.FlagField != "" {
+		if pkt.Fields[cc.FlagField]&(1<<cc.FlagBit) == 0 {
+			return nil
+		}
+	} else if cc.Threshold > 0 && !hasCompressMagic(cc.Algorithm, pkt.Data) {
+		return nil
+	}
+
+	data, err := decompressBody(cc.Algorithm, pkt.Data, c.EffectiveMaxFrameSize())
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrDecompress, cc.Algorithm, err)
+	}
+	pkt.Data = data
+	return nil
+}
+
+// hasCompressMagic 判断 data 是否以算法的格式标识开头
+func hasCompressMagic(algo string, data []byte) bool {
+	switch algo {
+	case CompressZlib:
+		// CMF 低 4 位为 8(deflate), 且 CMF<<8|FLG 是 31 的倍数
+		return len(data) >= 2 && data[0]&0x0f == 8 && (uint(data[0])<<8|uint(data[1]))%31 == 0
+	case CompressGzip:
+		return bytes.HasPrefix(data, []byte{0x1f, 0x8b})
+	case CompressLZ4:
+		return bytes.HasPrefix(data, []byte{0x04, 0x22, 0x4d, 0x18})
+	case CompressZstd:
+		return bytes.HasPrefix(data, []byte{0x28, 0xb5, 0x2f, 0xfd})
+	}
+	return false
+}
+
+// compressBody 压缩消息体
+func compressBody(algo string, data []byte) ([]byte, error) {
+	if algo == CompressSnappy {
+		return snappy.Encode(nil, data), nil
+	}
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch algo {
+	case CompressZlib:
+		w = zlib.NewWriter(&buf)
+	case CompressGzip:
+		w = gzip.NewWriter(&buf)
+	case CompressLZ4:
+		w = lz4.NewWriter(&buf)
+	case CompressZstd:
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		w = zw
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q", algo)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// errDecompressedTooLarge 解压结果超出单帧上限
+var errDecompressedTooLarge = errors.New("decompressed body exceeds frame size limit")
+
+// decompressBody 解压消息体, limit > 0 时解压结果不得超过 limit 字节
+func decompressBody(algo string, data []byte, limit int) ([]byte, error) {
+	if algo == CompressSnappy {
+		n, err := snappy.DecodedLen(data)
+		if err != nil {
+			return nil, err
+		}
+		if limit > 0 && n > limit {
+			return nil, errDecompressedTooLarge
+		}
+		return snappy.Decode(nil, data)
+	}
+
+	src := bytes.NewReader(data)
+	var r io.Reader
+	switch algo {
+	case CompressZlib:
+		zr, err := zlib.NewReader(src)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case CompressGzip:
+		gr, err := gzip.NewReader(src)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	case CompressLZ4:
+		r = lz4.NewReader(src)
+	case CompressZstd:
+		zr, err := zstd.NewReader(src)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q", algo)
+	}
+
+	if limit > 0 {
+		r = io.LimitReader(r, int64(limit)+1)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(out) > limit {
+		return nil, errDecompressedTooLarge
+	}
+	return out, nil
+}
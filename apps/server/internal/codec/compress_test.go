@@ -0,0 +1,162 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// flagGatewayConfig 带标志字节的网关帧: len(4) + flags(1) + cmd(2,route) + seq(2), 大端序,
+// body 超过 1KB 时 zlib 压缩并置 flags 的 bit 0
+func flagGatewayConfig(t *testing.T) PacketConfig {
+	t.Helper()
+	fdCfg, err := NewFieldDrivenConfig([]FieldDef{
+		{Name: "len", Bytes: 4},
+		{Name: "flags", Bytes: 1},
+		{Name: "cmd", Bytes: 2, IsRoute: true},
+		{Name: "seq", Bytes: 2, IsSeq: true},
+	})
+	if err != nil {
+		t.Fatalf("NewFieldDrivenConfig error: %v", err)
+	}
+	fdCfg.BigEndian = true
+	cc := &CompressionConfig{Algorithm: CompressZlib, Threshold: 1024, FlagField: "flags"}
+	if err := cc.Validate(fdCfg); err != nil {
+		t.Fatalf("Validate error: %v", err)
+	}
+	return PacketConfig{FieldDriven: fdCfg, Compression: cc}
+}
+
+func TestCompressionAlgorithms(t *testing.T) {
+	body := bytes.Repeat([]byte("flow-packet "), 200)
+	for _, algo := range []string{CompressZlib, CompressGzip, CompressSnappy, CompressLZ4, CompressZstd} {
+		cfg := DefaultPacketConfig()
+		cfg.Compression = &CompressionConfig{Algorithm: algo}
+		if err := cfg.Compression.Validate(nil); err != nil {
+			t.Fatalf("%s: Validate error: %v", algo, err)
+		}
+
+		buf, err := Encode(&Packet{Route: 1, Seq: 2, Data: body}, cfg)
+		if err != nil {
+			t.Fatalf("%s: Encode error: %v", algo, err)
+		}
+		if len(buf) >= len(body) {
+			t.Errorf("%s: frame %d bytes, body not compressed", algo, len(buf))
+		}
+
+		pkt, err := DecodeBytes(buf, cfg)
+		if err != nil {
+			t.Fatalf("%s: DecodeBytes error: %v", algo, err)
+		}
+		if !bytes.Equal(pkt.Data, body) {
+			t.Fatalf("%s: decoded body mismatch", algo)
+		}
+	}
+}
+
+func TestCompressionFlagBit(t *testing.T) {
+	cfg := flagGatewayConfig(t)
+	large := bytes.Repeat([]byte{0x42}, 2048)
+	small := []byte("ok")
+
+	var stream bytes.Buffer
+	for _, body := range [][]byte{large, small} {
+		buf, err := Encode(&Packet{Route: 7, Seq: 1, Data: body, Fields: map[string]uint64{"flags": 0x81}}, cfg)
+		if err != nil {
+			t.Fatalf("Encode error: %v", err)
+		}
+		stream.Write(buf)
+	}
+
+	raw := stream.Bytes()
+	if raw[4] != 0x81 {
+		t.Fatalf("large frame flags = 0x%x, want 0x81", raw[4])
+	}
+
+	dec := NewDecoder(&stream, cfg)
+	pkt, err := dec.Decode()
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if !bytes.Equal(pkt.Data, large) {
+		t.Fatalf("large body = %d bytes, want %d", len(pkt.Data), len(large))
+	}
+
+	pkt, err = dec.Decode()
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if !bytes.Equal(pkt.Data, small) {
+		t.Fatalf("small body = %q, want %q", pkt.Data, small)
+	}
+	// 未压缩时清除标记位, 保留其他位
+	if pkt.Fields["flags"] != 0x80 {
+		t.Fatalf("small frame flags = 0x%x, want 0x80", pkt.Fields["flags"])
+	}
+}
+
+func TestCompressionThresholdWithoutFlag(t *testing.T) {
+	cfg := DefaultPacketConfig()
+	cfg.Compression = &CompressionConfig{Algorithm: CompressZstd, Threshold: 16}
+
+	// 未超过阈值的 body 原样发送, 解码时不带魔数的 body 原样返回
+	buf, err := Encode(&Packet{Route: 1, Data: []byte("short")}, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	pkt, err := DecodeBytes(buf, cfg)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if string(pkt.Data) != "short" {
+		t.Fatalf("body = %q, want short", pkt.Data)
+	}
+}
+
+func TestCompressionErrors(t *testing.T) {
+	cfg := flagGatewayConfig(t)
+
+	// 标记位已置但 body 不是 zlib 数据
+	plain := cfg
+	plain.Compression = nil
+	buf, err := Encode(&Packet{Route: 1, Data: []byte("not zlib"), Fields: map[string]uint64{"flags": 1}}, plain)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if _, err := DecodeBytes(buf, cfg); !errors.Is(err, ErrDecompress) || !IsProtocolError(err) {
+		t.Fatalf("err = %v, want ErrDecompress", err)
+	}
+
+	// 解压结果超出单帧上限
+	limited := cfg
+	limited.MaxFrameSize = 4096
+	buf, err = Encode(&Packet{Route: 1, Data: make([]byte, 8192)}, limited)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if _, err := DecodeBytes(buf, limited); !errors.Is(err, ErrDecompress) {
+		t.Fatalf("err = %v, want ErrDecompress", err)
+	}
+}
+
+func TestCompressionValidation(t *testing.T) {
+	fdCfg := flagGatewayConfig(t).FieldDriven
+	tests := []struct {
+		name string
+		cc   CompressionConfig
+		fd   *FieldDrivenConfig
+	}{
+		{"unknown algorithm", CompressionConfig{Algorithm: "brotli"}, nil},
+		{"negative threshold", CompressionConfig{Algorithm: CompressZlib, Threshold: -1}, nil},
+		{"snappy threshold without flag", CompressionConfig{Algorithm: CompressSnappy, Threshold: 1024}, nil},
+		{"flag without field-driven", CompressionConfig{Algorithm: CompressZlib, FlagField: "flags"}, nil},
+		{"missing flag field", CompressionConfig{Algorithm: CompressZlib, FlagField: "opts"}, fdCfg},
+		{"flag on route field", CompressionConfig{Algorithm: CompressZlib, FlagField: "cmd"}, fdCfg},
+		{"flag bit too wide", CompressionConfig{Algorithm: CompressZlib, FlagField: "flags", FlagBit: 8}, fdCfg},
+	}
+	for _, tt := range tests {
+		if err := tt.cc.Validate(tt.fd); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Due 框架(github.com/dobyte/due)的帧格式: size(4) + header(1: h + extcode) + route + seq + message
+//
+// route 宽度可为 1/2/4 字节, seq 宽度可为 0/1/2/4 字节(0 表示不携带 seq), 默认均为 2 字节.
+// 心跳包 h=1, 服务端下发的心跳可携带 8 字节服务器时间(Unix 纳秒), 解码后保存在 Packet.Data
+const (
+	DueHeartbeatTimeBytes = 8 // 心跳时间字节数
+
+	// DueFieldExtCode 扩展操作码在 Packet.Fields 中的键
+	//
+	// 编码时 Packet.ExtCode 为 0 则取该字段的值; 解码时 extcode 非 0 的包会记录该字段,
+	// 使流程节点可以像自定义帧头字段一样设置和读取 extcode
+	DueFieldExtCode = "extcode"
+)
+
+// NewDueConfig 创建 Due 帧配置
+//
+// 参数：
+//   - routeBytes: route 字段字节数, 1/2/4
+//   - seqBytes: seq 字段字节数, 0/1/2/4
+//
+// 返回值：
+//   - PacketConfig: Protocol 为 ProtocolDue 的帧配置
+//   - error: 字段宽度不合法时返回错误
+func NewDueConfig(routeBytes, seqBytes int) (PacketConfig, error) {
+	cfg := PacketConfig{
+		Protocol:   ProtocolDue,
+		RouteBytes: routeBytes,
+		SeqBytes:   seqBytes,
+	}
+	if err := validateConfig(cfg); err != nil {
+		return PacketConfig{}, fmt.Errorf("due: %w", err)
+	}
+	return cfg, nil
+}
+
+// DueHeartbeat 返回携带服务器时间的心跳包, 供模拟网关下发
+func DueHeartbeat(now time.Time) *Packet {
+	data := make([]byte, DueHeartbeatTimeBytes)
+	binary.BigEndian.PutUint64(data, uint64(now.UnixNano()))
+	return &Packet{Heartbeat: true, Data: data}
+}
+
+// DueHeartbeatTime 返回心跳包携带的服务器时间, 不是心跳包或未携带时间时返回 false
+func DueHeartbeatTime(pkt *Packet) (time.Time, bool) {
+	if pkt == nil || !pkt.Heartbeat || len(pkt.Data) != DueHeartbeatTimeBytes {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(pkt.Data))), true
+}
+
+// dueExtCode 返回编码时使用的 extcode, Packet.ExtCode 优先于 Fields 中的 DueFieldExtCode
+func dueExtCode(pkt *Packet) uint8 {
+	if pkt.ExtCode != 0 {
+		return pkt.ExtCode & 0x7F
+	}
+	return uint8(pkt.Fields[DueFieldExtCode]) & 0x7F
+}
+
+// setDueExtField 解码后将非 0 的 extcode 记录到 Packet.Fields
+func setDueExtField(pkt *Packet) {
+	if pkt.ExtCode == 0 {
+		return
+	}
+	if pkt.Fields == nil {
+		pkt.Fields = make(map[string]uint64, 1)
+	}
+	pkt.Fields[DueFieldExtCode] = uint64(pkt.ExtCode)
+}
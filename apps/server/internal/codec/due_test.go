@@ -0,0 +1,75 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDueHeartbeatTime(t *testing.T) {
+	cfg, err := NewDueConfig(2, 2)
+	if err != nil {
+		t.Fatalf("NewDueConfig error: %v", err)
+	}
+
+	now := time.Unix(1700000000, 123456789)
+	buf, err := Encode(DueHeartbeat(now), cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	want := []byte{0x00, 0x00, 0x00, 0x09, 0x80, 0x17, 0x97, 0x9c, 0xfe, 0x3d, 0x85, 0xcd, 0x15}
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("frame = % x, want % x", buf, want)
+	}
+
+	pkt, err := NewDecoder(bytes.NewReader(buf), cfg).Decode()
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	got, ok := DueHeartbeatTime(pkt)
+	if !ok || !got.Equal(now) {
+		t.Fatalf("heartbeat time = %v, %v, want %v", got, ok, now)
+	}
+
+	// 客户端心跳不带时间
+	buf, _ = Encode(&Packet{Heartbeat: true}, cfg)
+	pkt, err = DecodeBytes(buf, cfg)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if _, ok := DueHeartbeatTime(pkt); ok {
+		t.Fatal("unexpected heartbeat time")
+	}
+}
+
+func TestDueExtCodeField(t *testing.T) {
+	cfg, err := NewDueConfig(4, 0)
+	if err != nil {
+		t.Fatalf("NewDueConfig error: %v", err)
+	}
+
+	buf, err := Encode(&Packet{Route: 0x01020304, Data: []byte{0xAA}, Fields: map[string]uint64{DueFieldExtCode: 5}}, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	want := []byte{0x00, 0x00, 0x00, 0x06, 0x05, 0x01, 0x02, 0x03, 0x04, 0xAA}
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("frame = % x, want % x", buf, want)
+	}
+
+	pkt, err := DecodeBytes(buf, cfg)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if pkt.ExtCode != 5 || pkt.Fields[DueFieldExtCode] != 5 || pkt.Route != 0x01020304 {
+		t.Fatalf("pkt = %+v", pkt)
+	}
+}
+
+func TestNewDueConfigWidths(t *testing.T) {
+	for _, tt := range []struct{ route, seq int }{{3, 2}, {0, 2}, {2, 8}} {
+		if _, err := NewDueConfig(tt.route, tt.seq); err == nil {
+			t.Errorf("NewDueConfig(%d, %d): expected error", tt.route, tt.seq)
+		}
+	}
+}
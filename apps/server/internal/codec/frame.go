@@ -1,11 +1,21 @@
 package codec
 
 import (
+	"bufio"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"strings"
+	"time"
+)
+
+// 字段角色, 未设置角色的字段按 size/route/seq 标记处理, 其余编码为 0
+const (
+	FieldRoleConst     = "const"     // 固定值(魔数、协议版本), 编码时写入 Value, 解码时校验
+	FieldRoleChecksum  = "checksum"  // 校验和, 编码时计算, 解码时校验
+	FieldRoleTimestamp = "timestamp" // 时间戳, 编码时写入当前时间, 超出字段宽度时截断; 设置 MaxSkew 时解码校验与本地时间的偏差
 )
 
 // FieldDef 描述帧中的一个字段
@@ -14,33 +24,161 @@ type FieldDef struct {
 	Bytes   int    `json:"bytes"`
 	IsRoute bool   `json:"isRoute"`
 	IsSeq   bool   `json:"isSeq"`
+
+	Role          string `json:"role,omitempty"`          // 字段角色, 见 FieldRole* 常量
+	Value         uint64 `json:"value,omitempty"`         // const 字段的固定值
+	Checksum      string `json:"checksum,omitempty"`      // checksum 字段的算法, 见 Checksum* 常量
+	ChecksumScope string `json:"checksumScope,omitempty"` // checksum 字段的覆盖范围, 默认 frame
+	TimestampUnit string `json:"timestampUnit,omitempty"` // timestamp 字段的单位: s(默认) | ms
+	MaxSkew       uint64 `json:"maxSkew,omitempty"`       // timestamp 字段与本地时间允许的最大偏差, 单位同 TimestampUnit, 0 表示解码时不校验
+
+	Trailer     bool   `json:"trailer,omitempty"`     // 位于 payload body 之后(校验和、结束标记)
+	LengthField string `json:"lengthField,omitempty"` // 非空时为变长字段, 长度取自该名称的 header 字段, Bytes 被忽略
+	Varint      bool   `json:"varint,omitempty"`      // protobuf 风格 varint 编码, 仅限 header 字段, Bytes 被忽略
 }
 
+// maxFieldBytes 数值字段的最大定长字节数
+const maxFieldBytes = 8
+
+// width 返回字段值的有效字节数, varint 字段视为 8 字节
+func (f FieldDef) width() int {
+	if f.Varint {
+		return maxFieldBytes
+	}
+	return f.Bytes
+}
+
+// validate 检查字段角色相关的配置
+func (f FieldDef) validate() error {
+	if f.MaxSkew != 0 && f.Role != FieldRoleTimestamp {
+		return fmt.Errorf("field %s: max skew requires timestamp role", f.Name)
+	}
+	switch f.Role {
+	case "", FieldRoleTimestamp:
+		if f.Role == FieldRoleTimestamp && f.TimestampUnit != "" && f.TimestampUnit != "s" && f.TimestampUnit != "ms" {
+			return fmt.Errorf("field %s: unknown timestamp unit %q", f.Name, f.TimestampUnit)
+		}
+	case FieldRoleConst:
+		if f.width() < maxFieldBytes && f.Value>>(uint(f.width())*8) != 0 {
+			return fmt.Errorf("field %s: value 0x%x exceeds %d bytes", f.Name, f.Value, f.Bytes)
+		}
+	case FieldRoleChecksum:
+		width, err := checksumWidth(f.Checksum)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		if f.Bytes != width {
+			return fmt.Errorf("field %s: %s checksum needs %d bytes, got %d", f.Name, f.Checksum, width, f.Bytes)
+		}
+		switch f.ChecksumScope {
+		case "", ChecksumScopeFrame, ChecksumScopeHeader, ChecksumScopeBody:
+		default:
+			return fmt.Errorf("field %s: unknown checksum scope %q", f.Name, f.ChecksumScope)
+		}
+	default:
+		return fmt.Errorf("field %s: unknown role %q", f.Name, f.Role)
+	}
+	if f.Role != "" && (f.IsRoute || f.IsSeq) {
+		return fmt.Errorf("field %s: role %s cannot be route or seq", f.Name, f.Role)
+	}
+	if f.LengthField != "" && (f.Role != "" || f.IsRoute || f.IsSeq || f.Varint) {
+		return fmt.Errorf("field %s: variable-length field cannot have a role, route, seq or varint", f.Name)
+	}
+	if f.Varint && (f.Trailer || f.Role == FieldRoleChecksum) {
+		return fmt.Errorf("field %s: varint field cannot be a trailer or checksum", f.Name)
+	}
+	if f.LengthField == "" && !f.Varint && (f.Role != "" || f.IsRoute || f.IsSeq) && (f.Bytes < 1 || f.Bytes > maxFieldBytes) {
+		return fmt.Errorf("field %s: numeric field must be 1-%d bytes, got %d", f.Name, maxFieldBytes, f.Bytes)
+	}
+	return nil
+}
+
+// size 字段的长度语义
+const (
+	SizeModeBody      = "body"      // size = body 长度(默认)
+	SizeModeFrame     = "frame"     // size = 整帧长度(所有字段 + body)
+	SizeModeAfterSize = "afterSize" // size = size 字段之后的字节数(后续 header 字段 + body + trailer 字段)
+)
+
 // FieldDrivenConfig 字段驱动编解码配置
+//
+// 帧格式: header 字段 + payload body + trailer 字段, trailer 字段必须位于字段列表末尾
 type FieldDrivenConfig struct {
 	Fields      []FieldDef
 	SizeIndex   int   // size/len 字段的索引
 	SeqIndex    int   // seq 字段的索引(-1 无)
 	RouteFields []int // route 字段的索引列表
-	HeaderSize  int   // 所有 header 字段(不含 payload body)的总字节数
-	SizeBytes   int   // size 字段的字节数
+	HeaderSize  int   // header 最小字节数(变长字段按 0、varint 字段按 1 计, 不含 payload body)
+	TrailerSize int   // trailer 定长字段的总字节数
+	SizeBytes   int   // size 字段的字节数(varint 时为 0)
 	BigEndian   bool  // true 时使用大端序, 默认小端序
+
+	// SizeMode size 字段覆盖的范围, 为空等同 SizeModeBody
+	SizeMode string
+	// SizeAdjustment 长度修正值, 含义同 Netty LengthFieldBasedFrameDecoder 的 lengthAdjustment:
+	// 覆盖范围的实际长度 = size 值 + SizeAdjustment
+	SizeAdjustment int
+
+	trailerStart int         // 第一个 trailer 字段的索引, 无 trailer 时为 len(Fields)
+	lengthFor    map[int]int // 变长字段索引 → 长度字段索引
+	lengthOf     map[int]int // 长度字段索引 → 变长字段索引
+	checksums    []int       // checksum 字段的索引列表
 }
 
 // NewFieldDrivenConfig 根据字段定义构建配置, 自动检测 size/seq/route 字段索引
 func NewFieldDrivenConfig(fields []FieldDef) (*FieldDrivenConfig, error) {
 	cfg := &FieldDrivenConfig{
-		Fields:    fields,
-		SizeIndex: -1,
-		SeqIndex:  -1,
+		Fields:       fields,
+		SizeIndex:    -1,
+		SeqIndex:     -1,
+		trailerStart: len(fields),
+		lengthFor:    make(map[int]int),
+		lengthOf:     make(map[int]int),
 	}
 
-	totalBytes := 0
+	byName := make(map[string]int, len(fields))
 	for i, f := range fields {
+		if err := f.validate(); err != nil {
+			return nil, fmt.Errorf("field-driven config: %w", err)
+		}
+		if f.Trailer {
+			if cfg.trailerStart == len(fields) {
+				cfg.trailerStart = i
+			}
+		} else if cfg.trailerStart < i {
+			return nil, fmt.Errorf("field-driven config: header field %s follows trailer fields", f.Name)
+		}
+		if f.Role == FieldRoleChecksum {
+			cfg.checksums = append(cfg.checksums, i)
+		}
+
+		if f.LengthField != "" {
+			j, ok := byName[f.LengthField]
+			if !ok {
+				return nil, fmt.Errorf("field-driven config: length field %s of %s must be defined before it", f.LengthField, f.Name)
+			}
+			lf := fields[j]
+			if lf.Trailer || lf.LengthField != "" || lf.Role != "" || lf.IsRoute || lf.IsSeq || j == cfg.SizeIndex || (!lf.Varint && (lf.Bytes < 1 || lf.Bytes > maxFieldBytes)) {
+				return nil, fmt.Errorf("field-driven config: length field %s must be a plain numeric header field", lf.Name)
+			}
+			if _, used := cfg.lengthOf[j]; used {
+				return nil, fmt.Errorf("field-driven config: length field %s used by multiple fields", lf.Name)
+			}
+			cfg.lengthFor[i] = j
+			cfg.lengthOf[j] = i
+			byName[f.Name] = i
+			continue
+		}
+
 		name := strings.ToLower(f.Name)
-		if name == "size" || name == "len" {
+		if f.Role == "" && (name == "size" || name == "len") && !f.Trailer {
+			if !f.Varint && (f.Bytes < 1 || f.Bytes > maxFieldBytes) {
+				return nil, fmt.Errorf("field-driven config: size field must be 1-%d bytes, got %d", maxFieldBytes, f.Bytes)
+			}
 			cfg.SizeIndex = i
-			cfg.SizeBytes = f.Bytes
+			if !f.Varint {
+				cfg.SizeBytes = f.Bytes
+			}
 		}
 		if f.IsSeq {
 			cfg.SeqIndex = i
@@ -48,33 +186,281 @@ func NewFieldDrivenConfig(fields []FieldDef) (*FieldDrivenConfig, error) {
 		if f.IsRoute {
 			cfg.RouteFields = append(cfg.RouteFields, i)
 		}
-		totalBytes += f.Bytes
+		if f.Varint {
+			// varint 字段最少 1 字节
+			cfg.HeaderSize++
+		} else if f.Trailer {
+			cfg.TrailerSize += f.Bytes
+		} else {
+			cfg.HeaderSize += f.Bytes
+		}
+		byName[f.Name] = i
 	}
 
 	if cfg.SizeIndex < 0 {
 		return nil, errors.New("field-driven config: no size/len field found")
 	}
-
-	cfg.HeaderSize = totalBytes
+	if _, used := cfg.lengthOf[cfg.SizeIndex]; used {
+		return nil, errors.New("field-driven config: size field cannot be a length field")
+	}
 	return cfg, nil
 }
 
+// fieldFrame 一帧中各字段的值和原始字节
+type fieldFrame struct {
+	values []uint64 // 数值字段的值
+	parts  [][]byte // 各字段的原始字节, 编码前或尚未读取时为 nil
+}
+
+func newFieldFrame(n int) *fieldFrame {
+	return &fieldFrame{values: make([]uint64, n), parts: make([][]byte, n)}
+}
+
+// fieldLen 返回字段的字节数
+//
+// 已读取的字段取实际长度; 变长字段取其长度字段的值; varint 字段取当前值的编码长度
+func (cfg *FieldDrivenConfig) fieldLen(i int, fr *fieldFrame) int {
+	if fr.parts[i] != nil {
+		return len(fr.parts[i])
+	}
+	if j, ok := cfg.lengthFor[i]; ok {
+		return int(fr.values[j])
+	}
+	if cfg.Fields[i].Varint {
+		return uvarintLen(fr.values[i])
+	}
+	return cfg.Fields[i].Bytes
+}
+
+// fieldsLen 返回 [from, to) 范围内字段的总字节数
+func (cfg *FieldDrivenConfig) fieldsLen(from, to int, fr *fieldFrame) int {
+	n := 0
+	for i := from; i < to; i++ {
+		n += cfg.fieldLen(i, fr)
+	}
+	return n
+}
+
+// sizeCovered 返回 size 值覆盖的非 body 字节数
+func (cfg *FieldDrivenConfig) sizeCovered(fr *fieldFrame) (int, error) {
+	switch cfg.SizeMode {
+	case "", SizeModeBody:
+		return 0, nil
+	case SizeModeFrame:
+		return cfg.fieldsLen(0, len(cfg.Fields), fr), nil
+	case SizeModeAfterSize:
+		return cfg.fieldsLen(cfg.SizeIndex+1, len(cfg.Fields), fr), nil
+	default:
+		return 0, fmt.Errorf("field-driven config: unknown size mode %q", cfg.SizeMode)
+	}
+}
+
+// sizeValue 根据 body 长度计算写入 size 字段的值
+func (cfg *FieldDrivenConfig) sizeValue(bodyLen int, fr *fieldFrame) (uint64, error) {
+	covered, err := cfg.sizeCovered(fr)
+	if err != nil {
+		return 0, err
+	}
+	val := covered + bodyLen - cfg.SizeAdjustment
+	if val < 0 {
+		return 0, fmt.Errorf("size value %d is negative (body %d, adjustment %d)", val, bodyLen, cfg.SizeAdjustment)
+	}
+	return uint64(val), nil
+}
+
+// bodyLength 根据 size 字段的值计算 body 长度
+func (cfg *FieldDrivenConfig) bodyLength(sizeValue uint64, fr *fieldFrame) (int, error) {
+	covered, err := cfg.sizeCovered(fr)
+	if err != nil {
+		return 0, err
+	}
+	if sizeValue > math.MaxInt32 {
+		return 0, &FrameTooLargeError{Size: sizeValue, Limit: math.MaxInt32}
+	}
+	n := int64(sizeValue) + int64(cfg.SizeAdjustment) - int64(covered)
+	if n < 0 {
+		return 0, fmt.Errorf("%w: size %d shorter than header (mode %s, adjustment %d)", ErrInvalidFrame, sizeValue, cfg.SizeMode, cfg.SizeAdjustment)
+	}
+	return int(n), nil
+}
+
+// isCustom 返回字段是否为自定义数值字段(非 size/route/seq/变长字段及其长度字段)
+func (cfg *FieldDrivenConfig) isCustom(i int) bool {
+	f := cfg.Fields[i]
+	if i == cfg.SizeIndex || f.IsRoute || f.IsSeq {
+		return false
+	}
+	_, isVar := cfg.lengthFor[i]
+	_, isLength := cfg.lengthOf[i]
+	return !isVar && !isLength
+}
+
+// fieldValue 计算编码时自定义字段的值, overrides 中的值优先于 timestamp 和未设置角色的字段
+func fieldValue(f FieldDef, overrides map[string]uint64) uint64 {
+	if v, ok := overrides[f.Name]; ok && (f.Role == "" || f.Role == FieldRoleTimestamp) {
+		return v
+	}
+	switch f.Role {
+	case FieldRoleConst:
+		return f.Value
+	case FieldRoleTimestamp:
+		if f.TimestampUnit == "ms" {
+			return uint64(timeNow().UnixMilli())
+		}
+		return uint64(timeNow().Unix())
+	}
+	return 0
+}
+
+// timeNow 当前时间, 测试中可替换
+var timeNow = time.Now
+
+// checksumInput 返回 checksum 字段覆盖的数据片段
+//
+// header 范围为全部 header 字段, frame 范围额外包含 body 和位于该字段之前的 trailer 字段;
+// 所有 checksum 字段自身的字节均被跳过
+func (cfg *FieldDrivenConfig) checksumInput(idx int, fr *fieldFrame, body []byte) [][]byte {
+	scope := cfg.Fields[idx].ChecksumScope
+	var input [][]byte
+	if scope != ChecksumScopeBody {
+		for i := 0; i < cfg.trailerStart; i++ {
+			if cfg.Fields[i].Role != FieldRoleChecksum {
+				input = append(input, fr.parts[i])
+			}
+		}
+	}
+	if scope != ChecksumScopeHeader {
+		input = append(input, body)
+	}
+	if scope == "" || scope == ChecksumScopeFrame {
+		for i := cfg.trailerStart; i < idx; i++ {
+			if cfg.Fields[i].Role != FieldRoleChecksum {
+				input = append(input, fr.parts[i])
+			}
+		}
+	}
+	return input
+}
+
+// writeChecksums 在其他字段写入完成后计算并写入所有 checksum 字段
+func (cfg *FieldDrivenConfig) writeChecksums(fr *fieldFrame, body []byte) {
+	for _, idx := range cfg.checksums {
+		f := cfg.Fields[idx]
+		sum := computeChecksum(f.Checksum, cfg.checksumInput(idx, fr, body)...)
+		cfg.putUintN(fr.parts[idx], uint64(sum), f.Bytes)
+	}
+}
+
+// verifyConsts 校验 [from, to) 范围内的固定值字段和设置了 MaxSkew 的时间戳字段
+func (cfg *FieldDrivenConfig) verifyConsts(fr *fieldFrame, from, to int) error {
+	for i := from; i < to; i++ {
+		f := cfg.Fields[i]
+		switch {
+		case f.Role == FieldRoleConst && fr.values[i] != f.Value:
+			return &FieldMismatchError{Field: f.Name, Got: fr.values[i], Want: f.Value, Err: ErrConstMismatch}
+		case f.Role == FieldRoleTimestamp && f.MaxSkew != 0:
+			now := truncate(fieldValue(f, nil), f.width())
+			if timestampSkew(fr.values[i], now, f.width()) > f.MaxSkew {
+				return &FieldMismatchError{Field: f.Name, Got: fr.values[i], Want: now, Err: ErrTimestampSkew}
+			}
+		}
+	}
+	return nil
+}
+
+// truncate 按字段宽度截断, 与编码时写入的值一致
+func truncate(v uint64, width int) uint64 {
+	if width < maxFieldBytes {
+		return v & (uint64(1)<<(uint(width)*8) - 1)
+	}
+	return v
+}
+
+// timestampSkew 返回两个时间戳的差值, 按字段宽度截断后回绕比较, 取较近的方向
+func timestampSkew(got, now uint64, width int) uint64 {
+	d := truncate(got-now, width)
+	return min(d, truncate(-d, width))
+}
+
+// verifyChecksums 校验所有 checksum 字段
+func (cfg *FieldDrivenConfig) verifyChecksums(fr *fieldFrame, body []byte) error {
+	for _, idx := range cfg.checksums {
+		f := cfg.Fields[idx]
+		want := uint64(computeChecksum(f.Checksum, cfg.checksumInput(idx, fr, body)...))
+		if got := fr.values[idx]; got != want {
+			return &FieldMismatchError{Field: f.Name, Got: got, Want: want, Err: ErrChecksumMismatch}
+		}
+	}
+	return nil
+}
+
 // PacketConfig 协议帧配置
 type PacketConfig struct {
-	RouteBytes  int                // route 字段字节数
-	SeqBytes    int                // seq 字段字节数
-	FieldDriven *FieldDrivenConfig // 非 nil 时启用字段驱动模式
-	Pomelo      *PomeloConfig      // 非 nil 时启用 Pomelo 模式
+	Protocol     string             // 帧编解码器名称, 为空时按 Pomelo/FieldDriven 是否设置推断, 见 ProtocolName
+	RouteBytes   int                // route 字段字节数
+	SeqBytes     int                // seq 字段字节数
+	FieldDriven  *FieldDrivenConfig // 非 nil 时启用字段驱动模式
+	Pomelo       *PomeloConfig      // 非 nil 时启用 Pomelo 模式
+	MaxFrameSize int                // 单帧最大字节数(含帧头), 0 使用 DefaultMaxFrameSize, 负数表示不限制
+	Resync       *ResyncConfig      // 非 nil 时流式解码遇到协议错误会尝试重新同步而不是中断
+	Compression  *CompressionConfig // 非 nil 时压缩/解压消息体
+	Cipher       *CipherConfig      // 非 nil 时加密/解密消息体(或整帧)
+	Transforms   []Transform        // 追加在压缩、加密之后的自定义变换
+	BodyFormat   string             // 消息体格式, 见 Body* 常量, 为空时为 BodyProto; 路由映射可单独覆盖
+	ProtoJSON    ProtoJSONOptions   // proto 消息体字段值与 JSON 友好值互转的选项
+}
+
+// DefaultMaxFrameSize 默认单帧上限 16 MiB
+const DefaultMaxFrameSize = 16 << 20
+
+// EffectiveMaxFrameSize 返回生效的单帧上限, 0 表示不限制
+func (c PacketConfig) EffectiveMaxFrameSize() int {
+	switch {
+	case c.MaxFrameSize > 0:
+		return c.MaxFrameSize
+	case c.MaxFrameSize < 0:
+		return 0
+	default:
+		return DefaultMaxFrameSize
+	}
+}
+
+// checkFrameSize 校验帧长度是否超出上限
+func (c PacketConfig) checkFrameSize(size uint64) error {
+	if limit := c.EffectiveMaxFrameSize(); limit > 0 && size > uint64(limit) {
+		return &FrameTooLargeError{Size: size, Limit: limit}
+	}
+	return nil
+}
+
+// ProtocolName 返回生效的帧编解码器名称
+//
+// 未设置 Protocol 时: Pomelo 非 nil 为 pomelo, FieldDriven 非 nil 为字段驱动, 否则为 Legacy Due
+func (c PacketConfig) ProtocolName() string {
+	switch {
+	case c.Protocol != "":
+		return c.Protocol
+	case c.Pomelo != nil:
+		return ProtocolPomelo
+	case c.FieldDriven != nil:
+		return ProtocolFieldDriven
+	default:
+		return ProtocolDue
+	}
 }
 
 // IsFieldDriven 返回是否使用字段驱动模式
 func (c PacketConfig) IsFieldDriven() bool {
-	return c.FieldDriven != nil
+	return c.ProtocolName() == ProtocolFieldDriven
 }
 
-// IsPomelo 返回是否使用 Pomelo 模式
+// IsPomelo 返回是否使用 Pomelo 协议族(Pomelo、Cherry、Pitaya、Nano), 这些协议共用握手、心跳和控制包处理
 func (c PacketConfig) IsPomelo() bool {
-	return c.Pomelo != nil
+	switch c.ProtocolName() {
+	case ProtocolPomelo, ProtocolCherry, ProtocolPitaya, ProtocolNano:
+		return true
+	}
+	return false
 }
 
 // DefaultPacketConfig 默认帧配置
@@ -92,10 +478,16 @@ const headerSize = 5
 type Packet struct {
 	Heartbeat   bool   // 是否为心跳包(header 中 h=1)
 	ExtCode     uint8  // 扩展操作码 (7 bits)
-	Route       uint32 // 消息路由(仅数据包)
-	Seq         uint32 // 消息序列号(仅数据包)
+	Route       uint64 // 消息路由(仅数据包)
+	Seq         uint64 // 消息序列号(仅数据包)
 	Data        []byte // 消息体(数据包)或心跳时间(心跳包)
 	StringRoute string // Pomelo 字符串路由(非空时优先使用)
+
+	VarFields map[string][]byte // 字段驱动模式下变长字段的内容, 键为字段名
+	// Fields 字段驱动模式下自定义帧头字段(非 size/route/seq/长度字段)的值, 键为字段名
+	// 编码时覆盖未设置角色字段和 timestamp 字段的值; 解码时包含全部自定义字段
+	// Pomelo 扩展标志模式下解码时记录消息标志, 见 PomeloFieldError
+	Fields map[string]uint64
 }
 
 // IsHeartbeat 返回是否为心跳包
@@ -103,33 +495,44 @@ func (p *Packet) IsHeartbeat() bool {
 	return p.Heartbeat
 }
 
-// Encode 将数据包编码为二进制帧
-// 字段驱动模式: 按 FieldDrivenConfig 定义小端编码
-// Legacy Due 模式: size(4B) + header(1B: h=0 + extcode) + route + seq + message data
+// Encode 将数据包编码为二进制帧, 消息体先经过变换链(压缩、加密等), 再由 cfg 选择的 FrameCodec 封帧
 func Encode(pkt *Packet, cfg PacketConfig) ([]byte, error) {
-	if cfg.IsPomelo() {
-		return pomeloEncode(pkt, cfg.Pomelo)
+	fc, err := NewFrameCodec(cfg)
+	if err != nil {
+		return nil, err
 	}
-	if cfg.IsFieldDriven() {
-		return fieldDrivenEncode(pkt, cfg.FieldDriven)
+	if pkt, err = cfg.encodeTransforms(pkt); err != nil {
+		return nil, err
 	}
+	return fc.Encode(pkt)
+}
+
+// ---- Legacy Due 模式 ----
+
+// dueCodec Legacy Due 帧: size(4B) + header(1B: h=0 + extcode) + route + seq + message data, 见 due.go
+type dueCodec struct {
+	cfg PacketConfig
+}
+
+func (c *dueCodec) Encode(pkt *Packet) ([]byte, error) {
 	if pkt.Heartbeat {
 		return encodeHeartbeat(pkt)
 	}
-	return encodeData(pkt, cfg)
+	return encodeData(pkt, c.cfg)
 }
 
-// encodeHeartbeat 编码心跳包
+// encodeHeartbeat 编码心跳包, pkt.Data 非空时作为心跳时间写在 header 之后
 func encodeHeartbeat(pkt *Packet) ([]byte, error) {
-	// size = header(1)
-	payloadSize := 1
+	// size = header(1) + heartbeat time
+	payloadSize := 1 + len(pkt.Data)
 	buf := make([]byte, 4+payloadSize)
 
 	// size(不包含 size 字段自身)
 	binary.BigEndian.PutUint32(buf[0:4], uint32(payloadSize))
 
 	// header: h=1 + extcode
-	buf[4] = 0x80 | (pkt.ExtCode & 0x7F)
+	buf[4] = 0x80 | dueExtCode(pkt)
+	copy(buf[5:], pkt.Data)
 
 	return buf, nil
 }
@@ -148,7 +551,7 @@ func encodeData(pkt *Packet, cfg PacketConfig) ([]byte, error) {
 	binary.BigEndian.PutUint32(buf[0:4], uint32(payloadSize))
 
 	// header: h=0 + extcode
-	buf[4] = pkt.ExtCode & 0x7F
+	buf[4] = dueExtCode(pkt)
 
 	offset := 5
 
@@ -181,40 +584,47 @@ func validateConfig(cfg PacketConfig) error {
 	return nil
 }
 
-// putUintN 以大端序将 val 写入 buf 的前 n 字节
-func putUintN(buf []byte, val uint32, n int) {
-	switch n {
-	case 1:
-		buf[0] = byte(val)
-	case 2:
-		binary.BigEndian.PutUint16(buf, uint16(val))
-	case 4:
-		binary.BigEndian.PutUint32(buf, val)
+// putUintN 以大端序将 val 写入 buf 的前 n 字节, n 超过 8 时不写入
+func putUintN(buf []byte, val uint64, n int) {
+	if n > maxFieldBytes {
+		return
+	}
+	for i := n - 1; i >= 0; i-- {
+		buf[i] = byte(val)
+		val >>= 8
 	}
 }
 
-// readUintN 从 buf 中以大端序读取 n 字节
-func readUintN(buf []byte, n int) uint32 {
-	switch n {
-	case 1:
-		return uint32(buf[0])
-	case 2:
-		return uint32(binary.BigEndian.Uint16(buf))
-	case 4:
-		return binary.BigEndian.Uint32(buf)
-	default:
+// readUintN 从 buf 中以大端序读取 n 字节, n 超过 8 时返回 0
+func readUintN(buf []byte, n int) uint64 {
+	if n > maxFieldBytes {
 		return 0
 	}
+	var val uint64
+	for i := 0; i < n; i++ {
+		val = val<<8 | uint64(buf[i])
+	}
+	return val
 }
 
-// DecodeBytes 从完整的字节数组中解码一个数据包
+// DecodeBytes 从完整的字节数组中解码一个数据包, 消息体逆序经过变换链(解密、解压等)
 func DecodeBytes(data []byte, cfg PacketConfig) (*Packet, error) {
-	if cfg.IsPomelo() {
-		return pomeloDecodeBytes(data, cfg.Pomelo)
+	fc, err := NewFrameCodec(cfg)
+	if err != nil {
+		return nil, err
 	}
-	if cfg.IsFieldDriven() {
-		return fieldDrivenDecodeBytes(data, cfg.FieldDriven)
+	pkt, err := fc.DecodeBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.decodeTransforms(pkt); err != nil {
+		return nil, err
 	}
+	return pkt, nil
+}
+
+func (c *dueCodec) DecodeBytes(data []byte) (*Packet, error) {
+	cfg := c.cfg
 	if len(data) < headerSize {
 		return nil, fmt.Errorf("data too short: %d < %d", len(data), headerSize)
 	}
@@ -233,6 +643,7 @@ func DecodeBytes(data []byte, cfg PacketConfig) (*Packet, error) {
 		Heartbeat: isHeartbeat,
 		ExtCode:   extCode,
 	}
+	setDueExtField(pkt)
 
 	if isHeartbeat {
 		if len(payload) > 1 {
@@ -266,55 +677,123 @@ func DecodeBytes(data []byte, cfg PacketConfig) (*Packet, error) {
 type Decoder struct {
 	reader io.Reader
 	cfg    PacketConfig
+	fc     FrameCodec
+	fcErr  error // cfg 无法创建 FrameCodec 时的错误, 在解码时返回
+	// capture 记录当前帧已读取的原始字节, 仅 DecodeFrame 期间启用
+	capture *captureReader
+	// buffered 启用重新同步时的带缓冲读取器, 用于向前扫描同步标记
+	buffered *bufio.Reader
+}
+
+// captureReader 在启用时记录经过的字节
+type captureReader struct {
+	r   io.Reader
+	on  bool
+	buf []byte
+}
+
+func (c *captureReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if c.on && n > 0 {
+		c.buf = append(c.buf, p[:n]...)
+	}
+	return n, err
 }
 
 // NewDecoder 创建解码器
 func NewDecoder(reader io.Reader, cfg PacketConfig) *Decoder {
-	return &Decoder{reader: reader, cfg: cfg}
+	d := &Decoder{cfg: cfg}
+	if cfg.Resync != nil {
+		d.buffered = bufio.NewReader(reader)
+		reader = d.buffered
+	}
+	d.capture = &captureReader{r: reader}
+	d.reader = d.capture
+	d.fc, d.fcErr = NewFrameCodec(cfg)
+	return d
+}
+
+// SetConfig 更新协议帧配置, 用于密钥交换、握手下发路由字典后对后续帧生效
+//
+// 重新同步依赖创建时的缓冲读取器, 因此 Resync 保持创建时的配置
+func (d *Decoder) SetConfig(cfg PacketConfig) {
+	cfg.Resync = d.cfg.Resync
+	d.cfg = cfg
+	d.fc, d.fcErr = NewFrameCodec(cfg)
+}
+
+// DecodeFrame 解码下一个数据包, 同时返回该帧在流中的原始字节(含帧头)
+//
+// 解码失败时仍返回已读取的字节, 便于代理等场景将其原样转发
+func (d *Decoder) DecodeFrame() (*Packet, []byte, error) {
+	d.capture.buf = d.capture.buf[:0]
+	d.capture.on = true
+	pkt, err := d.Decode()
+	d.capture.on = false
+
+	raw := make([]byte, len(d.capture.buf))
+	copy(raw, d.capture.buf)
+	return pkt, raw, err
 }
 
-// Decode 从流中读取并解码下一个完整的数据包
 // DecodeRaw 从流中读取下一个完整帧, 返回原始字节(含帧头)
 //
-// 仅 Pomelo 模式使用, 避免 decode-reencode 导致控制包信息丢失
+// 返回流中的原样字节而不是解码后重新编码, 避免控制包、时间戳和变长字段等信息丢失
 func (d *Decoder) DecodeRaw() ([]byte, error) {
-	if d.cfg.IsPomelo() {
-		return pomeloDecodeRaw(d.reader)
+	if rd, ok := d.fc.(RawDecoder); ok {
+		raw, err := rd.DecodeRaw(d.reader)
+		if err != nil {
+			return nil, d.recover(err)
+		}
+		return raw, nil
 	}
-	// 非 Pomelo 模式: 解码后重新编码
-	pkt, err := d.Decode()
+	_, raw, err := d.DecodeFrame()
 	if err != nil {
 		return nil, err
 	}
-	return Encode(pkt, d.cfg)
+	return raw, nil
 }
 
+// Decode 从流中读取并解码下一个完整的数据包
+//
+// 帧非法或超出 MaxFrameSize 时返回协议错误; 配置了 Resync 时会先跳过坏数据
+// 直到下一个同步标记, 并返回 *ResyncError, 调用方可以继续调用 Decode
 func (d *Decoder) Decode() (*Packet, error) {
-	if d.cfg.IsPomelo() {
-		raw, err := pomeloDecodeRaw(d.reader)
-		if err != nil {
-			return nil, err
-		}
-		return pomeloDecodeBytes(raw, d.cfg.Pomelo)
+	pkt, err := d.decode()
+	if err == nil {
+		err = d.cfg.decodeTransforms(pkt)
+	}
+	if err != nil {
+		return nil, d.recover(err)
 	}
-	if d.cfg.IsFieldDriven() {
-		return d.decodeFieldDriven()
+	return pkt, nil
+}
+
+func (d *Decoder) decode() (*Packet, error) {
+	if d.fcErr != nil {
+		return nil, d.fcErr
 	}
+	return d.fc.Decode(d.reader)
+}
 
+func (c *dueCodec) Decode(r io.Reader) (*Packet, error) {
 	// 1. 读取 size (4 bytes)
 	sizeBuf := make([]byte, 4)
-	if _, err := io.ReadFull(d.reader, sizeBuf); err != nil {
+	if _, err := io.ReadFull(r, sizeBuf); err != nil {
 		return nil, err
 	}
 	payloadSize := binary.BigEndian.Uint32(sizeBuf)
 
 	if payloadSize == 0 {
-		return nil, errors.New("invalid packet: payload size is 0")
+		return nil, fmt.Errorf("%w: payload size is 0", ErrInvalidFrame)
+	}
+	if err := c.cfg.checkFrameSize(4 + uint64(payloadSize)); err != nil {
+		return nil, err
 	}
 
 	// 2. 读取整个 payload
 	payload := make([]byte, payloadSize)
-	if _, err := io.ReadFull(d.reader, payload); err != nil {
+	if _, err := io.ReadFull(r, payload); err != nil {
 		return nil, fmt.Errorf("read payload: %w", err)
 	}
 
@@ -327,6 +806,7 @@ func (d *Decoder) Decode() (*Packet, error) {
 		Heartbeat: isHeartbeat,
 		ExtCode:   extCode,
 	}
+	setDueExtField(pkt)
 
 	if isHeartbeat {
 		// 心跳包: 剩余数据为 heartbeat time(如有)
@@ -338,17 +818,17 @@ func (d *Decoder) Decode() (*Packet, error) {
 
 	// 4. 数据包: 解析 route + seq + message data
 	offset := 1
-	minSize := 1 + d.cfg.RouteBytes + d.cfg.SeqBytes
+	minSize := 1 + c.cfg.RouteBytes + c.cfg.SeqBytes
 	if int(payloadSize) < minSize {
-		return nil, fmt.Errorf("invalid data packet: payload size %d < minimum %d", payloadSize, minSize)
+		return nil, fmt.Errorf("%w: payload size %d < minimum %d", ErrInvalidFrame, payloadSize, minSize)
 	}
 
-	pkt.Route = readUintN(payload[offset:], d.cfg.RouteBytes)
-	offset += d.cfg.RouteBytes
+	pkt.Route = readUintN(payload[offset:], c.cfg.RouteBytes)
+	offset += c.cfg.RouteBytes
 
-	if d.cfg.SeqBytes > 0 {
-		pkt.Seq = readUintN(payload[offset:], d.cfg.SeqBytes)
-		offset += d.cfg.SeqBytes
+	if c.cfg.SeqBytes > 0 {
+		pkt.Seq = readUintN(payload[offset:], c.cfg.SeqBytes)
+		offset += c.cfg.SeqBytes
 	}
 
 	if offset < len(payload) {
@@ -360,59 +840,71 @@ func (d *Decoder) Decode() (*Packet, error) {
 
 // ---- 字段驱动模式(小端序) ----
 
-// putUintNLE 以小端序将 val 写入 buf 的前 n 字节
-func putUintNLE(buf []byte, val uint32, n int) {
-	switch n {
-	case 1:
-		buf[0] = byte(val)
-	case 2:
-		binary.LittleEndian.PutUint16(buf, uint16(val))
-	case 4:
-		binary.LittleEndian.PutUint32(buf, val)
-	}
-}
-
-// readUintNLE 从 buf 中以小端序读取 n 字节
-func readUintNLE(buf []byte, n int) uint32 {
-	switch n {
-	case 1:
-		return uint32(buf[0])
-	case 2:
-		return uint32(binary.LittleEndian.Uint16(buf))
-	case 4:
-		return binary.LittleEndian.Uint32(buf)
-	default:
+// putUintNLE 以小端序将 val 写入 buf 的前 n 字节, n 超过 8 时不写入
+func putUintNLE(buf []byte, val uint64, n int) {
+	if n > maxFieldBytes {
+		return
+	}
+	for i := 0; i < n; i++ {
+		buf[i] = byte(val)
+		val >>= 8
+	}
+}
+
+// readUintNLE 从 buf 中以小端序读取 n 字节, n 超过 8 时返回 0
+func readUintNLE(buf []byte, n int) uint64 {
+	if n > maxFieldBytes {
 		return 0
 	}
+	var val uint64
+	for i := n - 1; i >= 0; i-- {
+		val = val<<8 | uint64(buf[i])
+	}
+	return val
+}
+
+// uvarintLen 返回 v 的 varint 编码长度
+func uvarintLen(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+	return n
 }
 
 // splitRouteToFields 将组合路由值拆分为各路由字段值
 // 逆向前端 combineRoute: 从右往左按字段字节数依次提取
-func splitRouteToFields(route uint32, cfg *FieldDrivenConfig) map[int]uint32 {
-	result := make(map[int]uint32)
+func splitRouteToFields(route uint64, cfg *FieldDrivenConfig, fr *fieldFrame) {
 	value := route
 	for i := len(cfg.RouteFields) - 1; i >= 0; i-- {
 		idx := cfg.RouteFields[i]
-		f := cfg.Fields[idx]
-		mask := uint32((1 << (f.Bytes * 8)) - 1)
-		result[idx] = value & mask
-		value >>= uint(f.Bytes * 8)
+		bits := uint(cfg.Fields[idx].width() * 8)
+		fr.values[idx] = value & widthMask(bits)
+		value >>= bits
 	}
-	return result
 }
 
-// combineRouteFromFields 将各路由字段值组合为单一 uint32
-func combineRouteFromFields(values map[int]uint32, cfg *FieldDrivenConfig) uint32 {
-	var result uint32
+// combineRouteFromFields 将各路由字段值组合为单一 uint64
+func combineRouteFromFields(cfg *FieldDrivenConfig, fr *fieldFrame) uint64 {
+	var result uint64
 	for _, idx := range cfg.RouteFields {
-		f := cfg.Fields[idx]
-		result = (result << uint(f.Bytes*8)) | (values[idx] & uint32((1<<(f.Bytes*8))-1))
+		bits := uint(cfg.Fields[idx].width() * 8)
+		result = (result << bits) | (fr.values[idx] & widthMask(bits))
 	}
 	return result
 }
 
+// widthMask 返回低 bits 位的掩码
+func widthMask(bits uint) uint64 {
+	if bits >= 64 {
+		return math.MaxUint64
+	}
+	return 1<<bits - 1
+}
+
 // putUintNFD 根据 BigEndian 标志选择字节序写入
-func (cfg *FieldDrivenConfig) putUintN(buf []byte, val uint32, n int) {
+func (cfg *FieldDrivenConfig) putUintN(buf []byte, val uint64, n int) {
 	if cfg.BigEndian {
 		putUintN(buf, val, n)
 	} else {
@@ -421,7 +913,7 @@ func (cfg *FieldDrivenConfig) putUintN(buf []byte, val uint32, n int) {
 }
 
 // readUintNFD 根据 BigEndian 标志选择字节序读取
-func (cfg *FieldDrivenConfig) readUintN(buf []byte, n int) uint32 {
+func (cfg *FieldDrivenConfig) readUintN(buf []byte, n int) uint64 {
 	if cfg.BigEndian {
 		return readUintN(buf, n)
 	}
@@ -429,112 +921,266 @@ func (cfg *FieldDrivenConfig) readUintN(buf []byte, n int) uint32 {
 }
 
 // fieldDrivenEncode 字段驱动编码
-// 帧格式: header fields(按字段定义) + payload body
-// size 字段的值 = payload body 的字节数
+// 帧格式: header fields(按字段定义) + payload body + trailer fields
+// size 字段的值由 SizeMode 和 SizeAdjustment 决定, 默认为 payload body 的字节数
 func fieldDrivenEncode(pkt *Packet, cfg *FieldDrivenConfig) ([]byte, error) {
-	totalSize := cfg.HeaderSize + len(pkt.Data)
-	buf := make([]byte, totalSize)
+	fr := newFieldFrame(len(cfg.Fields))
+	splitRouteToFields(pkt.Route, cfg, fr)
+	for i, f := range cfg.Fields {
+		if v, ok := cfg.lengthOf[i]; ok {
+			fr.values[i] = uint64(len(pkt.VarFields[cfg.Fields[v].Name]))
+		} else if f.IsSeq {
+			fr.values[i] = pkt.Seq
+		} else if cfg.isCustom(i) {
+			fr.values[i] = fieldValue(f, pkt.Fields)
+		}
+	}
+
+	// varint size 字段覆盖自身时, 其长度随值变化, 迭代到稳定
+	for {
+		sizeValue, err := cfg.sizeValue(len(pkt.Data), fr)
+		if err != nil {
+			return nil, err
+		}
+		prev := fr.values[cfg.SizeIndex]
+		fr.values[cfg.SizeIndex] = sizeValue
+		if !cfg.Fields[cfg.SizeIndex].Varint || uvarintLen(prev) == uvarintLen(sizeValue) {
+			break
+		}
+	}
 
-	routeValues := splitRouteToFields(pkt.Route, cfg)
+	headerLen := cfg.fieldsLen(0, cfg.trailerStart, fr)
+	buf := make([]byte, headerLen+len(pkt.Data)+cfg.fieldsLen(cfg.trailerStart, len(cfg.Fields), fr))
 
 	offset := 0
-	for i, f := range cfg.Fields {
-		var val uint32
-		if i == cfg.SizeIndex {
-			val = uint32(len(pkt.Data))
-		} else if f.IsRoute {
-			val = routeValues[i]
-		} else if f.IsSeq {
-			val = pkt.Seq
+	writeField := func(i int) {
+		n := cfg.fieldLen(i, fr)
+		part := buf[offset : offset+n]
+		if _, ok := cfg.lengthFor[i]; ok {
+			copy(part, pkt.VarFields[cfg.Fields[i].Name])
+		} else if cfg.Fields[i].Varint {
+			binary.PutUvarint(part, fr.values[i])
+		} else {
+			cfg.putUintN(part, fr.values[i], n)
 		}
-		cfg.putUintN(buf[offset:], val, f.Bytes)
-		offset += f.Bytes
+		fr.parts[i] = part
+		offset += n
+	}
+	for i := 0; i < cfg.trailerStart; i++ {
+		writeField(i)
 	}
 
 	// payload body
-	copy(buf[offset:], pkt.Data)
+	body := buf[offset : offset+len(pkt.Data)]
+	copy(body, pkt.Data)
+	offset += len(pkt.Data)
+
+	for i := cfg.trailerStart; i < len(cfg.Fields); i++ {
+		writeField(i)
+	}
+	cfg.writeChecksums(fr, body)
 
 	return buf, nil
 }
 
-// fieldDrivenDecodeBytes 从完整字节数组中解码一个字段驱动的数据包
-func fieldDrivenDecodeBytes(data []byte, cfg *FieldDrivenConfig) (*Packet, error) {
-	if len(data) < cfg.HeaderSize {
-		return nil, fmt.Errorf("data too short: %d < %d", len(data), cfg.HeaderSize)
+// decode 按字段定义依次读取一帧
+//
+// read 返回接下来 n 个字节; checkSize 在读取 body 前检查整帧长度, 可为 nil
+func (cfg *FieldDrivenConfig) decode(read func(n int) ([]byte, error), checkSize func(size uint64) error) (*Packet, error) {
+	fr := newFieldFrame(len(cfg.Fields))
+
+	// checkLength 长度字段的值由对端控制, 转为 int 前须限制范围, 否则 8 字节或 varint 长度会溢出为负数
+	checkLength := func(i int) error {
+		if _, ok := cfg.lengthOf[i]; ok && fr.values[i] > math.MaxInt32 {
+			return fmt.Errorf("%w: length field %s value %d exceeds %d", ErrInvalidFrame, cfg.Fields[i].Name, fr.values[i], math.MaxInt32)
+		}
+		return nil
 	}
 
-	// 解析 header 字段
-	routeValues := make(map[int]uint32)
-	var seq uint32
-	var sizeValue uint32
+	// readVarint 逐字节读取 varint 字段
+	readVarint := func(i int) error {
+		var raw []byte
+		for {
+			b, err := read(1)
+			if err != nil {
+				if len(raw) > 0 && err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return err
+			}
+			raw = append(raw, b[0])
+			if b[0] < 0x80 {
+				break
+			}
+			if len(raw) >= binary.MaxVarintLen64 {
+				return fmt.Errorf("%w: varint field %s overflows 64 bits", ErrInvalidFrame, cfg.Fields[i].Name)
+			}
+		}
+		v, n := binary.Uvarint(raw)
+		if n <= 0 {
+			return fmt.Errorf("%w: varint field %s overflows 64 bits", ErrInvalidFrame, cfg.Fields[i].Name)
+		}
+		fr.values[i], fr.parts[i] = v, raw
+		return checkLength(i)
+	}
 
-	offset := 0
-	for i, f := range cfg.Fields {
-		val := cfg.readUintN(data[offset:], f.Bytes)
-		if i == cfg.SizeIndex {
-			sizeValue = val
-		} else if f.IsRoute {
-			routeValues[i] = val
-		} else if f.IsSeq {
-			seq = val
+	// readFields 读取 [from, to) 范围的字段, 长度已知的相邻字段合并为一次读取
+	readFields := func(from, to int) error {
+		for i := from; i < to; {
+			if cfg.Fields[i].Varint {
+				if err := readVarint(i); err != nil {
+					return err
+				}
+				i++
+				continue
+			}
+			j, n := i, 0
+			for j < to && !cfg.Fields[j].Varint {
+				// 变长字段的长度字段在本批次中, 需等本批次读完
+				if k, ok := cfg.lengthFor[j]; ok && k >= i {
+					break
+				}
+				n += cfg.fieldLen(j, fr)
+				j++
+			}
+			if checkSize != nil {
+				if err := checkSize(uint64(n)); err != nil {
+					return err
+				}
+			}
+			buf, err := read(n)
+			if err != nil {
+				return err
+			}
+			for k := i; k < j; k++ {
+				l := cfg.fieldLen(k, fr)
+				fr.parts[k] = buf[:l]
+				if _, ok := cfg.lengthFor[k]; !ok {
+					fr.values[k] = cfg.readUintN(fr.parts[k], l)
+					if err := checkLength(k); err != nil {
+						return err
+					}
+				}
+				buf = buf[l:]
+			}
+			i = j
 		}
-		offset += f.Bytes
+		return nil
 	}
 
-	if cfg.HeaderSize+int(sizeValue) > len(data) {
-		return nil, fmt.Errorf("incomplete packet: need %d bytes, have %d", cfg.HeaderSize+int(sizeValue), len(data))
+	// 1. 读取 header 字段
+	if err := readFields(0, cfg.trailerStart); err != nil {
+		return nil, err
+	}
+	// 魔数等固定值先于 body 校验, 避免按错误的 size 读取
+	if err := cfg.verifyConsts(fr, 0, cfg.trailerStart); err != nil {
+		return nil, err
+	}
+
+	// 2. 读取 payload body
+	bodyLen, err := cfg.bodyLength(fr.values[cfg.SizeIndex], fr)
+	if err != nil {
+		return nil, err
+	}
+	if checkSize != nil {
+		frameLen := uint64(cfg.fieldsLen(0, len(cfg.Fields), fr)) + uint64(bodyLen)
+		if err := checkSize(frameLen); err != nil {
+			return nil, err
+		}
+	}
+	var body []byte
+	if bodyLen > 0 {
+		if body, err = read(bodyLen); err != nil {
+			return nil, fmt.Errorf("read payload: %w", err)
+		}
+	}
+
+	// 3. 读取 trailer 字段并校验
+	if err := readFields(cfg.trailerStart, len(cfg.Fields)); err != nil {
+		return nil, fmt.Errorf("read trailer: %w", err)
+	}
+	if err := cfg.verifyConsts(fr, cfg.trailerStart, len(cfg.Fields)); err != nil {
+		return nil, err
+	}
+	if err := cfg.verifyChecksums(fr, body); err != nil {
+		return nil, err
 	}
 
 	pkt := &Packet{
-		Route: combineRouteFromFields(routeValues, cfg),
-		Seq:   seq,
+		Route: combineRouteFromFields(cfg, fr),
+		Data:  body,
 	}
-	if sizeValue > 0 {
-		pkt.Data = data[cfg.HeaderSize : cfg.HeaderSize+int(sizeValue)]
+	if cfg.SeqIndex >= 0 {
+		pkt.Seq = fr.values[cfg.SeqIndex]
+	}
+	for i, f := range cfg.Fields {
+		if cfg.isCustom(i) {
+			if pkt.Fields == nil {
+				pkt.Fields = make(map[string]uint64)
+			}
+			pkt.Fields[f.Name] = fr.values[i]
+		}
+	}
+	if len(cfg.lengthFor) > 0 {
+		pkt.VarFields = make(map[string][]byte, len(cfg.lengthFor))
+		for idx := range cfg.lengthFor {
+			pkt.VarFields[cfg.Fields[idx].Name] = fr.parts[idx]
+		}
 	}
 	return pkt, nil
 }
 
-// decodeFieldDriven 字段驱动流式解码
-func (d *Decoder) decodeFieldDriven() (*Packet, error) {
-	cfg := d.cfg.FieldDriven
+// fieldDrivenDecodeBytes 从完整字节数组中解码一个字段驱动的数据包
+func fieldDrivenDecodeBytes(data []byte, cfg *FieldDrivenConfig) (*Packet, error) {
+	offset := 0
+	return cfg.decode(func(n int) ([]byte, error) {
+		if offset+n > len(data) {
+			return nil, fmt.Errorf("incomplete packet: need %d bytes, have %d", offset+n, len(data))
+		}
+		b := data[offset : offset+n]
+		offset += n
+		return b, nil
+	}, nil)
+}
 
-	// 1. 读取整个 header
-	headerBuf := make([]byte, cfg.HeaderSize)
-	if _, err := io.ReadFull(d.reader, headerBuf); err != nil {
+// fieldDrivenCodec 字段驱动帧, 启用整帧加密时负责 size 字段之后字节的加解密
+type fieldDrivenCodec struct {
+	cfg PacketConfig
+}
+
+func (c *fieldDrivenCodec) Encode(pkt *Packet) ([]byte, error) {
+	buf, err := fieldDrivenEncode(pkt, c.cfg.FieldDriven)
+	if err != nil {
 		return nil, err
 	}
+	return c.cfg.cryptFrame(buf, true)
+}
 
-	// 2. 从 header 中提取各字段值
-	routeValues := make(map[int]uint32)
-	var seq uint32
-	var sizeValue uint32
+func (c *fieldDrivenCodec) DecodeBytes(data []byte) (*Packet, error) {
+	data, err := c.cfg.cryptFrame(data, false)
+	if err != nil {
+		return nil, err
+	}
+	return fieldDrivenDecodeBytes(data, c.cfg.FieldDriven)
+}
 
+func (c *fieldDrivenCodec) Decode(r io.Reader) (*Packet, error) {
+	// 整帧加密时 size 字段之后的字节边读边解密
+	stream, err := c.cfg.Cipher.frameStream(false)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	fd := c.cfg.FieldDriven
 	offset := 0
-	for i, f := range cfg.Fields {
-		val := cfg.readUintN(headerBuf[offset:], f.Bytes)
-		if i == cfg.SizeIndex {
-			sizeValue = val
-		} else if f.IsRoute {
-			routeValues[i] = val
-		} else if f.IsSeq {
-			seq = val
+	return fd.decode(func(n int) ([]byte, error) {
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
 		}
-		offset += f.Bytes
-	}
-
-	// 3. 读取 payload body
-	var body []byte
-	if sizeValue > 0 {
-		body = make([]byte, sizeValue)
-		if _, err := io.ReadFull(d.reader, body); err != nil {
-			return nil, fmt.Errorf("read payload: %w", err)
+		if start := max(fd.SizeBytes-offset, 0); stream != nil && start < n {
+			stream.XORKeyStream(buf[start:], buf[start:])
 		}
-	}
-
-	return &Packet{
-		Route: combineRouteFromFields(routeValues, cfg),
-		Seq:   seq,
-		Data:  body,
-	}, nil
+		offset += n
+		return buf, nil
+	}, c.cfg.checkFrameSize)
 }
@@ -3,8 +3,10 @@ package codec
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"io"
 	"testing"
+	"testing/iotest"
 )
 
 func TestEncodeDataPacket(t *testing.T) {
@@ -269,6 +271,38 @@ func TestDecodeEOF(t *testing.T) {
 	}
 }
 
+func TestDecodeFrameReturnsRawBytes(t *testing.T) {
+	cfg := DefaultPacketConfig()
+	first, _ := Encode(&Packet{Route: 1, Seq: 1, Data: []byte("a")}, cfg)
+	second, _ := Encode(&Packet{Route: 2, Seq: 2, Data: []byte("bc")}, cfg)
+	bad := []byte{0, 0, 0, 0}
+
+	stream := append(append(append([]byte{}, first...), second...), bad...)
+	decoder := NewDecoder(bytes.NewReader(stream), cfg)
+
+	for i, want := range [][]byte{first, second} {
+		pkt, raw, err := decoder.DecodeFrame()
+		if err != nil {
+			t.Fatalf("frame %d: DecodeFrame error: %v", i, err)
+		}
+		if pkt.Route != uint64(i+1) {
+			t.Fatalf("frame %d: route = %d, want %d", i, pkt.Route, i+1)
+		}
+		if !bytes.Equal(raw, want) {
+			t.Fatalf("frame %d: raw = %x, want %x", i, raw, want)
+		}
+	}
+
+	// 解码失败时仍返回已消费的字节
+	_, raw, err := decoder.DecodeFrame()
+	if err == nil {
+		t.Fatal("expected error for zero payload size")
+	}
+	if !bytes.Equal(raw, bad) {
+		t.Fatalf("raw on error = %x, want %x", raw, bad)
+	}
+}
+
 // ---- 字段驱动模式测试 ----
 
 // antnetFields 返回 Antnet 协议帧字段定义
@@ -544,18 +578,19 @@ func TestFieldDrivenRouteSplitCombine(t *testing.T) {
 	fdCfg, _ := NewFieldDrivenConfig(antnetFields())
 
 	// cmd=3, act=7 → route = (3<<8)|7 = 775
-	route := uint32(775)
-	split := splitRouteToFields(route, fdCfg)
+	route := uint64(775)
+	fr := newFieldFrame(len(fdCfg.Fields))
+	splitRouteToFields(route, fdCfg, fr)
 
 	// index 2 = cmd, index 3 = act
-	if split[2] != 3 {
-		t.Fatalf("cmd = %d, want 3", split[2])
+	if fr.values[2] != 3 {
+		t.Fatalf("cmd = %d, want 3", fr.values[2])
 	}
-	if split[3] != 7 {
-		t.Fatalf("act = %d, want 7", split[3])
+	if fr.values[3] != 7 {
+		t.Fatalf("act = %d, want 7", fr.values[3])
 	}
 
-	combined := combineRouteFromFields(split, fdCfg)
+	combined := combineRouteFromFields(fdCfg, fr)
 	if combined != route {
 		t.Fatalf("combined = %d, want %d", combined, route)
 	}
@@ -665,6 +700,450 @@ func TestCherryStreamDecode(t *testing.T) {
 	}
 }
 
+func TestFieldDrivenSizeModes(t *testing.T) {
+	// antnet header = 12 bytes, len 字段位于偏移 0, 之后还有 8 bytes header
+	tests := []struct {
+		name       string
+		mode       string
+		adjustment int
+		wantSize   uint32
+	}{
+		{"body", SizeModeBody, 0, 3},
+		{"frame", SizeModeFrame, 0, 15},
+		{"afterSize", SizeModeAfterSize, 0, 11},
+		{"frame with adjustment", SizeModeFrame, 4, 11},
+		{"body with negative adjustment", "", -2, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := antnetConfig()
+			cfg.FieldDriven.SizeMode = tt.mode
+			cfg.FieldDriven.SizeAdjustment = tt.adjustment
+
+			pkt := &Packet{Route: 258, Seq: 7, Data: []byte{0x01, 0x02, 0x03}}
+			buf, err := Encode(pkt, cfg)
+			if err != nil {
+				t.Fatalf("Encode error: %v", err)
+			}
+			if len(buf) != 15 {
+				t.Fatalf("buf len = %d, want 15", len(buf))
+			}
+			if size := binary.LittleEndian.Uint32(buf[0:4]); size != tt.wantSize {
+				t.Fatalf("size = %d, want %d", size, tt.wantSize)
+			}
+
+			decoded, err := DecodeBytes(buf, cfg)
+			if err != nil {
+				t.Fatalf("DecodeBytes error: %v", err)
+			}
+			if !bytes.Equal(decoded.Data, pkt.Data) {
+				t.Fatalf("DecodeBytes data = %x, want %x", decoded.Data, pkt.Data)
+			}
+
+			// 流式解码两个粘连的帧
+			decoder := NewDecoder(bytes.NewReader(append(append([]byte{}, buf...), buf...)), cfg)
+			for i := 0; i < 2; i++ {
+				got, err := decoder.Decode()
+				if err != nil {
+					t.Fatalf("Decode %d error: %v", i, err)
+				}
+				if got.Route != 258 || got.Seq != 7 || !bytes.Equal(got.Data, pkt.Data) {
+					t.Fatalf("Decode %d = route %d seq %d data %x", i, got.Route, got.Seq, got.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestFieldDrivenSizeShorterThanHeader(t *testing.T) {
+	cfg := antnetConfig()
+	cfg.FieldDriven.SizeMode = SizeModeFrame
+
+	// size=4 小于 12 字节 header
+	buf := make([]byte, 12)
+	binary.LittleEndian.PutUint32(buf[0:4], 4)
+
+	if _, err := DecodeBytes(buf, cfg); !IsProtocolError(err) {
+		t.Fatalf("DecodeBytes err = %v, want protocol error", err)
+	}
+	if _, err := NewDecoder(bytes.NewReader(buf), cfg).Decode(); !IsProtocolError(err) {
+		t.Fatalf("Decode err = %v, want protocol error", err)
+	}
+
+	// 编码时 size 值不能为负
+	cfg.FieldDriven.SizeMode = SizeModeBody
+	cfg.FieldDriven.SizeAdjustment = 10
+	if _, err := Encode(&Packet{Route: 258, Data: []byte("x")}, cfg); err == nil {
+		t.Fatal("expected error for negative size value")
+	}
+}
+
+func TestFieldDrivenTrailerChecksum(t *testing.T) {
+	// magic(2, 0xCAFE) + len(4) + cmd(2,route) + seq(2) + body + crc32(4, trailer), 大端序
+	fdCfg, err := NewFieldDrivenConfig([]FieldDef{
+		{Name: "magic", Bytes: 2, Role: FieldRoleConst, Value: 0xCAFE},
+		{Name: "len", Bytes: 4},
+		{Name: "cmd", Bytes: 2, IsRoute: true},
+		{Name: "seq", Bytes: 2, IsSeq: true},
+		{Name: "crc", Bytes: 4, Role: FieldRoleChecksum, Checksum: ChecksumCRC32, Trailer: true},
+	})
+	if err != nil {
+		t.Fatalf("NewFieldDrivenConfig error: %v", err)
+	}
+	fdCfg.BigEndian = true
+	fdCfg.SizeMode = SizeModeFrame
+	cfg := PacketConfig{FieldDriven: fdCfg}
+
+	if fdCfg.HeaderSize != 10 || fdCfg.TrailerSize != 4 {
+		t.Fatalf("HeaderSize/TrailerSize = %d/%d, want 10/4", fdCfg.HeaderSize, fdCfg.TrailerSize)
+	}
+
+	pkt := &Packet{Route: 1001, Seq: 9, Data: []byte("hello")}
+	buf, err := Encode(pkt, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if len(buf) != 19 {
+		t.Fatalf("buf len = %d, want 19", len(buf))
+	}
+	if size := binary.BigEndian.Uint32(buf[2:6]); size != 19 {
+		t.Fatalf("size = %d, want 19", size)
+	}
+	if !bytes.Equal(buf[10:15], []byte("hello")) {
+		t.Fatalf("body = %q, want hello", buf[10:15])
+	}
+	if crc := binary.BigEndian.Uint32(buf[15:]); crc != computeChecksum(ChecksumCRC32, buf[:15]) {
+		t.Fatalf("crc = 0x%x, want crc32 of header + body", crc)
+	}
+
+	decoded, err := DecodeBytes(buf, cfg)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if decoded.Route != 1001 || decoded.Seq != 9 || !bytes.Equal(decoded.Data, pkt.Data) {
+		t.Fatalf("decoded = %+v", decoded)
+	}
+
+	// 粘包 + 逐字节读取
+	stream := append(append([]byte{}, buf...), buf...)
+	decoder := NewDecoder(iotest.OneByteReader(bytes.NewReader(stream)), cfg)
+	for i := 0; i < 2; i++ {
+		got, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("Decode %d error: %v", i, err)
+		}
+		if !bytes.Equal(got.Data, pkt.Data) {
+			t.Fatalf("Decode %d data = %q", i, got.Data)
+		}
+	}
+
+	buf[12] ^= 0x01
+	if _, err := DecodeBytes(buf, cfg); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("corrupted DecodeBytes err = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestFieldDrivenVarFields(t *testing.T) {
+	// len(2) + cmd(1,route) + klen(1) + key(klen) + body + end(1, 0x0A, trailer)
+	fdCfg, err := NewFieldDrivenConfig([]FieldDef{
+		{Name: "len", Bytes: 2},
+		{Name: "cmd", Bytes: 1, IsRoute: true},
+		{Name: "klen", Bytes: 1},
+		{Name: "key", LengthField: "klen"},
+		{Name: "end", Bytes: 1, Role: FieldRoleConst, Value: 0x0A, Trailer: true},
+	})
+	if err != nil {
+		t.Fatalf("NewFieldDrivenConfig error: %v", err)
+	}
+	fdCfg.SizeMode = SizeModeAfterSize
+	cfg := PacketConfig{FieldDriven: fdCfg}
+
+	pkt := &Packet{Route: 5, Data: []byte("body"), VarFields: map[string][]byte{"key": []byte("token")}}
+	buf, err := Encode(pkt, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	// size = cmd(1) + klen(1) + key(5) + body(4) + end(1) = 12
+	want := []byte{12, 0, 5, 5, 't', 'o', 'k', 'e', 'n', 'b', 'o', 'd', 'y', 0x0A}
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("buf = %v, want %v", buf, want)
+	}
+
+	decoded, err := DecodeBytes(buf, cfg)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if decoded.Route != 5 || !bytes.Equal(decoded.Data, []byte("body")) || string(decoded.VarFields["key"]) != "token" {
+		t.Fatalf("decoded = %+v", decoded)
+	}
+
+	decoder := NewDecoder(iotest.OneByteReader(bytes.NewReader(append(append([]byte{}, buf...), buf...))), cfg)
+	for i := 0; i < 2; i++ {
+		got, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("Decode %d error: %v", i, err)
+		}
+		if string(got.VarFields["key"]) != "token" || !bytes.Equal(got.Data, []byte("body")) {
+			t.Fatalf("Decode %d = %+v", i, got)
+		}
+	}
+
+	// 结束标记错误
+	buf[len(buf)-1] = 0x0D
+	if _, err := DecodeBytes(buf, cfg); !errors.Is(err, ErrConstMismatch) {
+		t.Fatalf("DecodeBytes err = %v, want ErrConstMismatch", err)
+	}
+}
+
+func TestFieldDrivenVarFieldLengthOverflow(t *testing.T) {
+	// size(4) + route(2) + tlen(8) + token(tlen): tlen ≥ 2^63 曾转为负数 int 导致切片越界
+	fdCfg, err := NewFieldDrivenConfig([]FieldDef{
+		{Name: "size", Bytes: 4},
+		{Name: "route", Bytes: 2, IsRoute: true},
+		{Name: "tlen", Bytes: 8},
+		{Name: "token", LengthField: "tlen"},
+	})
+	if err != nil {
+		t.Fatalf("NewFieldDrivenConfig error: %v", err)
+	}
+	fdCfg.BigEndian = true
+	cfg := PacketConfig{FieldDriven: fdCfg, MaxFrameSize: -1}
+
+	buf := []byte{0, 0, 0, 0, 0, 1, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	if _, err := DecodeBytes(buf, cfg); !errors.Is(err, ErrInvalidFrame) {
+		t.Fatalf("DecodeBytes err = %v, want ErrInvalidFrame", err)
+	}
+	if _, err := NewDecoder(bytes.NewReader(buf), cfg).Decode(); !errors.Is(err, ErrInvalidFrame) {
+		t.Fatalf("Decode err = %v, want ErrInvalidFrame", err)
+	}
+
+	// varint 长度字段同样受限
+	fdCfg, err = NewFieldDrivenConfig([]FieldDef{
+		{Name: "size", Bytes: 4},
+		{Name: "tlen", Varint: true},
+		{Name: "token", LengthField: "tlen"},
+	})
+	if err != nil {
+		t.Fatalf("NewFieldDrivenConfig error: %v", err)
+	}
+	buf = []byte{0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}
+	if _, err := DecodeBytes(buf, PacketConfig{FieldDriven: fdCfg}); !errors.Is(err, ErrInvalidFrame) {
+		t.Fatalf("varint DecodeBytes err = %v, want ErrInvalidFrame", err)
+	}
+}
+
+func TestFieldDrivenLayoutValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []FieldDef
+	}{
+		{"header after trailer", []FieldDef{
+			{Name: "len", Bytes: 2}, {Name: "crc", Bytes: 1, Role: FieldRoleChecksum, Checksum: ChecksumXOR, Trailer: true}, {Name: "cmd", Bytes: 1},
+		}},
+		{"unknown length field", []FieldDef{
+			{Name: "len", Bytes: 2}, {Name: "key", LengthField: "klen"},
+		}},
+		{"length field after var field", []FieldDef{
+			{Name: "len", Bytes: 2}, {Name: "key", LengthField: "klen"}, {Name: "klen", Bytes: 1},
+		}},
+		{"length field in trailer", []FieldDef{
+			{Name: "len", Bytes: 2}, {Name: "klen", Bytes: 1, Trailer: true}, {Name: "key", LengthField: "klen", Trailer: true},
+		}},
+		{"size as length field", []FieldDef{
+			{Name: "len", Bytes: 2}, {Name: "key", LengthField: "len"},
+		}},
+		{"size only in trailer", []FieldDef{
+			{Name: "cmd", Bytes: 2}, {Name: "len", Bytes: 2, Trailer: true},
+		}},
+	}
+	for _, tt := range tests {
+		if _, err := NewFieldDrivenConfig(tt.fields); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestFieldDrivenWideFields(t *testing.T) {
+	// len(3) + mid(8,route) + seq(8) + flags(3)
+	fields := []FieldDef{
+		{Name: "len", Bytes: 3},
+		{Name: "mid", Bytes: 8, IsRoute: true},
+		{Name: "seq", Bytes: 8, IsSeq: true},
+		{Name: "flags", Bytes: 3, Role: FieldRoleConst, Value: 0xABCDEF},
+	}
+	pkt := &Packet{Route: 1<<53 + 1, Seq: 1<<63 | 5, Data: []byte("wide")}
+
+	for _, bigEndian := range []bool{true, false} {
+		fdCfg, err := NewFieldDrivenConfig(fields)
+		if err != nil {
+			t.Fatalf("NewFieldDrivenConfig error: %v", err)
+		}
+		fdCfg.BigEndian = bigEndian
+		cfg := PacketConfig{FieldDriven: fdCfg}
+
+		buf, err := Encode(pkt, cfg)
+		if err != nil {
+			t.Fatalf("Encode error: %v", err)
+		}
+		if len(buf) != 22+4 {
+			t.Fatalf("buf len = %d, want 26", len(buf))
+		}
+		if bigEndian {
+			if !bytes.Equal(buf[0:3], []byte{0, 0, 4}) || binary.BigEndian.Uint64(buf[3:11]) != pkt.Route || !bytes.Equal(buf[19:22], []byte{0xAB, 0xCD, 0xEF}) {
+				t.Fatalf("big endian header = %x", buf[:22])
+			}
+		} else {
+			if !bytes.Equal(buf[0:3], []byte{4, 0, 0}) || binary.LittleEndian.Uint64(buf[11:19]) != pkt.Seq || !bytes.Equal(buf[19:22], []byte{0xEF, 0xCD, 0xAB}) {
+				t.Fatalf("little endian header = %x", buf[:22])
+			}
+		}
+
+		decoded, err := NewDecoder(bytes.NewReader(buf), cfg).Decode()
+		if err != nil {
+			t.Fatalf("Decode error: %v", err)
+		}
+		if decoded.Route != pkt.Route || decoded.Seq != pkt.Seq || !bytes.Equal(decoded.Data, pkt.Data) {
+			t.Fatalf("decoded = route %d seq %d data %q", decoded.Route, decoded.Seq, decoded.Data)
+		}
+	}
+}
+
+func TestFieldDrivenVarintFields(t *testing.T) {
+	// Netty ProtobufVarint32 风格: len(varint) + cmd(varint,route) + body
+	fdCfg, err := NewFieldDrivenConfig([]FieldDef{
+		{Name: "len", Varint: true},
+		{Name: "cmd", Varint: true, IsRoute: true},
+	})
+	if err != nil {
+		t.Fatalf("NewFieldDrivenConfig error: %v", err)
+	}
+	cfg := PacketConfig{FieldDriven: fdCfg}
+
+	pkt := &Packet{Route: 300, Data: bytes.Repeat([]byte{'x'}, 200)}
+	buf, err := Encode(pkt, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	// len=200 → c8 01, cmd=300 → ac 02
+	if !bytes.Equal(buf[:4], []byte{0xC8, 0x01, 0xAC, 0x02}) || len(buf) != 204 {
+		t.Fatalf("header = %x, len = %d", buf[:4], len(buf))
+	}
+
+	decoder := NewDecoder(iotest.OneByteReader(bytes.NewReader(append(append([]byte{}, buf...), buf...))), cfg)
+	for i := 0; i < 2; i++ {
+		got, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("Decode %d error: %v", i, err)
+		}
+		if got.Route != 300 || !bytes.Equal(got.Data, pkt.Data) {
+			t.Fatalf("Decode %d = route %d, %d bytes", i, got.Route, len(got.Data))
+		}
+	}
+	if _, err := decoder.Decode(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+
+	// size 覆盖整帧时, varint 长度随自身的值变化: 126 + 1(cmd) + 1(len) = 128 超出单字节, 整帧变为 129
+	fdCfg.SizeMode = SizeModeFrame
+	pkt = &Packet{Route: 1, Data: bytes.Repeat([]byte{'y'}, 126)}
+	buf, err = Encode(pkt, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if size, n := binary.Uvarint(buf); n != 2 || size != uint64(len(buf)) {
+		t.Fatalf("frame size = %d (%d bytes), want %d", size, n, len(buf))
+	}
+	decoded, err := DecodeBytes(buf, cfg)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if !bytes.Equal(decoded.Data, pkt.Data) {
+		t.Fatalf("decoded %d bytes, want %d", len(decoded.Data), len(pkt.Data))
+	}
+
+	// 超过 10 字节的 varint
+	overflow := bytes.Repeat([]byte{0xFF}, 11)
+	if _, err := NewDecoder(bytes.NewReader(overflow), cfg).Decode(); !errors.Is(err, ErrInvalidFrame) {
+		t.Fatalf("overflow err = %v, want ErrInvalidFrame", err)
+	}
+}
+
+func TestFieldDrivenWidthValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []FieldDef
+	}{
+		{"9-byte route", []FieldDef{{Name: "len", Bytes: 2}, {Name: "mid", Bytes: 9, IsRoute: true}}},
+		{"9-byte size", []FieldDef{{Name: "len", Bytes: 9}}},
+		{"varint trailer", []FieldDef{{Name: "len", Bytes: 2}, {Name: "tag", Varint: true, Trailer: true}}},
+		{"varint checksum", []FieldDef{{Name: "len", Bytes: 2}, {Name: "sum", Varint: true, Role: FieldRoleChecksum, Checksum: ChecksumXOR}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewFieldDrivenConfig(tt.fields); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+
+	// 不参与编解码的保留字段可以超过 8 字节
+	if _, err := NewFieldDrivenConfig([]FieldDef{{Name: "len", Bytes: 2}, {Name: "reserved", Bytes: 16}}); err != nil {
+		t.Fatalf("reserved field: %v", err)
+	}
+}
+
+func TestFieldDrivenCustomFields(t *testing.T) {
+	// len(4) + uid(8) + cmd(2,route) + serverId(2) + flags(1) + ver(1, const)
+	fdCfg, err := NewFieldDrivenConfig([]FieldDef{
+		{Name: "len", Bytes: 4},
+		{Name: "uid", Bytes: 8},
+		{Name: "cmd", Bytes: 2, IsRoute: true},
+		{Name: "serverId", Bytes: 2},
+		{Name: "flags", Bytes: 1},
+		{Name: "ver", Bytes: 1, Role: FieldRoleConst, Value: 3},
+	})
+	if err != nil {
+		t.Fatalf("NewFieldDrivenConfig error: %v", err)
+	}
+	fdCfg.BigEndian = true
+	cfg := PacketConfig{FieldDriven: fdCfg}
+
+	pkt := &Packet{
+		Route: 7,
+		Data:  []byte("x"),
+		// ver 为固定值字段, 不能被覆盖; cmd 由 Route 决定
+		Fields: map[string]uint64{"uid": 1 << 40, "serverId": 12, "flags": 0x01, "ver": 9, "cmd": 99},
+	}
+	buf, err := Encode(pkt, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if uid := binary.BigEndian.Uint64(buf[4:12]); uid != 1<<40 {
+		t.Fatalf("uid = %d, want %d", uid, uint64(1<<40))
+	}
+	if cmd := binary.BigEndian.Uint16(buf[12:14]); cmd != 7 {
+		t.Fatalf("cmd = %d, want 7", cmd)
+	}
+	if serverID := binary.BigEndian.Uint16(buf[14:16]); serverID != 12 {
+		t.Fatalf("serverId = %d, want 12", serverID)
+	}
+	if buf[16] != 0x01 || buf[17] != 3 {
+		t.Fatalf("flags/ver = %d/%d, want 1/3", buf[16], buf[17])
+	}
+
+	decoded, err := DecodeBytes(buf, cfg)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	want := map[string]uint64{"uid": 1 << 40, "serverId": 12, "flags": 1, "ver": 3}
+	if len(decoded.Fields) != len(want) {
+		t.Fatalf("decoded fields = %v, want %v", decoded.Fields, want)
+	}
+	for name, v := range want {
+		if decoded.Fields[name] != v {
+			t.Fatalf("decoded fields = %v, want %v", decoded.Fields, want)
+		}
+	}
+}
+
 func TestLegacyDueUnaffected(t *testing.T) {
 	// 确认 legacy Due 模式在添加字段驱动后仍然正常工作
 	cfg := DefaultPacketConfig()
@@ -696,3 +1175,39 @@ func TestLegacyDueUnaffected(t *testing.T) {
 		t.Fatalf("legacy data = %q, want %q", decoded.Data, "legacy")
 	}
 }
+
+func TestDecodeOneByteReads(t *testing.T) {
+	// 每次 Read 只返回 1 字节, 模拟极端的拆包
+	configs := map[string]PacketConfig{
+		"due":    DefaultPacketConfig(),
+		"antnet": antnetConfig(),
+		"cherry": cherryConfig(),
+		"pomelo": {Pomelo: &PomeloConfig{}},
+	}
+	for name, cfg := range configs {
+		t.Run(name, func(t *testing.T) {
+			var stream []byte
+			for i := 1; i <= 3; i++ {
+				encoded, err := Encode(&Packet{Route: uint64(i), Seq: uint64(i), Data: bytes.Repeat([]byte{byte(i)}, i*10)}, cfg)
+				if err != nil {
+					t.Fatalf("Encode error: %v", err)
+				}
+				stream = append(stream, encoded...)
+			}
+
+			decoder := NewDecoder(iotest.OneByteReader(bytes.NewReader(stream)), cfg)
+			for i := 1; i <= 3; i++ {
+				pkt, err := decoder.Decode()
+				if err != nil {
+					t.Fatalf("packet %d: Decode error: %v", i, err)
+				}
+				if pkt.Route != uint64(i) || len(pkt.Data) != i*10 {
+					t.Fatalf("packet %d: route=%d len=%d", i, pkt.Route, len(pkt.Data))
+				}
+			}
+			if _, err := decoder.Decode(); err != io.EOF {
+				t.Fatalf("expected io.EOF after last packet, got %v", err)
+			}
+		})
+	}
+}
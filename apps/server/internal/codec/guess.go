@@ -0,0 +1,132 @@
+package codec
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// guessPenalty 每个可疑字段(未知字段、未定义的枚举值、非法 UTF-8 字符串)抵消的已识别字段数
+const guessPenalty = 3
+
+// MessageGuess 按某个消息定义试解码的结果
+type MessageGuess struct {
+	Message string         `json:"message"` // 消息全限定名
+	Score   int            `json:"score"`   // 已识别字段数减去可疑字段的惩罚, 越高越可信
+	Known   int            `json:"known"`   // 按消息定义识别的字段数(含嵌套消息、列表元素)
+	Suspect int            `json:"suspect"` // 可疑字段数
+	Fields  map[string]any `json:"fields"`  // 按该消息定义解码的结果
+
+	declared int // 消息定义的字段数, 用于同分排序
+}
+
+// GuessMessage 用每个候选消息定义试解码 data, 按可信度从高到低返回
+//
+// 无法解码、没有识别出任何字段或得分不为正的候选会被丢弃. 得分相同时字段定义更少的消息优先,
+// 即优先选择与数据贴合更紧的消息. 用于路由未映射响应消息时推荐映射
+//
+// 参数：
+//   - data: protobuf 编码的消息体
+//   - candidates: 候选消息定义, 通常为连接加载的全部消息
+//   - limit: 最多返回的候选数, 0 表示不限制
+//   - opts: 解码结果的转换选项
+//
+// 返回值：
+//   - []MessageGuess: 按 Score 降序排列的候选
+func GuessMessage(data []byte, candidates []protoreflect.MessageDescriptor, limit int, opts ProtoJSONOptions) []MessageGuess {
+	var guesses []MessageGuess
+	for _, md := range candidates {
+		if md.IsMapEntry() {
+			continue
+		}
+		msg := dynamicpb.NewMessage(md)
+		if err := proto.Unmarshal(data, msg); err != nil {
+			continue
+		}
+		known, suspect := scoreMessage(msg)
+		score := known - guessPenalty*suspect
+		if known == 0 || score <= 0 {
+			continue
+		}
+		guesses = append(guesses, MessageGuess{
+			Message: string(md.FullName()),
+			Score:   score,
+			Known:   known,
+			Suspect: suspect,
+			Fields:  messageToMap(msg, opts),
+
+			declared: md.Fields().Len(),
+		})
+	}
+
+	slices.SortStableFunc(guesses, func(a, b MessageGuess) int {
+		if a.Score != b.Score {
+			return b.Score - a.Score
+		}
+		if a.declared != b.declared {
+			return a.declared - b.declared
+		}
+		return strings.Compare(a.Message, b.Message)
+	})
+	if limit > 0 && len(guesses) > limit {
+		guesses = guesses[:limit]
+	}
+	return guesses
+}
+
+// scoreMessage 统计消息中已识别的字段数和可疑字段数, 递归处理嵌套消息
+func scoreMessage(msg protoreflect.Message) (known, suspect int) {
+	if unknown := msg.GetUnknown(); len(unknown) > 0 {
+		if fields, err := DecodeWire(unknown); err == nil {
+			suspect += len(fields)
+		} else {
+			suspect++
+		}
+	}
+
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				k, s := scoreValue(fd, list.Get(i))
+				known, suspect = known+k, suspect+s
+			}
+		case fd.IsMap():
+			v.Map().Range(func(_ protoreflect.MapKey, e protoreflect.Value) bool {
+				k, s := scoreValue(fd.MapValue(), e)
+				known, suspect = known+k, suspect+s
+				return true
+			})
+		default:
+			k, s := scoreValue(fd, v)
+			known, suspect = known+k, suspect+s
+		}
+		return true
+	})
+	return known, suspect
+}
+
+// scoreValue 单个字段值计为一个已识别字段, 未定义的枚举值和非法 UTF-8 字符串计为可疑
+//
+// proto3 的 string 字段在解码时已校验 UTF-8, proto2 不校验, 因此这里仍需检查
+func scoreValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) (known, suspect int) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		k, s := scoreMessage(v.Message())
+		return k + 1, s
+	case protoreflect.EnumKind:
+		if fd.Enum().Values().ByNumber(v.Enum()) == nil {
+			return 0, 1
+		}
+	case protoreflect.StringKind:
+		if !utf8.ValidString(v.String()) {
+			return 0, 1
+		}
+	}
+	return 1, 0
+}
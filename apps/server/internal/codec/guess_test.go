@@ -0,0 +1,60 @@
+package codec
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestGuessMessage(t *testing.T) {
+	proto := `syntax = "proto3";
+enum Status {
+  UNKNOWN = 0;
+  ACTIVE = 1;
+}
+message LoginResp {
+  string account = 1;
+  int32 zone = 2;
+}
+message ItemResp {
+  int32 id = 1;
+  Status status = 2;
+}
+message Pair {
+  int32 a = 1;
+  int32 b = 2;
+}
+message Wide {
+  int32 a = 1;
+  int32 b = 2;
+  int32 c = 3;
+}
+message Empty {}`
+	md := compileProto(t, proto, "LoginResp")
+	msgs := md.ParentFile().Messages()
+	var candidates []protoreflect.MessageDescriptor
+	for i := 0; i < msgs.Len(); i++ {
+		candidates = append(candidates, msgs.Get(i))
+	}
+
+	// {account: "bob", zone: 3}: 字段 1 为 len, 只有 LoginResp 能完整识别
+	data, err := DynamicEncode(md, map[string]any{"account": "bob", "zone": 3})
+	if err != nil {
+		t.Fatalf("DynamicEncode error: %v", err)
+	}
+	guesses := GuessMessage(data, candidates, 0, ProtoJSONOptions{})
+	if len(guesses) == 0 || guesses[0].Message != "LoginResp" || guesses[0].Fields["account"] != "bob" {
+		t.Fatalf("guesses = %+v", guesses)
+	}
+
+	// {1: 5, 2: 9}: ItemResp 的枚举值 9 未定义; Pair 与 Wide 同分时字段更少的 Pair 优先
+	guesses = GuessMessage([]byte{0x08, 0x05, 0x10, 0x09}, candidates, 2, ProtoJSONOptions{})
+	if len(guesses) != 2 || guesses[0].Message != "Pair" || guesses[1].Message != "Wide" {
+		t.Fatalf("guesses = %+v", guesses)
+	}
+
+	// 任何消息都无法识别的字段不产生候选
+	if guesses := GuessMessage([]byte{0xf8, 0x07, 0x01}, candidates, 0, ProtoJSONOptions{}); len(guesses) != 0 {
+		t.Fatalf("guesses = %+v, want none", guesses)
+	}
+}
@@ -0,0 +1,16 @@
+package codec
+
+// Pomelo 派生框架的协议名称
+//
+// Pitaya 与 Cherry 相同, 消息 flag 使用 gzip(0x10, zlib 压缩)和 error(0x20)扩展位, 握手响应的
+// sys.serializer 指明消息体序列化方式(protobuf/json); Nano 沿用 Pomelo 原始的消息格式,
+// 握手响应的 sys.heartbeat 可能为小数秒. 两者的握手、心跳、路由字典和踢下线包均与 Pomelo 相同
+const (
+	ProtocolPitaya = "pitaya"
+	ProtocolNano   = "nano"
+)
+
+func init() {
+	RegisterFrameCodec(ProtocolPitaya, pomeloFamilyCodec(true))
+	RegisterFrameCodec(ProtocolNano, pomeloFamilyCodec(false))
+}
@@ -28,11 +28,45 @@ const (
 const (
 	pomeloRouteCompressMask byte = 0x01
 	pomeloTypeMask          byte = 0x07
+	pomeloGzipMask          byte = 0x10 // 扩展标志: 消息体经 zlib 压缩
+	pomeloErrorMask         byte = 0x20 // 扩展标志: 错误响应
 )
 
+// PomeloFieldError 扩展标志模式下错误响应在 Packet.Fields 中的键, 值为 1
+const PomeloFieldError = "error"
+
 // PomeloConfig Pomelo 协议配置
 type PomeloConfig struct {
 	UseRouteCompress bool // 是否使用路由压缩
+	// RouteDict 握手响应 sys.dict 下发的路由字典(字符串路由 → 压缩码)
+	// 编码时字典中的字符串路由改用 2 字节压缩码, 解码时压缩码还原为字符串路由
+	RouteDict map[string]uint16
+	// ExtendedFlags 消息 flag 含 gzip(0x10) 和 error(0x20) 位, Cherry 等派生框架使用
+	ExtendedFlags bool
+}
+
+// dictRoute 在路由字典中查找压缩码对应的字符串路由
+func (c *PomeloConfig) dictRoute(code uint16) (string, bool) {
+	for route, v := range c.RouteDict {
+		if v == code {
+			return route, true
+		}
+	}
+	return "", false
+}
+
+// pomeloFamilyCodec 返回 Pomelo 派生框架的编解码器工厂, 未设置 Pomelo 配置时默认启用路由压缩
+//
+// extended 为 true 时消息 flag 使用 gzip/error 扩展位
+func pomeloFamilyCodec(extended bool) FrameCodecFactory {
+	return func(cfg PacketConfig) (FrameCodec, error) {
+		pc := PomeloConfig{UseRouteCompress: true}
+		if cfg.Pomelo != nil {
+			pc = *cfg.Pomelo
+		}
+		pc.ExtendedFlags = extended
+		return &pomeloCodec{cfg: &pc, maxSize: cfg.EffectiveMaxFrameSize()}, nil
+	}
 }
 
 // Pomelo 外层包头大小: type(1B) + length(3B)
@@ -53,6 +87,21 @@ func PomeloEncodeHeartbeat() []byte {
 	return pomeloEncodePacket(PomeloPacketHeartbeat, nil)
 }
 
+// PomeloEncodeResponse 编码服务端响应数据包, 响应只携带 msgId 不携带路由
+func PomeloEncodeResponse(msgId uint64, data []byte) []byte {
+	return pomeloEncodePacket(PomeloPacketData, pomeloEncodeMessage(PomeloMsgResponse, msgId, 0, "", data))
+}
+
+// PomeloEncodePush 编码服务端推送数据包, 推送不携带 msgId
+func PomeloEncodePush(route uint64, stringRoute string, data []byte) []byte {
+	return pomeloEncodePacket(PomeloPacketData, pomeloEncodeMessage(PomeloMsgPush, 0, route, stringRoute, data))
+}
+
+// PomeloEncodeKick 编码踢下线包, payload 通常为 {"reason": "..."}
+func PomeloEncodeKick(payload []byte) []byte {
+	return pomeloEncodePacket(PomeloPacketKick, payload)
+}
+
 // pomeloEncodePacket 编码 Pomelo 外层包
 //
 // 帧格式: type(1B) + length(3B, 大端) + data(NB)
@@ -71,7 +120,7 @@ func pomeloEncodePacket(pkgType byte, data []byte) []byte {
 // 帧格式: flag(1B) + [msgId(varint)] + [route] + payload
 // Request 类型携带 msgId 和 route, Response 只携带 msgId
 // route 有两种编码: 压缩模式(2B uint16) 和字符串模式(1B len + string)
-func pomeloEncodeMessage(msgType byte, msgId uint32, route uint32, stringRoute string, data []byte) []byte {
+func pomeloEncodeMessage(msgType byte, msgId uint64, route uint64, stringRoute string, data []byte) []byte {
 	routeCompress := stringRoute == ""
 
 	flag := msgType << 1
@@ -107,23 +156,54 @@ func pomeloEncodeMessage(msgType byte, msgId uint32, route uint32, stringRoute s
 
 // pomeloEncode 将 Packet 编码为 Pomelo 二进制帧
 //
-// 当 Packet.StringRoute 非空时使用字符串路由, 否则使用压缩路由(uint16)
+// 当 Packet.StringRoute 非空时使用字符串路由(在路由字典中时改用其压缩码), 否则使用压缩路由(uint16)
 func pomeloEncode(pkt *Packet, cfg *PomeloConfig) ([]byte, error) {
 	if pkt.Heartbeat {
 		return PomeloEncodeHeartbeat(), nil
 	}
 
+	route, stringRoute := pkt.Route, pkt.StringRoute
+	if code, ok := cfg.RouteDict[stringRoute]; ok && stringRoute != "" {
+		route, stringRoute = uint64(code), ""
+	}
 	msgData := pomeloEncodeMessage(
 		PomeloMsgRequest,
 		pkt.Seq,
-		pkt.Route,
-		pkt.StringRoute,
+		route,
+		stringRoute,
 		pkt.Data,
 	)
 
 	return pomeloEncodePacket(PomeloPacketData, msgData), nil
 }
 
+// pomeloCodec Pomelo 帧编解码器, 流式读取时先按外层包头读取整包
+type pomeloCodec struct {
+	cfg     *PomeloConfig
+	maxSize int
+}
+
+func (c *pomeloCodec) Encode(pkt *Packet) ([]byte, error) {
+	return pomeloEncode(pkt, c.cfg)
+}
+
+func (c *pomeloCodec) DecodeBytes(data []byte) (*Packet, error) {
+	return pomeloDecodeBytes(data, c.cfg)
+}
+
+func (c *pomeloCodec) Decode(r io.Reader) (*Packet, error) {
+	raw, err := c.DecodeRaw(r)
+	if err != nil {
+		return nil, err
+	}
+	return pomeloDecodeBytes(raw, c.cfg)
+}
+
+// DecodeRaw 读取一个完整的 Pomelo 包而不解析内层消息
+func (c *pomeloCodec) DecodeRaw(r io.Reader) ([]byte, error) {
+	return pomeloDecodeRaw(r, c.maxSize)
+}
+
 // pomeloDecodeBytes 从完整字节数组解码 Pomelo 包
 //
 // 返回值:
@@ -146,7 +226,7 @@ func pomeloDecodeBytes(data []byte, cfg *PomeloConfig) (*Packet, error) {
 	case PomeloPacketHeartbeat:
 		return &Packet{Heartbeat: true}, nil
 	case PomeloPacketData:
-		return pomeloDecodeMessage(body)
+		return pomeloDecodeMessage(body, cfg)
 	case PomeloPacketHandshake, PomeloPacketHandshakeAck, PomeloPacketKick:
 		// 控制包: ExtCode 标记包类型, Data 存放 body
 		return &Packet{ExtCode: pkgType, Data: body}, nil
@@ -156,7 +236,7 @@ func pomeloDecodeBytes(data []byte, cfg *PomeloConfig) (*Packet, error) {
 }
 
 // pomeloDecodeMessage 解码 Pomelo 内层消息
-func pomeloDecodeMessage(data []byte) (*Packet, error) {
+func pomeloDecodeMessage(data []byte, cfg *PomeloConfig) (*Packet, error) {
 	if len(data) < 1 {
 		return nil, errors.New("pomelo: message too short")
 	}
@@ -185,7 +265,9 @@ func pomeloDecodeMessage(data []byte) (*Packet, error) {
 			if offset+2 > len(data) {
 				return nil, errors.New("pomelo: route too short")
 			}
-			pkt.Route = uint32(binary.BigEndian.Uint16(data[offset:]))
+			code := binary.BigEndian.Uint16(data[offset:])
+			pkt.Route = uint64(code)
+			pkt.StringRoute, _ = cfg.dictRoute(code)
 			offset += 2
 		} else {
 			// 字符串路由: 1B length + string
@@ -206,17 +288,38 @@ func pomeloDecodeMessage(data []byte) (*Packet, error) {
 		pkt.Data = data[offset:]
 	}
 
+	if cfg.ExtendedFlags {
+		if flag&pomeloErrorMask != 0 {
+			pkt.Fields = map[string]uint64{PomeloFieldError: 1}
+		}
+		if flag&pomeloGzipMask != 0 && len(pkt.Data) > 0 {
+			body, err := decompressBody(CompressZlib, pkt.Data, DefaultMaxFrameSize)
+			if err != nil {
+				return nil, fmt.Errorf("%w: pomelo: inflate body: %v", ErrDecompress, err)
+			}
+			pkt.Data = body
+		}
+	}
+
 	return pkt, nil
 }
 
 // pomeloDecodeRaw 从流中读取一个完整的 Pomelo 包, 返回原始字节(含包头)
-func pomeloDecodeRaw(reader io.Reader) ([]byte, error) {
+//
+// maxSize 为单包上限(含包头), 0 表示不限制
+func pomeloDecodeRaw(reader io.Reader, maxSize int) ([]byte, error) {
 	head := make([]byte, pomeloHeadLength)
 	if _, err := io.ReadFull(reader, head); err != nil {
 		return nil, err
 	}
 
+	if head[0] < PomeloPacketHandshake || head[0] > PomeloPacketKick {
+		return nil, fmt.Errorf("%w: pomelo: unknown packet type: 0x%02x", ErrInvalidFrame, head[0])
+	}
 	length := int(head[1])<<16 | int(head[2])<<8 | int(head[3])
+	if maxSize > 0 && pomeloHeadLength+length > maxSize {
+		return nil, &FrameTooLargeError{Size: uint64(pomeloHeadLength + length), Limit: maxSize}
+	}
 	raw := make([]byte, pomeloHeadLength+length)
 	copy(raw, head)
 
@@ -229,10 +332,10 @@ func pomeloDecodeRaw(reader io.Reader) ([]byte, error) {
 	return raw, nil
 }
 
-// encodeVarint 将 uint32 编码为 varint128
+// encodeVarint 将 uint64 编码为 varint128
 //
 // 每字节 7 位有效数据, 最高位为续传标记
-func encodeVarint(v uint32) []byte {
+func encodeVarint(v uint64) []byte {
 	if v == 0 {
 		return []byte{0}
 	}
@@ -251,13 +354,13 @@ func encodeVarint(v uint32) []byte {
 // decodeVarint 从字节数组解码 varint128
 //
 // 返回值:
-//   - uint32: 解码后的值
+//   - uint64: 解码后的值
 //   - int: 消耗的字节数
-func decodeVarint(data []byte) (uint32, int, error) {
-	var result uint32
-	for i := 0; i < len(data) && i < 5; i++ {
+func decodeVarint(data []byte) (uint64, int, error) {
+	var result uint64
+	for i := 0; i < len(data) && i < binary.MaxVarintLen64; i++ {
 		b := data[i]
-		result |= uint32(b&0x7F) << (7 * uint(i))
+		result |= uint64(b&0x7F) << (7 * uint(i))
 		if (b & 0x80) == 0 {
 			return result, i + 1, nil
 		}
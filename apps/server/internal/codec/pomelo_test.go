@@ -6,7 +6,7 @@ import (
 )
 
 func TestVarintRoundTrip(t *testing.T) {
-	cases := []uint32{0, 1, 127, 128, 255, 256, 16383, 16384, 1<<21 - 1, 1 << 21}
+	cases := []uint64{0, 1, 127, 128, 255, 256, 16383, 16384, 1<<21 - 1, 1 << 21, 1 << 40, 1<<64 - 1}
 	for _, v := range cases {
 		encoded := encodeVarint(v)
 		decoded, n, err := decodeVarint(encoded)
@@ -98,7 +98,7 @@ func TestPomeloStreamDecode(t *testing.T) {
 	reader := bytes.NewReader(stream)
 
 	// 第一个包: 数据包
-	raw1, err := pomeloDecodeRaw(reader)
+	raw1, err := pomeloDecodeRaw(reader, 0)
 	if err != nil {
 		t.Fatalf("decodeRaw 1: %v", err)
 	}
@@ -111,7 +111,7 @@ func TestPomeloStreamDecode(t *testing.T) {
 	}
 
 	// 第二个包: 心跳
-	raw2, err := pomeloDecodeRaw(reader)
+	raw2, err := pomeloDecodeRaw(reader, 0)
 	if err != nil {
 		t.Fatalf("decodeRaw 2: %v", err)
 	}
@@ -143,7 +143,7 @@ func TestPomeloControlPacket(t *testing.T) {
 
 func TestPomeloResponseDecode(t *testing.T) {
 	// 手工构建一个 Response 消息: flag(Response, no route compress) + msgId(varint) + payload
-	msgId := uint32(42)
+	msgId := uint64(42)
 	payload := []byte{0x0A, 0x0B}
 
 	flag := PomeloMsgResponse << 1 // Response 类型, 无路由压缩
@@ -166,3 +166,35 @@ func TestPomeloResponseDecode(t *testing.T) {
 		t.Fatalf("data: got %v, want %v", decoded.Data, payload)
 	}
 }
+
+func TestPomeloEncodeResponse(t *testing.T) {
+	payload := []byte("ok")
+	decoded, err := pomeloDecodeBytes(PomeloEncodeResponse(300, payload), &PomeloConfig{})
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if decoded.Seq != 300 || decoded.Route != 0 || decoded.StringRoute != "" {
+		t.Fatalf("decoded = %+v, want seq 300 without route", decoded)
+	}
+	if !bytes.Equal(decoded.Data, payload) {
+		t.Fatalf("data: got %q, want %q", decoded.Data, payload)
+	}
+}
+
+func TestPomeloEncodePush(t *testing.T) {
+	decoded, err := pomeloDecodeBytes(PomeloEncodePush(0, "onBagUpdate", []byte{0x08, 0x01}), &PomeloConfig{})
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if decoded.Seq != 0 || decoded.StringRoute != "onBagUpdate" {
+		t.Fatalf("decoded = %+v, want push onBagUpdate without seq", decoded)
+	}
+
+	kick, err := pomeloDecodeBytes(PomeloEncodeKick([]byte(`{"reason":"test"}`)), &PomeloConfig{})
+	if err != nil {
+		t.Fatalf("decode kick: %v", err)
+	}
+	if kick.ExtCode != PomeloPacketKick {
+		t.Fatalf("kick ext = %d, want %d", kick.ExtCode, PomeloPacketKick)
+	}
+}
@@ -0,0 +1,66 @@
+package codec
+
+import "slices"
+
+// ProtocolLeaf Leaf 框架(github.com/name5566/leaf)的 TCP 帧预设名称
+//
+// Leaf 默认配置下帧格式为 len(2B) + id(2B) + data, 大端序, len 为 id + data 的长度;
+// id 为 protobuf 处理器注册消息的序号. Leaf 帧可直接用字段驱动模式表示, 不单独注册编解码器
+const ProtocolLeaf = "leaf"
+
+// LeafPacketConfig 返回 Leaf 默认配置(LenMsgLen=2, LittleEndian=false, protobuf 处理器)的帧配置
+func LeafPacketConfig() PacketConfig {
+	fdCfg, err := NewFieldDrivenConfig([]FieldDef{
+		{Name: "len", Bytes: 2},
+		{Name: "id", Bytes: 2, IsRoute: true},
+	})
+	if err != nil {
+		panic(err)
+	}
+	fdCfg.BigEndian = true
+	fdCfg.SizeMode = SizeModeAfterSize
+	return PacketConfig{FieldDriven: fdCfg}
+}
+
+// presets 框架预设, 名称 → 帧配置构造函数
+var presets = map[string]func() PacketConfig{
+	ProtocolDue: func() PacketConfig {
+		cfg := DefaultPacketConfig()
+		cfg.Protocol = ProtocolDue
+		return cfg
+	},
+	ProtocolPomelo: pomeloPreset(ProtocolPomelo),
+	ProtocolCherry: pomeloPreset(ProtocolCherry),
+	ProtocolPitaya: pomeloPreset(ProtocolPitaya),
+	ProtocolNano:   pomeloPreset(ProtocolNano),
+	ProtocolLeaf:   LeafPacketConfig,
+	ProtocolSkynet: func() PacketConfig { return PacketConfig{Protocol: ProtocolSkynet} },
+}
+
+// pomeloPreset Pomelo 协议族预设, 默认启用路由压缩
+func pomeloPreset(name string) func() PacketConfig {
+	return func() PacketConfig {
+		return PacketConfig{Protocol: name, Pomelo: &PomeloConfig{UseRouteCompress: true}}
+	}
+}
+
+// Preset 返回框架预设的帧配置, 未知名称返回 false
+//
+// 预设只包含帧格式, MaxFrameSize、压缩、加密等由调用方另行设置
+func Preset(name string) (PacketConfig, bool) {
+	fn, ok := presets[name]
+	if !ok {
+		return PacketConfig{}, false
+	}
+	return fn(), true
+}
+
+// Presets 返回所有框架预设名称(按字母序)
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
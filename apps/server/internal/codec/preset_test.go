@@ -0,0 +1,83 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		pomelo   bool
+	}{
+		{ProtocolDue, ProtocolDue, false},
+		{ProtocolPomelo, ProtocolPomelo, true},
+		{ProtocolCherry, ProtocolCherry, true},
+		{ProtocolPitaya, ProtocolPitaya, true},
+		{ProtocolNano, ProtocolNano, true},
+		{ProtocolLeaf, ProtocolFieldDriven, false},
+		{ProtocolSkynet, ProtocolSkynet, false},
+	}
+	for _, tt := range tests {
+		cfg, ok := Preset(tt.name)
+		if !ok {
+			t.Fatalf("preset %s missing from %v", tt.name, Presets())
+		}
+		if cfg.ProtocolName() != tt.protocol || cfg.IsPomelo() != tt.pomelo {
+			t.Errorf("%s: protocol = %s, pomelo = %v", tt.name, cfg.ProtocolName(), cfg.IsPomelo())
+		}
+		if _, err := NewFrameCodec(cfg); err != nil {
+			t.Errorf("%s: NewFrameCodec error: %v", tt.name, err)
+		}
+	}
+	if _, ok := Preset("tars"); ok {
+		t.Fatal("unexpected preset")
+	}
+}
+
+func TestLeafWireFormat(t *testing.T) {
+	cfg, _ := Preset(ProtocolLeaf)
+
+	// len 覆盖 id + data
+	buf, err := Encode(&Packet{Route: 0x0102, Data: []byte("hi")}, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	want := []byte{0x00, 0x04, 0x01, 0x02, 'h', 'i'}
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("frame = % x, want % x", buf, want)
+	}
+
+	pkt, err := NewDecoder(bytes.NewReader(buf), cfg).Decode()
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if pkt.Route != 0x0102 || string(pkt.Data) != "hi" {
+		t.Fatalf("pkt = %+v", pkt)
+	}
+}
+
+func TestPitayaAndNanoFlags(t *testing.T) {
+	// Pitaya 错误响应: flag = type(response)<<1 | error(0x20), msgId = 7
+	resp := pomeloEncodePacket(PomeloPacketData, []byte{PomeloMsgResponse<<1 | pomeloErrorMask, 0x07, 'e'})
+
+	pitaya, _ := Preset(ProtocolPitaya)
+	pkt, err := DecodeBytes(resp, pitaya)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if pkt.Seq != 7 || pkt.Fields[PomeloFieldError] != 1 || string(pkt.Data) != "e" {
+		t.Fatalf("pitaya pkt = %+v", pkt)
+	}
+
+	// Nano 不使用扩展标志位
+	nano, _ := Preset(ProtocolNano)
+	pkt, err = DecodeBytes(resp, nano)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if pkt.Fields != nil {
+		t.Fatalf("nano pkt fields = %v, want none", pkt.Fields)
+	}
+}
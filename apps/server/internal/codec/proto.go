@@ -1,33 +1,72 @@
 package codec
 
 import (
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
 	"google.golang.org/protobuf/types/dynamicpb"
 )
 
-// DynamicEncode 使用动态消息将字段值编码为 Protobuf 字节数组
+// ProtoJSONOptions Protobuf 字段值与 JSON 友好值互转的选项
+//
+// 除 bytes 外与 protojson 的映射一致: 64 位整数为十进制字符串, 枚举为值名称(未定义的值保留数字),
+// Timestamp 为 RFC 3339 字符串, Duration 为 "1.5s" 形式, 包装类型为其值, Struct/Value/ListValue
+// 为对应的 JSON 值, Any 为 {"@type": ..., 消息字段...}
+type ProtoJSONOptions struct {
+	// BytesBase64 bytes 字段使用标准 base64(protojson 的格式), 默认为十六进制
+	BytesBase64 bool
+	// Resolver 按全限定名查找 Any 内嵌的消息类型, 找不到时再查全局注册表;
+	// 仍找不到时 Any 解码为 {"@type": ..., "_hex": ...}
+	Resolver func(fullName string) protoreflect.MessageDescriptor
+}
+
+// maxExactFloat float64 能精确表示的最大整数 2^53, 超出的 JSON 数字已丢失精度
+const maxExactFloat = 1 << 53
+
+// DynamicEncode 使用动态消息将字段值编码为 Protobuf 字节数组, bytes 字段为十六进制
 func DynamicEncode(md protoreflect.MessageDescriptor, fields map[string]any) ([]byte, error) {
+	return DynamicEncodeWith(md, fields, ProtoJSONOptions{})
+}
+
+// DynamicEncodeWith 按选项将字段值编码为 Protobuf 字节数组
+//
+// 整数字段接受数字和十进制字符串, 超过 2^53 的整数须以字符串传入; 枚举字段接受数字和值名称
+func DynamicEncodeWith(md protoreflect.MessageDescriptor, fields map[string]any, opts ProtoJSONOptions) ([]byte, error) {
 	msg := dynamicpb.NewMessage(md)
 
-	if err := setMessageFields(msg, md, fields); err != nil {
+	if err := setMessageFields(msg, md, fields, opts); err != nil {
 		return nil, err
 	}
 
 	return proto.Marshal(msg)
 }
 
-// DynamicDecode 将 Protobuf 字节数组解码为 JSON 友好的 map
-// 如果 md 为 nil, 返回十六进制字符串
+// DynamicDecode 将 Protobuf 字节数组解码为 JSON 友好的 map, bytes 字段为十六进制
+// 如果 md 为 nil, 返回十六进制字符串, 数据是合法线格式时另附 _wire(见 DecodeWire);
+// 消息定义中不存在的字段以线格式结构列在 _unknown 中
 func DynamicDecode(data []byte, md protoreflect.MessageDescriptor) (map[string]any, error) {
+	return DynamicDecodeWith(data, md, ProtoJSONOptions{})
+}
+
+// DynamicDecodeWith 按选项将 Protobuf 字节数组解码为 JSON 友好的 map
+func DynamicDecodeWith(data []byte, md protoreflect.MessageDescriptor, opts ProtoJSONOptions) (map[string]any, error) {
 	if md == nil {
-		return map[string]any{
-			"_hex": hex.EncodeToString(data),
-		}, nil
+		result := map[string]any{
+			bodyHexKey: hex.EncodeToString(data),
+		}
+		if wire, ok := wireValue(data); ok {
+			result[wireKey] = wire
+		}
+		return result, nil
 	}
 
 	msg := dynamicpb.NewMessage(md)
@@ -35,17 +74,21 @@ func DynamicDecode(data []byte, md protoreflect.MessageDescriptor) (map[string]a
 		return nil, fmt.Errorf("unmarshal: %w", err)
 	}
 
-	return messageToMap(msg), nil
+	return messageToMap(msg, opts), nil
 }
 
-func setMessageFields(msg *dynamicpb.Message, md protoreflect.MessageDescriptor, fields map[string]any) error {
+func setMessageFields(msg *dynamicpb.Message, md protoreflect.MessageDescriptor, fields map[string]any, opts ProtoJSONOptions) error {
 	for name, val := range fields {
 		fd := md.Fields().ByName(protoreflect.Name(name))
 		if fd == nil {
 			return fmt.Errorf("unknown field %q in %s", name, md.FullName())
 		}
+		// 与 protojson 一致, null 表示不设置该字段, 只有 google.protobuf.Value 的 null 有值
+		if val == nil && (fd.Message() == nil || fd.Message().FullName() != wktValue || fd.IsList() || fd.IsMap()) {
+			continue
+		}
 
-		protoVal, err := toProtoValue(fd, val)
+		protoVal, err := toProtoValue(fd, val, opts)
 		if err != nil {
 			return fmt.Errorf("field %q: %w", name, err)
 		}
@@ -57,7 +100,7 @@ func setMessageFields(msg *dynamicpb.Message, md protoreflect.MessageDescriptor,
 				return fmt.Errorf("field %q: expected array, got %T", name, val)
 			}
 			for i, item := range items {
-				elemVal, err := toProtoListElement(fd, item)
+				elemVal, err := toProtoListElement(fd, item, opts)
 				if err != nil {
 					return fmt.Errorf("field %q[%d]: %w", name, i, err)
 				}
@@ -73,11 +116,11 @@ func setMessageFields(msg *dynamicpb.Message, md protoreflect.MessageDescriptor,
 			keyFd := fd.MapKey()
 			valueFd := fd.MapValue()
 			for k, v := range items {
-				mapKey, err := toProtoScalar(keyFd, k)
+				mapKey, err := toProtoScalar(keyFd, k, opts)
 				if err != nil {
 					return fmt.Errorf("field %q map key %q: %w", name, k, err)
 				}
-				mapValue, err := toProtoMapValue(valueFd, v)
+				mapValue, err := toProtoMapValue(valueFd, v, opts)
 				if err != nil {
 					return fmt.Errorf("field %q map value for key %q: %w", name, k, err)
 				}
@@ -91,7 +134,7 @@ func setMessageFields(msg *dynamicpb.Message, md protoreflect.MessageDescriptor,
 	return nil
 }
 
-func toProtoValue(fd protoreflect.FieldDescriptor, val any) (protoreflect.Value, error) {
+func toProtoValue(fd protoreflect.FieldDescriptor, val any, opts ProtoJSONOptions) (protoreflect.Value, error) {
 	if fd.IsList() || fd.IsMap() {
 		// handled in caller
 		return protoreflect.Value{}, nil
@@ -99,55 +142,51 @@ func toProtoValue(fd protoreflect.FieldDescriptor, val any) (protoreflect.Value,
 
 	switch fd.Kind() {
 	case protoreflect.MessageKind, protoreflect.GroupKind:
-		nested, ok := val.(map[string]any)
-		if !ok {
-			return protoreflect.Value{}, fmt.Errorf("expected map for message, got %T", val)
-		}
-		subMsg := dynamicpb.NewMessage(fd.Message())
-		if err := setMessageFields(subMsg, fd.Message(), nested); err != nil {
-			return protoreflect.Value{}, err
-		}
-		return protoreflect.ValueOfMessage(subMsg), nil
+		return toProtoMessage(fd.Message(), val, opts)
 	default:
-		return toProtoScalar(fd, val)
+		return toProtoScalar(fd, val, opts)
 	}
 }
 
-func toProtoListElement(fd protoreflect.FieldDescriptor, val any) (protoreflect.Value, error) {
+func toProtoListElement(fd protoreflect.FieldDescriptor, val any, opts ProtoJSONOptions) (protoreflect.Value, error) {
 	switch fd.Kind() {
 	case protoreflect.MessageKind, protoreflect.GroupKind:
-		nested, ok := val.(map[string]any)
-		if !ok {
-			return protoreflect.Value{}, fmt.Errorf("expected map for message, got %T", val)
-		}
-		subMsg := dynamicpb.NewMessage(fd.Message())
-		if err := setMessageFields(subMsg, fd.Message(), nested); err != nil {
-			return protoreflect.Value{}, err
-		}
-		return protoreflect.ValueOfMessage(subMsg), nil
+		return toProtoMessage(fd.Message(), val, opts)
 	default:
-		return toProtoScalar(fd, val)
+		return toProtoScalar(fd, val, opts)
 	}
 }
 
-func toProtoMapValue(fd protoreflect.FieldDescriptor, val any) (protoreflect.Value, error) {
+func toProtoMapValue(fd protoreflect.FieldDescriptor, val any, opts ProtoJSONOptions) (protoreflect.Value, error) {
 	switch fd.Kind() {
 	case protoreflect.MessageKind, protoreflect.GroupKind:
-		nested, ok := val.(map[string]any)
-		if !ok {
-			return protoreflect.Value{}, fmt.Errorf("expected map for message, got %T", val)
-		}
-		subMsg := dynamicpb.NewMessage(fd.Message())
-		if err := setMessageFields(subMsg, fd.Message(), nested); err != nil {
-			return protoreflect.Value{}, err
+		return toProtoMessage(fd.Message(), val, opts)
+	default:
+		return toProtoScalar(fd, val, opts)
+	}
+}
+
+// toProtoMessage 将字段值转为消息, 知名类型按其 JSON 形式解析, 其他消息须为 map
+func toProtoMessage(md protoreflect.MessageDescriptor, val any, opts ProtoJSONOptions) (protoreflect.Value, error) {
+	subMsg := dynamicpb.NewMessage(md)
+	if handled, err := setWellKnown(subMsg, val, opts); handled {
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("%s: %w", md.FullName(), err)
 		}
 		return protoreflect.ValueOfMessage(subMsg), nil
-	default:
-		return toProtoScalar(fd, val)
 	}
+
+	nested, ok := val.(map[string]any)
+	if !ok {
+		return protoreflect.Value{}, fmt.Errorf("expected map for message, got %T", val)
+	}
+	if err := setMessageFields(subMsg, md, nested, opts); err != nil {
+		return protoreflect.Value{}, err
+	}
+	return protoreflect.ValueOfMessage(subMsg), nil
 }
 
-func toProtoScalar(fd protoreflect.FieldDescriptor, val any) (protoreflect.Value, error) {
+func toProtoScalar(fd protoreflect.FieldDescriptor, val any, opts ProtoJSONOptions) (protoreflect.Value, error) {
 	switch fd.Kind() {
 	case protoreflect.BoolKind:
 		b, ok := toBool(val)
@@ -158,29 +197,29 @@ func toProtoScalar(fd protoreflect.FieldDescriptor, val any) (protoreflect.Value
 
 	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
 		n, ok := toInt64(val)
-		if !ok {
-			return protoreflect.Value{}, fmt.Errorf("cannot convert %T to int32", val)
+		if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+			return protoreflect.Value{}, fmt.Errorf("cannot convert %T(%v) to int32", val, val)
 		}
 		return protoreflect.ValueOfInt32(int32(n)), nil
 
 	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
 		n, ok := toInt64(val)
 		if !ok {
-			return protoreflect.Value{}, fmt.Errorf("cannot convert %T to int64", val)
+			return protoreflect.Value{}, fmt.Errorf("cannot convert %T(%v) to int64, integers beyond 2^53 must be strings", val, val)
 		}
 		return protoreflect.ValueOfInt64(n), nil
 
 	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
 		n, ok := toUint64(val)
-		if !ok {
-			return protoreflect.Value{}, fmt.Errorf("cannot convert %T to uint32", val)
+		if !ok || n > math.MaxUint32 {
+			return protoreflect.Value{}, fmt.Errorf("cannot convert %T(%v) to uint32", val, val)
 		}
 		return protoreflect.ValueOfUint32(uint32(n)), nil
 
 	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
 		n, ok := toUint64(val)
 		if !ok {
-			return protoreflect.Value{}, fmt.Errorf("cannot convert %T to uint64", val)
+			return protoreflect.Value{}, fmt.Errorf("cannot convert %T(%v) to uint64, integers beyond 2^53 must be strings", val, val)
 		}
 		return protoreflect.ValueOfUint64(n), nil
 
@@ -210,6 +249,13 @@ func toProtoScalar(fd protoreflect.FieldDescriptor, val any) (protoreflect.Value
 		case []byte:
 			return protoreflect.ValueOfBytes(v), nil
 		case string:
+			if opts.BytesBase64 {
+				b, err := decodeBase64(v)
+				if err != nil {
+					return protoreflect.Value{}, fmt.Errorf("invalid base64 string: %w", err)
+				}
+				return protoreflect.ValueOfBytes(b), nil
+			}
 			b, err := hex.DecodeString(v)
 			if err != nil {
 				return protoreflect.Value{}, fmt.Errorf("invalid hex string: %w", err)
@@ -220,9 +266,14 @@ func toProtoScalar(fd protoreflect.FieldDescriptor, val any) (protoreflect.Value
 		}
 
 	case protoreflect.EnumKind:
+		if name, ok := val.(string); ok {
+			if ev := fd.Enum().Values().ByName(protoreflect.Name(name)); ev != nil {
+				return protoreflect.ValueOfEnum(ev.Number()), nil
+			}
+		}
 		n, ok := toInt64(val)
-		if !ok {
-			return protoreflect.Value{}, fmt.Errorf("cannot convert %T to enum", val)
+		if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+			return protoreflect.Value{}, fmt.Errorf("cannot convert %T(%v) to enum %s", val, val, fd.Enum().FullName())
 		}
 		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
 
@@ -231,8 +282,8 @@ func toProtoScalar(fd protoreflect.FieldDescriptor, val any) (protoreflect.Value
 	}
 }
 
-// messageToMap 将动态消息转为 map[string]any
-func messageToMap(msg *dynamicpb.Message) map[string]any {
+// messageToMap 将消息转为 map[string]any
+func messageToMap(msg protoreflect.Message, opts ProtoJSONOptions) map[string]any {
 	result := make(map[string]any)
 	md := msg.Descriptor()
 	fields := md.Fields()
@@ -244,60 +295,422 @@ func messageToMap(msg *dynamicpb.Message) map[string]any {
 		}
 
 		val := msg.Get(fd)
-		result[string(fd.Name())] = protoValueToAny(fd, val)
+		result[string(fd.Name())] = protoValueToAny(fd, val, opts)
+	}
+
+	// 服务端消息定义变更或路由映射错误时, 多出的字段和线格式不匹配的字段都会落入 unknown
+	if unknown, ok := wireValue(msg.GetUnknown()); ok {
+		result[unknownKey] = unknown
 	}
 
 	return result
 }
 
-func protoValueToAny(fd protoreflect.FieldDescriptor, val protoreflect.Value) any {
+func protoValueToAny(fd protoreflect.FieldDescriptor, val protoreflect.Value, opts ProtoJSONOptions) any {
 	if fd.IsMap() {
-		return mapToAny(fd, val.Map())
+		return mapToAny(fd, val.Map(), opts)
 	}
 	if fd.IsList() {
-		return listToAny(fd, val.List())
+		return listToAny(fd, val.List(), opts)
 	}
-	return scalarToAny(fd, val)
+	return scalarToAny(fd, val, opts)
 }
 
-func mapToAny(fd protoreflect.FieldDescriptor, m protoreflect.Map) any {
+func mapToAny(fd protoreflect.FieldDescriptor, m protoreflect.Map, opts ProtoJSONOptions) any {
 	result := make(map[string]any)
 	valueFd := fd.MapValue()
 	m.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
-		key := fmt.Sprintf("%v", k.Value().Interface())
-		result[key] = scalarToAny(valueFd, v)
+		result[k.String()] = scalarToAny(valueFd, v, opts)
 		return true
 	})
 	return result
 }
 
-func listToAny(fd protoreflect.FieldDescriptor, list protoreflect.List) any {
+func listToAny(fd protoreflect.FieldDescriptor, list protoreflect.List, opts ProtoJSONOptions) any {
 	result := make([]any, list.Len())
 	for i := 0; i < list.Len(); i++ {
-		result[i] = scalarToAny(fd, list.Get(i))
+		result[i] = scalarToAny(fd, list.Get(i), opts)
 	}
 	return result
 }
 
-func scalarToAny(fd protoreflect.FieldDescriptor, val protoreflect.Value) any {
+// scalarToAny 将单个字段值转为 JSON 友好的值, 映射规则见 ProtoJSONOptions
+func scalarToAny(fd protoreflect.FieldDescriptor, val protoreflect.Value, opts ProtoJSONOptions) any {
 	switch fd.Kind() {
 	case protoreflect.MessageKind, protoreflect.GroupKind:
-		dm, ok := val.Message().Interface().(*dynamicpb.Message)
-		if ok {
-			return messageToMap(dm)
-		}
-		return nil
+		return messageToAny(val.Message(), opts)
 	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(val.Enum()); ev != nil {
+			return string(ev.Name())
+		}
 		return int(val.Enum())
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		// JSON 数字在浏览器中为 float64, 超过 2^53 会丢失精度
+		return strconv.FormatInt(val.Int(), 10)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(val.Uint(), 10)
 	case protoreflect.BoolKind:
 		return val.Bool()
 	case protoreflect.BytesKind:
+		if opts.BytesBase64 {
+			return base64.StdEncoding.EncodeToString(val.Bytes())
+		}
 		return hex.EncodeToString(val.Bytes())
 	default:
 		return val.Interface()
 	}
 }
 
+// messageToAny 将消息转为 JSON 友好的值, 知名类型转为其 JSON 形式
+func messageToAny(msg protoreflect.Message, opts ProtoJSONOptions) any {
+	if v, ok := wellKnownToAny(msg, opts); ok {
+		return v
+	}
+	return messageToMap(msg, opts)
+}
+
+// ---- 知名类型(google/protobuf/*.proto) ----
+
+// 知名类型全限定名
+const (
+	wktAny       protoreflect.FullName = "google.protobuf.Any"
+	wktTimestamp protoreflect.FullName = "google.protobuf.Timestamp"
+	wktDuration  protoreflect.FullName = "google.protobuf.Duration"
+	wktStruct    protoreflect.FullName = "google.protobuf.Struct"
+	wktValue     protoreflect.FullName = "google.protobuf.Value"
+	wktListValue protoreflect.FullName = "google.protobuf.ListValue"
+	wktEmpty     protoreflect.FullName = "google.protobuf.Empty"
+)
+
+// anyTypeKey Any 的 JSON 形式中保存类型 URL 的键
+const anyTypeKey = "@type"
+
+// isWrapper 返回是否为包装类型(DoubleValue、Int64Value、StringValue 等), 其 JSON 形式为 value 字段的值
+func isWrapper(name protoreflect.FullName) bool {
+	switch name {
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		return true
+	}
+	return false
+}
+
+// isWellKnown 返回是否为有专门 JSON 形式的知名类型, 这类消息作为 Any 内容时放在 value 键中
+func isWellKnown(name protoreflect.FullName) bool {
+	switch name {
+	case wktAny, wktTimestamp, wktDuration, wktStruct, wktValue, wktListValue, wktEmpty:
+		return true
+	}
+	return isWrapper(name)
+}
+
+// wellKnownToAny 将知名类型转为其 JSON 形式, 不是知名类型时返回 false
+func wellKnownToAny(msg protoreflect.Message, opts ProtoJSONOptions) (any, bool) {
+	md := msg.Descriptor()
+	fields := md.Fields()
+	field := func(name protoreflect.Name) (protoreflect.FieldDescriptor, protoreflect.Value) {
+		fd := fields.ByName(name)
+		return fd, msg.Get(fd)
+	}
+
+	switch name := md.FullName(); {
+	case name == wktTimestamp:
+		_, secs := field("seconds")
+		_, nanos := field("nanos")
+		t := time.Unix(secs.Int(), nanos.Int()).UTC()
+		return t.Format("2006-01-02T15:04:05") + formatNanos(t.Nanosecond()) + "Z", true
+
+	case name == wktDuration:
+		_, secs := field("seconds")
+		_, nanos := field("nanos")
+		s, n := secs.Int(), nanos.Int()
+		sign := ""
+		if s < 0 || n < 0 {
+			sign, s, n = "-", -s, -n
+		}
+		return sign + strconv.FormatInt(s, 10) + formatNanos(int(n)) + "s", true
+
+	case isWrapper(name):
+		fd, v := field("value")
+		return scalarToAny(fd, v, opts), true
+
+	case name == wktStruct:
+		fd, v := field("fields")
+		return mapToAny(fd, v.Map(), opts), true
+
+	case name == wktListValue:
+		fd, v := field("values")
+		return listToAny(fd, v.List(), opts), true
+
+	case name == wktValue:
+		fd := msg.WhichOneof(md.Oneofs().ByName("kind"))
+		if fd == nil || fd.Name() == "null_value" {
+			return nil, true
+		}
+		return scalarToAny(fd, msg.Get(fd), opts), true
+
+	case name == wktEmpty:
+		return map[string]any{}, true
+
+	case name == wktAny:
+		_, typeURL := field("type_url")
+		_, value := field("value")
+		out := map[string]any{anyTypeKey: typeURL.String()}
+		inner := resolveAnyType(typeURL.String(), opts)
+		if inner == nil {
+			out[bodyHexKey] = hex.EncodeToString(value.Bytes())
+			return out, true
+		}
+		innerMsg := dynamicpb.NewMessage(inner)
+		if err := proto.Unmarshal(value.Bytes(), innerMsg); err != nil {
+			out[bodyHexKey] = hex.EncodeToString(value.Bytes())
+			return out, true
+		}
+		if isWellKnown(inner.FullName()) {
+			out["value"] = messageToAny(innerMsg, opts)
+			return out, true
+		}
+		for k, v := range messageToMap(innerMsg, opts) {
+			out[k] = v
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// maxDurationSeconds google.protobuf.Duration 的秒数上限(约 10000 年)
+const maxDurationSeconds = 315576000000
+
+// parseDuration 解析 Duration 的 JSON 形式 "[-]<秒>[.<小数>]s", 小数最多 9 位;
+// 与 protojson 一致, 不接受 "1h2m" 等 time.ParseDuration 的形式
+func parseDuration(s string) (int64, int32, error) {
+	body, ok := strings.CutSuffix(s, "s")
+	neg := strings.HasPrefix(body, "-")
+	if neg {
+		body = body[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(body, ".")
+	if !ok || !isDigits(whole) || (hasFrac && (!isDigits(frac) || len(frac) > 9)) {
+		return 0, 0, fmt.Errorf("invalid duration %q", s)
+	}
+	secs, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || secs > maxDurationSeconds {
+		return 0, 0, fmt.Errorf("duration %q out of range", s)
+	}
+	var nanos int64
+	if hasFrac {
+		nanos, _ = strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64)
+	}
+	if neg {
+		secs, nanos = -secs, -nanos
+	}
+	return secs, int32(nanos), nil
+}
+
+// isDigits 返回 s 是否为非空的十进制数字串
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// setWellKnown 按知名类型的 JSON 形式设置消息, 不是知名类型或 val 不是其 JSON 形式时返回 false,
+// 由调用方按普通消息处理(例如 Timestamp 仍可写成 {"seconds": ..., "nanos": ...})
+func setWellKnown(msg *dynamicpb.Message, val any, opts ProtoJSONOptions) (bool, error) {
+	md := msg.Descriptor()
+	fields := md.Fields()
+
+	switch name := md.FullName(); {
+	case name == wktTimestamp:
+		s, ok := val.(string)
+		if !ok {
+			return false, nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return true, err
+		}
+		msg.Set(fields.ByName("seconds"), protoreflect.ValueOfInt64(t.Unix()))
+		msg.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32(int32(t.Nanosecond())))
+		return true, nil
+
+	case name == wktDuration:
+		s, ok := val.(string)
+		if !ok {
+			return false, nil
+		}
+		secs, nanos, err := parseDuration(s)
+		if err != nil {
+			return true, err
+		}
+		msg.Set(fields.ByName("seconds"), protoreflect.ValueOfInt64(secs))
+		msg.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32(nanos))
+		return true, nil
+
+	case isWrapper(name):
+		if _, ok := val.(map[string]any); ok {
+			return false, nil
+		}
+		fd := fields.ByName("value")
+		v, err := toProtoScalar(fd, val, opts)
+		if err != nil {
+			return true, err
+		}
+		msg.Set(fd, v)
+		return true, nil
+
+	case name == wktStruct:
+		obj, ok := val.(map[string]any)
+		if !ok {
+			return true, fmt.Errorf("expected object, got %T", val)
+		}
+		fd := fields.ByName("fields")
+		m := msg.Mutable(fd).Map()
+		for k, e := range obj {
+			v, err := toProtoMessage(fd.MapValue().Message(), e, opts)
+			if err != nil {
+				return true, fmt.Errorf("key %q: %w", k, err)
+			}
+			m.Set(protoreflect.ValueOfString(k).MapKey(), v)
+		}
+		return true, nil
+
+	case name == wktListValue:
+		items, ok := val.([]any)
+		if !ok {
+			return true, fmt.Errorf("expected array, got %T", val)
+		}
+		return true, setValueList(msg, fields.ByName("values"), items, opts)
+
+	case name == wktValue:
+		return true, setValue(msg, val, opts)
+
+	case name == wktAny:
+		obj, ok := val.(map[string]any)
+		if !ok {
+			return false, nil
+		}
+		typeURL, ok := obj[anyTypeKey].(string)
+		if !ok {
+			return false, nil
+		}
+		inner := resolveAnyType(typeURL, opts)
+		if inner == nil {
+			return true, fmt.Errorf("unknown type %q", typeURL)
+		}
+		var content protoreflect.Value
+		var err error
+		if isWellKnown(inner.FullName()) {
+			content, err = toProtoMessage(inner, obj["value"], opts)
+		} else {
+			rest := make(map[string]any, len(obj))
+			for k, v := range obj {
+				if k != anyTypeKey {
+					rest[k] = v
+				}
+			}
+			content, err = toProtoMessage(inner, rest, opts)
+		}
+		if err != nil {
+			return true, err
+		}
+		data, err := proto.Marshal(content.Message().Interface())
+		if err != nil {
+			return true, err
+		}
+		msg.Set(fields.ByName("type_url"), protoreflect.ValueOfString(typeURL))
+		msg.Set(fields.ByName("value"), protoreflect.ValueOfBytes(data))
+		return true, nil
+	}
+	return false, nil
+}
+
+// setValue 按 JSON 值设置 google.protobuf.Value 的 kind
+func setValue(msg *dynamicpb.Message, val any, opts ProtoJSONOptions) error {
+	fields := msg.Descriptor().Fields()
+	switch v := val.(type) {
+	case nil:
+		msg.Set(fields.ByName("null_value"), protoreflect.ValueOfEnum(0))
+	case bool:
+		msg.Set(fields.ByName("bool_value"), protoreflect.ValueOfBool(v))
+	case string:
+		msg.Set(fields.ByName("string_value"), protoreflect.ValueOfString(v))
+	case map[string]any:
+		fd := fields.ByName("struct_value")
+		sv, err := toProtoMessage(fd.Message(), v, opts)
+		if err != nil {
+			return err
+		}
+		msg.Set(fd, sv)
+	case []any:
+		fd := fields.ByName("list_value")
+		lv := dynamicpb.NewMessage(fd.Message())
+		if err := setValueList(lv, lv.Descriptor().Fields().ByName("values"), v, opts); err != nil {
+			return err
+		}
+		msg.Set(fd, protoreflect.ValueOfMessage(lv))
+	default:
+		f, ok := toFloat64(v)
+		if !ok {
+			return fmt.Errorf("cannot convert %T to google.protobuf.Value", val)
+		}
+		msg.Set(fields.ByName("number_value"), protoreflect.ValueOfFloat64(f))
+	}
+	return nil
+}
+
+// setValueList 设置 ListValue 的 values 字段
+func setValueList(msg *dynamicpb.Message, fd protoreflect.FieldDescriptor, items []any, opts ProtoJSONOptions) error {
+	list := msg.Mutable(fd).List()
+	for i, item := range items {
+		v, err := toProtoMessage(fd.Message(), item, opts)
+		if err != nil {
+			return fmt.Errorf("[%d]: %w", i, err)
+		}
+		list.Append(v)
+	}
+	return nil
+}
+
+// resolveAnyType 根据 Any 的类型 URL(最后一个 / 之后为全限定名)查找消息描述符
+func resolveAnyType(typeURL string, opts ProtoJSONOptions) protoreflect.MessageDescriptor {
+	name := typeURL
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	if opts.Resolver != nil {
+		if md := opts.Resolver(name); md != nil {
+			return md
+		}
+	}
+	if d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name)); err == nil {
+		if md, ok := d.(protoreflect.MessageDescriptor); ok {
+			return md
+		}
+	}
+	return nil
+}
+
+// formatNanos 按 protojson 的格式输出秒的小数部分: 0、3、6 或 9 位, 0 时为空
+func formatNanos(nanos int) string {
+	if nanos == 0 {
+		return ""
+	}
+	frac := fmt.Sprintf("%09d", nanos)
+	for strings.HasSuffix(frac, "000") {
+		frac = frac[:len(frac)-3]
+	}
+	return "." + frac
+}
+
 // 类型转换辅助函数
 
 func toBool(v any) (bool, bool) {
@@ -309,6 +722,7 @@ func toBool(v any) (bool, bool) {
 	}
 }
 
+// toInt64 转换为 int64, 浮点数须为整数且不超过 2^53, 字符串按十进制解析
 func toInt64(v any) (int64, bool) {
 	switch val := v.(type) {
 	case int:
@@ -322,14 +736,27 @@ func toInt64(v any) (int64, bool) {
 	case int64:
 		return val, true
 	case float64:
+		if !isExactInt(val) {
+			return 0, false
+		}
 		return int64(val), true
 	case float32:
+		if !isExactInt(float64(val)) {
+			return 0, false
+		}
 		return int64(val), true
+	case string:
+		n, err := strconv.ParseInt(val, 10, 64)
+		return n, err == nil
+	case json.Number:
+		n, err := val.Int64()
+		return n, err == nil
 	default:
 		return 0, false
 	}
 }
 
+// toUint64 转换为 uint64, 负数返回 false, 其余规则同 toInt64
 func toUint64(v any) (uint64, bool) {
 	switch val := v.(type) {
 	case uint:
@@ -343,16 +770,30 @@ func toUint64(v any) (uint64, bool) {
 	case uint64:
 		return val, true
 	case int:
-		return uint64(val), true
+		return uint64(val), val >= 0
 	case int64:
-		return uint64(val), true
+		return uint64(val), val >= 0
 	case float64:
+		if !isExactInt(val) || val < 0 {
+			return 0, false
+		}
 		return uint64(val), true
+	case string:
+		n, err := strconv.ParseUint(val, 10, 64)
+		return n, err == nil
+	case json.Number:
+		n, err := strconv.ParseUint(val.String(), 10, 64)
+		return n, err == nil
 	default:
 		return 0, false
 	}
 }
 
+// isExactInt 返回浮点数是否为 float64 能精确表示的整数
+func isExactInt(f float64) bool {
+	return f == math.Trunc(f) && math.Abs(f) <= maxExactFloat
+}
+
 func toFloat64(v any) (float64, bool) {
 	switch val := v.(type) {
 	case float32:
@@ -363,7 +804,26 @@ func toFloat64(v any) (float64, bool) {
 		return float64(val), true
 	case int64:
 		return float64(val), true
+	case string:
+		// protojson 以字符串表示 NaN、Infinity 和 -Infinity
+		f, err := strconv.ParseFloat(val, 64)
+		return f, err == nil
+	case json.Number:
+		f, err := val.Float64()
+		return f, err == nil
 	default:
 		return math.NaN(), false
 	}
 }
+
+// decodeBase64 解码标准或 URL 安全的 base64, 填充可省略(与 protojson 一致)
+func decodeBase64(s string) ([]byte, error) {
+	enc := base64.StdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.URLEncoding
+	}
+	if len(s)%4 != 0 {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	return enc.DecodeString(s)
+}
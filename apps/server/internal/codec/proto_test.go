@@ -2,6 +2,8 @@ package codec
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -102,8 +104,25 @@ message TestMsg {
 		t.Fatalf("DynamicDecode error: %v", err)
 	}
 
-	if result["status"] != 1 {
-		t.Fatalf("status = %v, want 1", result["status"])
+	// 枚举按 protojson 解码为值名称
+	if result["status"] != "ACTIVE" {
+		t.Fatalf("status = %v, want ACTIVE", result["status"])
+	}
+
+	// 编码接受值名称, 未定义的枚举值解码时保留数字
+	data, err = DynamicEncode(md, map[string]any{"status": "INACTIVE"})
+	if err != nil {
+		t.Fatalf("DynamicEncode error: %v", err)
+	}
+	if result, _ := DynamicDecode(data, md); result["status"] != "INACTIVE" {
+		t.Fatalf("status = %v, want INACTIVE", result["status"])
+	}
+	data, _ = DynamicEncode(md, map[string]any{"status": 7})
+	if result, _ := DynamicDecode(data, md); result["status"] != 7 {
+		t.Fatalf("status = %v, want 7", result["status"])
+	}
+	if _, err := DynamicEncode(md, map[string]any{"status": "DELETED"}); err == nil {
+		t.Fatal("expected error for unknown enum name")
 	}
 }
 
@@ -311,3 +330,191 @@ message TestMsg {
 		t.Fatalf("data = %v, want %q", result["data"], "deadbeef")
 	}
 }
+
+func TestDynamicInt64AsString(t *testing.T) {
+	proto := `syntax = "proto3";
+message TestMsg {
+  int64 uid = 1;
+  fixed64 token = 2;
+  int32 level = 3;
+}`
+	md := compileProto(t, proto, "TestMsg")
+
+	// 超过 2^53 的整数以字符串传入和返回, 不经过 float64
+	data, err := DynamicEncode(md, map[string]any{"uid": "9007199254740993", "token": "18446744073709551615", "level": float64(3)})
+	if err != nil {
+		t.Fatalf("DynamicEncode error: %v", err)
+	}
+	result, err := DynamicDecode(data, md)
+	if err != nil {
+		t.Fatalf("DynamicDecode error: %v", err)
+	}
+	if result["uid"] != "9007199254740993" || result["token"] != "18446744073709551615" || result["level"] != int32(3) {
+		t.Fatalf("result = %#v", result)
+	}
+
+	for _, fields := range []map[string]any{
+		{"uid": float64(1 << 60)}, // 已丢失精度的 JSON 数字
+		{"uid": 1.5},
+		{"uid": "12abc"},
+		{"level": float64(1 << 40)},
+		{"token": -1},
+	} {
+		if _, err := DynamicEncode(md, fields); err == nil {
+			t.Errorf("DynamicEncode(%v): expected error", fields)
+		}
+	}
+}
+
+func TestDynamicBytesBase64(t *testing.T) {
+	proto := `syntax = "proto3";
+message TestMsg {
+  bytes data = 1;
+}`
+	md := compileProto(t, proto, "TestMsg")
+	opts := ProtoJSONOptions{BytesBase64: true}
+
+	data, err := DynamicEncodeWith(md, map[string]any{"data": "3q2-7w"}, opts)
+	if err != nil {
+		t.Fatalf("DynamicEncodeWith error: %v", err)
+	}
+	result, err := DynamicDecodeWith(data, md, opts)
+	if err != nil {
+		t.Fatalf("DynamicDecodeWith error: %v", err)
+	}
+	if result["data"] != "3q2+7w==" {
+		t.Fatalf("data = %v, want %q", result["data"], "3q2+7w==")
+	}
+	if result, _ := DynamicDecode(data, md); result["data"] != "deadbeef" {
+		t.Fatalf("hex data = %v, want deadbeef", result["data"])
+	}
+}
+
+func TestDynamicWellKnownTypes(t *testing.T) {
+	proto := `syntax = "proto3";
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/duration.proto";
+import "google/protobuf/struct.proto";
+import "google/protobuf/wrappers.proto";
+enum Status {
+  UNKNOWN = 0;
+  ACTIVE = 1;
+}
+message TestMsg {
+  google.protobuf.Timestamp at = 1;
+  google.protobuf.Duration ttl = 2;
+  google.protobuf.Struct extra = 3;
+  google.protobuf.Int64Value uid = 4;
+  map<int32, Status> states = 5;
+  repeated google.protobuf.Timestamp history = 6;
+}`
+	md := compileProto(t, proto, "TestMsg")
+
+	fields := map[string]any{
+		"at":      "2024-05-01T08:30:00.250Z",
+		"ttl":     "-1.5s",
+		"extra":   map[string]any{"name": "bob", "tags": []any{"a", 1.5, nil, map[string]any{"ok": true}}},
+		"uid":     "9007199254740993",
+		"states":  map[string]any{"3": "ACTIVE"},
+		"history": []any{"1970-01-01T00:00:01Z"},
+	}
+	data, err := DynamicEncode(md, fields)
+	if err != nil {
+		t.Fatalf("DynamicEncode error: %v", err)
+	}
+	result, err := DynamicDecode(data, md)
+	if err != nil {
+		t.Fatalf("DynamicDecode error: %v", err)
+	}
+
+	if result["at"] != "2024-05-01T08:30:00.250Z" || result["ttl"] != "-1.500s" || result["uid"] != "9007199254740993" {
+		t.Fatalf("result = %#v", result)
+	}
+	extra, _ := result["extra"].(map[string]any)
+	tags, _ := extra["tags"].([]any)
+	if extra["name"] != "bob" || len(tags) != 4 || tags[1] != 1.5 || tags[2] != nil {
+		t.Fatalf("extra = %#v", result["extra"])
+	}
+	if nested, _ := tags[3].(map[string]any); nested["ok"] != true {
+		t.Fatalf("extra.tags[3] = %#v", tags[3])
+	}
+	if states, _ := result["states"].(map[string]any); states["3"] != "ACTIVE" {
+		t.Fatalf("states = %#v", result["states"])
+	}
+	if history, _ := result["history"].([]any); len(history) != 1 || history[0] != "1970-01-01T00:00:01Z" {
+		t.Fatalf("history = %#v", result["history"])
+	}
+
+	// 仍接受消息字段形式, null 表示不设置
+	data, err = DynamicEncode(md, map[string]any{"at": map[string]any{"seconds": 1}, "ttl": nil})
+	if err != nil {
+		t.Fatalf("DynamicEncode error: %v", err)
+	}
+	if result, _ := DynamicDecode(data, md); result["at"] != "1970-01-01T00:00:01Z" || result["ttl"] != nil {
+		t.Fatalf("result = %#v", result)
+	}
+	if _, err := DynamicEncode(md, map[string]any{"at": "yesterday"}); err == nil {
+		t.Fatal("expected error for invalid timestamp")
+	}
+
+	// Duration 只接受 protojson 的 "<秒>[.<小数>]s" 形式
+	for _, ttl := range []string{"1h2m", "1.5ms", "+1s", ".5s", "1.s", "1.0000000001s", "315576000001s", "1"} {
+		if _, err := DynamicEncode(md, map[string]any{"ttl": ttl}); err == nil {
+			t.Fatalf("expected error for duration %q", ttl)
+		}
+	}
+	data, err = DynamicEncode(md, map[string]any{"ttl": "315576000000.000000001s"})
+	if err != nil {
+		t.Fatalf("DynamicEncode error: %v", err)
+	}
+	if result, _ := DynamicDecode(data, md); result["ttl"] != "315576000000.000000001s" {
+		t.Fatalf("ttl = %#v", result["ttl"])
+	}
+}
+
+func TestDynamicAny(t *testing.T) {
+	proto := `syntax = "proto3";
+package game;
+import "google/protobuf/any.proto";
+message Item {
+  int32 id = 1;
+  string name = 2;
+}
+message Envelope {
+  google.protobuf.Any body = 1;
+}`
+	md := compileProto(t, proto, "Envelope")
+	opts := ProtoJSONOptions{Resolver: func(name string) protoreflect.MessageDescriptor {
+		return md.ParentFile().Messages().ByName(protoreflect.FullName(name).Name())
+	}}
+
+	fields := map[string]any{"body": map[string]any{"@type": "type.googleapis.com/game.Item", "id": 7, "name": "sword"}}
+	data, err := DynamicEncodeWith(md, fields, opts)
+	if err != nil {
+		t.Fatalf("DynamicEncodeWith error: %v", err)
+	}
+	result, err := DynamicDecodeWith(data, md, opts)
+	if err != nil {
+		t.Fatalf("DynamicDecodeWith error: %v", err)
+	}
+	body, _ := result["body"].(map[string]any)
+	if body["@type"] != "type.googleapis.com/game.Item" || body["id"] != int32(7) || body["name"] != "sword" {
+		t.Fatalf("body = %#v", result["body"])
+	}
+
+	// 无法解析类型时保留原始字节
+	result, err = DynamicDecode(data, md)
+	if err != nil {
+		t.Fatalf("DynamicDecode error: %v", err)
+	}
+	// dynamicpb 序列化的字段顺序不固定, 按 game.Item 解码后比较字段值
+	body, _ = result["body"].(map[string]any)
+	raw, err := hex.DecodeString(fmt.Sprint(body["_hex"]))
+	if err != nil {
+		t.Fatalf("body = %#v", result["body"])
+	}
+	item, err := DynamicDecode(raw, opts.Resolver("game.Item"))
+	if err != nil || item["id"] != int32(7) || item["name"] != "sword" {
+		t.Fatalf("item = %#v, %v", item, err)
+	}
+}
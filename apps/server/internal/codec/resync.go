@@ -0,0 +1,79 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidFrame 帧结构非法(长度为 0、小于最小长度、未知包类型等)
+var ErrInvalidFrame = errors.New("invalid packet")
+
+// ErrFrameTooLarge 帧长度超出 MaxFrameSize, 可用 errors.Is 判断
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// FrameTooLargeError 帧长度超出上限, 在分配缓冲区之前返回
+type FrameTooLargeError struct {
+	Size  uint64 // 帧头声明的帧长度(含帧头)
+	Limit int    // 生效的上限
+}
+
+func (e *FrameTooLargeError) Error() string {
+	return fmt.Sprintf("frame too large: %d > %d", e.Size, e.Limit)
+}
+
+// Is 使 errors.Is(err, ErrFrameTooLarge) 成立
+func (e *FrameTooLargeError) Is(target error) bool {
+	return target == ErrFrameTooLarge
+}
+
+// IsProtocolError 判断错误是否为协议错误(而非连接 I/O 错误)
+func IsProtocolError(err error) bool {
+	return errors.Is(err, ErrInvalidFrame) || errors.Is(err, ErrFrameTooLarge)
+}
+
+// ResyncConfig 流式解码的重新同步策略
+//
+// 遇到协议错误后逐字节向前扫描, 直到 Magic 出现在候选帧起点的 Offset 处,
+// 将该位置视为下一帧的开始
+type ResyncConfig struct {
+	Magic  []byte // 同步标记, 如帧头中的固定魔数
+	Offset int    // 同步标记相对帧起点的偏移
+}
+
+// ResyncError 解码器遇到协议错误并已重新同步, 可继续解码
+type ResyncError struct {
+	Err     error // 触发重新同步的协议错误
+	Skipped int   // 为找到同步标记跳过的字节数(不含坏帧已读取的部分)
+}
+
+func (e *ResyncError) Error() string {
+	return fmt.Sprintf("resynced after %d bytes: %v", e.Skipped, e.Err)
+}
+
+func (e *ResyncError) Unwrap() error {
+	return e.Err
+}
+
+// recover 对协议错误执行重新同步, 其他错误原样返回
+func (d *Decoder) recover(err error) error {
+	if d.cfg.Resync == nil || len(d.cfg.Resync.Magic) == 0 || !IsProtocolError(err) {
+		return err
+	}
+
+	magic := d.cfg.Resync.Magic
+	window := d.cfg.Resync.Offset + len(magic)
+	skipped := 0
+	for {
+		peek, perr := d.buffered.Peek(window)
+		if perr != nil {
+			// 流结束前未找到同步标记
+			return fmt.Errorf("resync: %w (skipped %d bytes): %v", perr, skipped, err)
+		}
+		if bytes.Equal(peek[d.cfg.Resync.Offset:], magic) {
+			return &ResyncError{Err: err, Skipped: skipped}
+		}
+		d.buffered.Discard(1)
+		skipped++
+	}
+}
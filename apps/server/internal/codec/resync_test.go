@@ -0,0 +1,127 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestDecodeFrameTooLarge(t *testing.T) {
+	// legacy Due: size 字段声明 1 GiB
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint32(buf, 1<<30)
+	decoder := NewDecoder(bytes.NewReader(buf), DefaultPacketConfig())
+	_, err := decoder.Decode()
+	if !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("expected ErrFrameTooLarge, got %v", err)
+	}
+	var sizeErr *FrameTooLargeError
+	if !errors.As(err, &sizeErr) || sizeErr.Limit != DefaultMaxFrameSize {
+		t.Fatalf("FrameTooLargeError = %+v", sizeErr)
+	}
+
+	// 字段驱动: 自定义上限
+	cfg := antnetConfig()
+	cfg.MaxFrameSize = 64
+	encoded, _ := Encode(&Packet{Route: 1, Data: make([]byte, 100)}, cfg)
+	_, err = NewDecoder(bytes.NewReader(encoded), cfg).Decode()
+	if !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("field-driven: expected ErrFrameTooLarge, got %v", err)
+	}
+
+	// Pomelo
+	pomeloCfg := PacketConfig{Pomelo: &PomeloConfig{}, MaxFrameSize: 16}
+	_, err = NewDecoder(bytes.NewReader(PomeloEncodeHandshake(make([]byte, 32))), pomeloCfg).DecodeRaw()
+	if !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("pomelo: expected ErrFrameTooLarge, got %v", err)
+	}
+
+	// 负数表示不限制
+	cfg.MaxFrameSize = -1
+	if _, err := NewDecoder(bytes.NewReader(encoded), cfg).Decode(); err != nil {
+		t.Fatalf("unlimited: Decode error: %v", err)
+	}
+}
+
+func TestDecodeProtocolErrorClassification(t *testing.T) {
+	buf := make([]byte, 4)
+	_, err := NewDecoder(bytes.NewReader(buf), DefaultPacketConfig()).Decode()
+	if !IsProtocolError(err) || !errors.Is(err, ErrInvalidFrame) {
+		t.Fatalf("zero size should be a protocol error, got %v", err)
+	}
+
+	_, err = NewDecoder(bytes.NewReader(buf[:2]), DefaultPacketConfig()).Decode()
+	if IsProtocolError(err) {
+		t.Fatalf("short read should not be a protocol error: %v", err)
+	}
+}
+
+// magicConfig magic(2) + len(4) + cmd(2,route), 大端序
+func magicConfig(t *testing.T) PacketConfig {
+	t.Helper()
+	fd, err := NewFieldDrivenConfig([]FieldDef{
+		{Name: "magic", Bytes: 2},
+		{Name: "len", Bytes: 4},
+		{Name: "cmd", Bytes: 2, IsRoute: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.BigEndian = true
+	return PacketConfig{
+		FieldDriven:  fd,
+		MaxFrameSize: 1024,
+		Resync:       &ResyncConfig{Magic: []byte{0xCA, 0xFE}},
+	}
+}
+
+// withMagic 编码帧并填入魔数(字段驱动编码将非路由/seq 字段写为 0)
+func withMagic(t *testing.T, pkt *Packet, cfg PacketConfig) []byte {
+	t.Helper()
+	encoded, err := Encode(pkt, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded[0], encoded[1] = 0xCA, 0xFE
+	return encoded
+}
+
+func TestDecodeResyncMagic(t *testing.T) {
+	cfg := magicConfig(t)
+
+	var stream []byte
+	stream = append(stream, withMagic(t, &Packet{Route: 1, Data: []byte("a")}, cfg)...)
+	stream = append(stream, bytes.Repeat([]byte{0xFF}, 10)...)
+	stream = append(stream, withMagic(t, &Packet{Route: 2, Data: []byte("b")}, cfg)...)
+
+	decoder := NewDecoder(bytes.NewReader(stream), cfg)
+	if pkt, err := decoder.Decode(); err != nil || pkt.Route != 1 {
+		t.Fatalf("first Decode = %+v, %v", pkt, err)
+	}
+
+	_, err := decoder.Decode()
+	var resyncErr *ResyncError
+	if !errors.As(err, &resyncErr) {
+		t.Fatalf("expected ResyncError, got %v", err)
+	}
+	// 坏帧头消耗 8 字节, 剩余 2 字节垃圾被跳过
+	if resyncErr.Skipped != 2 || !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("ResyncError = %+v", resyncErr)
+	}
+
+	if pkt, err := decoder.Decode(); err != nil || pkt.Route != 2 {
+		t.Fatalf("Decode after resync = %+v, %v", pkt, err)
+	}
+}
+
+func TestDecodeResyncWithoutMagicInStream(t *testing.T) {
+	cfg := magicConfig(t)
+	stream := bytes.Repeat([]byte{0xFF}, 20)
+
+	_, err := NewDecoder(bytes.NewReader(stream), cfg).Decode()
+	var resyncErr *ResyncError
+	if err == nil || errors.As(err, &resyncErr) {
+		t.Fatalf("expected fatal error when no magic found, got %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ProtocolSkynet Skynet 的 sproto RPC 帧
+//
+// 帧格式: size(2B 大端) + sproto pack(package 头 + 消息内容). package 头为 sproto 结构
+// { type 0 : integer; session 1 : integer; ud 2 : integer }, type 映射为 Route, session 映射为 Seq;
+// 响应不带 type, 按 session 匹配请求. 消息内容为 sproto 编码的参数, 原样保存在 Packet.Data
+const ProtocolSkynet = "skynet"
+
+// SkynetFieldUD package 头 ud 字段在 Packet.Fields 中的键
+const SkynetFieldUD = "ud"
+
+// skynetMaxPayload size 字段可表示的最大包长
+const skynetMaxPayload = math.MaxUint16
+
+func init() {
+	RegisterFrameCodec(ProtocolSkynet, func(cfg PacketConfig) (FrameCodec, error) {
+		return &skynetCodec{cfg: cfg}, nil
+	})
+}
+
+// skynetCodec Skynet sproto 帧编解码器
+type skynetCodec struct {
+	cfg PacketConfig
+}
+
+func (c *skynetCodec) Encode(pkt *Packet) ([]byte, error) {
+	var header sprotoStruct
+	if pkt.Route != 0 {
+		header.set(0, int64(pkt.Route))
+	}
+	if pkt.Seq != 0 {
+		header.set(1, int64(pkt.Seq))
+	}
+	if ud, ok := pkt.Fields[SkynetFieldUD]; ok {
+		header.set(2, int64(ud))
+	}
+	payload := sprotoPack(append(header.encode(), pkt.Data...))
+	if len(payload) > skynetMaxPayload {
+		return nil, fmt.Errorf("skynet: packed payload %d bytes exceeds %d", len(payload), skynetMaxPayload)
+	}
+
+	buf := make([]byte, 2+len(payload))
+	binary.BigEndian.PutUint16(buf, uint16(len(payload)))
+	copy(buf[2:], payload)
+	return buf, nil
+}
+
+func (c *skynetCodec) DecodeBytes(data []byte) (*Packet, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("skynet: data too short: %d < 2", len(data))
+	}
+	size := int(binary.BigEndian.Uint16(data))
+	if 2+size > len(data) {
+		return nil, fmt.Errorf("skynet: incomplete packet: need %d, have %d", 2+size, len(data))
+	}
+	return skynetDecodePayload(data[2 : 2+size])
+}
+
+func (c *skynetCodec) Decode(r io.Reader) (*Packet, error) {
+	raw, err := c.DecodeRaw(r)
+	if err != nil {
+		return nil, err
+	}
+	return skynetDecodePayload(raw[2:])
+}
+
+// DecodeRaw 读取一个完整的 Skynet 包而不解析
+func (c *skynetCodec) DecodeRaw(r io.Reader) ([]byte, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint16(head))
+	if size == 0 {
+		return nil, fmt.Errorf("%w: skynet payload size is 0", ErrInvalidFrame)
+	}
+	if err := c.cfg.checkFrameSize(uint64(2 + size)); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 2+size)
+	copy(buf, head)
+	if _, err := io.ReadFull(r, buf[2:]); err != nil {
+		return nil, fmt.Errorf("read payload: %w", err)
+	}
+	return buf, nil
+}
+
+// skynetDecodePayload 解包并解析 package 头, 剩余的消息内容去掉 pack 补齐的 0 字节后作为 Data
+func skynetDecodePayload(payload []byte) (*Packet, error) {
+	data, err := sprotoUnpack(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: skynet: %v", ErrInvalidFrame, err)
+	}
+	header, n, err := sprotoDecodeStruct(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: skynet package: %v", ErrInvalidFrame, err)
+	}
+
+	pkt := &Packet{}
+	if v, ok := header[0]; ok {
+		pkt.Route = uint64(v)
+	}
+	if v, ok := header[1]; ok {
+		pkt.Seq = uint64(v)
+	}
+	if v, ok := header[2]; ok {
+		pkt.Fields = map[string]uint64{SkynetFieldUD: uint64(v)}
+	}
+
+	content := data[n:]
+	if _, m, err := sprotoDecodeStruct(content); err == nil {
+		content = content[:m]
+	}
+	if isZero(content) {
+		content = nil
+	}
+	pkt.Data = content
+	return pkt, nil
+}
+
+// isZero 返回 b 是否全为 0
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ---- sproto 编码 ----
+
+// sprotoStruct 只含 integer 字段的 sproto 结构, 用于 package 头
+type sprotoStruct struct {
+	tags   []int
+	values []int64
+}
+
+// set 追加字段, tag 需递增
+func (s *sprotoStruct) set(tag int, v int64) {
+	s.tags = append(s.tags, tag)
+	s.values = append(s.values, v)
+}
+
+// encode 按 sproto 格式编码: count(2) + 字段值(2 × count) + 数据段
+//
+// 0 ≤ v < 0x7fff 的整数直接写入字段值 (v+1)*2; 其他整数写入数据段 size(4) + int32/int64,
+// 字段值为 0; 跳过的 tag 以奇数字段值 (跳过数-1)*2+1 表示. 所有数值均为小端序
+func (s *sprotoStruct) encode() []byte {
+	var fields []uint16
+	var data []byte
+	last := -1
+	for i, tag := range s.tags {
+		if skip := tag - last - 1; skip > 0 {
+			fields = append(fields, uint16((skip-1)*2+1))
+		}
+		last = tag
+		v := s.values[i]
+		switch {
+		case v >= 0 && v < 0x7fff:
+			fields = append(fields, uint16((v+1)*2))
+		case v >= math.MinInt32 && v <= math.MaxInt32:
+			fields = append(fields, 0)
+			data = binary.LittleEndian.AppendUint32(data, 4)
+			data = binary.LittleEndian.AppendUint32(data, uint32(int32(v)))
+		default:
+			fields = append(fields, 0)
+			data = binary.LittleEndian.AppendUint32(data, 8)
+			data = binary.LittleEndian.AppendUint64(data, uint64(v))
+		}
+	}
+
+	buf := binary.LittleEndian.AppendUint16(nil, uint16(len(fields)))
+	for _, f := range fields {
+		buf = binary.LittleEndian.AppendUint16(buf, f)
+	}
+	return append(buf, data...)
+}
+
+// sprotoDecodeStruct 解码 sproto 结构中的 integer 字段, 返回 tag → 值和结构占用的字节数
+//
+// 数据段中长度不是 4/8 的字段(字符串、子结构、数组)只计算长度, 不返回其值
+func sprotoDecodeStruct(data []byte) (map[int]int64, int, error) {
+	if len(data) < 2 {
+		return nil, 0, errors.New("sproto header too short")
+	}
+	count := int(binary.LittleEndian.Uint16(data))
+	off := 2 + 2*count
+	if len(data) < off {
+		return nil, 0, fmt.Errorf("sproto header needs %d bytes, have %d", off, len(data))
+	}
+
+	values := make(map[int]int64, count)
+	tag := -1
+	for i := 0; i < count; i++ {
+		v := int(binary.LittleEndian.Uint16(data[2+2*i:]))
+		tag++
+		if v&1 != 0 {
+			tag += v / 2
+			continue
+		}
+		if v != 0 {
+			values[tag] = int64(v/2 - 1)
+			continue
+		}
+		if len(data) < off+4 {
+			return nil, 0, errors.New("sproto data segment truncated")
+		}
+		size := int(binary.LittleEndian.Uint32(data[off:]))
+		off += 4
+		if size > len(data)-off {
+			return nil, 0, fmt.Errorf("sproto field %d needs %d bytes, have %d", tag, size, len(data)-off)
+		}
+		switch size {
+		case 4:
+			values[tag] = int64(int32(binary.LittleEndian.Uint32(data[off:])))
+		case 8:
+			values[tag] = int64(binary.LittleEndian.Uint64(data[off:]))
+		}
+		off += size
+	}
+	return values, off, nil
+}
+
+// sprotoPack 0-pack 压缩: 每 8 字节一组, 组头为非 0 字节的位图, 后跟非 0 字节;
+// 全部非 0 的连续组以 0xff + (组数-1) + 原始字节表示. 末组不足 8 字节时补 0
+func sprotoPack(src []byte) []byte {
+	out := make([]byte, 0, len(src)+len(src)/8+2)
+	var run []byte // 待写出的全非 0 组
+	flush := func() {
+		if len(run) > 0 {
+			out = append(out, 0xff, byte(len(run)/8-1))
+			out = append(out, run...)
+			run = run[:0]
+		}
+	}
+
+	for i := 0; i < len(src); i += 8 {
+		var group [8]byte
+		copy(group[:], src[i:])
+		var mask byte
+		var nz []byte
+		for j, b := range group {
+			if b != 0 {
+				mask |= 1 << j
+				nz = append(nz, b)
+			}
+		}
+		if mask == 0xff {
+			run = append(run, group[:]...)
+			if len(run) == 256*8 {
+				flush()
+			}
+			continue
+		}
+		flush()
+		out = append(out, mask)
+		out = append(out, nz...)
+	}
+	flush()
+	return out
+}
+
+// sprotoUnpack 还原 sprotoPack 的输出, 结果长度为 8 的倍数
+func sprotoUnpack(src []byte) ([]byte, error) {
+	out := make([]byte, 0, len(src)*2)
+	for len(src) > 0 {
+		mask := src[0]
+		src = src[1:]
+		if mask == 0xff {
+			if len(src) < 1 {
+				return nil, errors.New("sproto pack: truncated run")
+			}
+			n := (int(src[0]) + 1) * 8
+			if len(src) < 1+n {
+				return nil, fmt.Errorf("sproto pack: run needs %d bytes, have %d", n, len(src)-1)
+			}
+			out = append(out, src[1:1+n]...)
+			src = src[1+n:]
+			continue
+		}
+		for j := 0; j < 8; j++ {
+			if mask&(1<<j) == 0 {
+				out = append(out, 0)
+				continue
+			}
+			if len(src) == 0 {
+				return nil, errors.New("sproto pack: truncated group")
+			}
+			out = append(out, src[0])
+			src = src[1:]
+		}
+	}
+	return out, nil
+}
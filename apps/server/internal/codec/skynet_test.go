@@ -0,0 +1,72 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSkynetWireFormat(t *testing.T) {
+	cfg, _ := Preset(ProtocolSkynet)
+
+	// package{type=1, session=1}: 02 00 04 00 04 00, pack 后为 15 02 04 04
+	buf, err := Encode(&Packet{Route: 1, Seq: 1}, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	want := []byte{0x00, 0x04, 0x15, 0x02, 0x04, 0x04}
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("frame = % x, want % x", buf, want)
+	}
+
+	pkt, err := DecodeBytes(buf, cfg)
+	if err != nil {
+		t.Fatalf("DecodeBytes error: %v", err)
+	}
+	if pkt.Route != 1 || pkt.Seq != 1 || pkt.Data != nil {
+		t.Fatalf("pkt = %+v", pkt)
+	}
+}
+
+func TestSkynetRoundTrip(t *testing.T) {
+	cfg, _ := Preset(ProtocolSkynet)
+
+	// 消息内容: sproto 结构 { 0: 5, 1: "abcdefghijklmnop" }, 含全非 0 的连续组
+	content := append([]byte{0x02, 0x00, 0x0c, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00}, "abcdefghijklmnop"...)
+
+	var stream bytes.Buffer
+	for _, pkt := range []*Packet{
+		{Route: 3, Seq: 70000, Data: content, Fields: map[string]uint64{SkynetFieldUD: 9}},
+		{Seq: 70000, Data: content}, // 响应不带 type
+	} {
+		buf, err := Encode(pkt, cfg)
+		if err != nil {
+			t.Fatalf("Encode error: %v", err)
+		}
+		stream.Write(buf)
+	}
+
+	dec := NewDecoder(&stream, cfg)
+	pkt, err := dec.Decode()
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if pkt.Route != 3 || pkt.Seq != 70000 || pkt.Fields[SkynetFieldUD] != 9 || !bytes.Equal(pkt.Data, content) {
+		t.Fatalf("pkt = %+v, want content % x", pkt, content)
+	}
+	pkt, err = dec.Decode()
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if pkt.Route != 0 || pkt.Seq != 70000 || !bytes.Equal(pkt.Data, content) {
+		t.Fatalf("pkt = %+v", pkt)
+	}
+}
+
+func TestSkynetInvalidPack(t *testing.T) {
+	cfg, _ := Preset(ProtocolSkynet)
+	// 位图声明 3 个非 0 字节但只有 1 个
+	if _, err := DecodeBytes([]byte{0x00, 0x02, 0x07, 0x01}, cfg); !errors.Is(err, ErrInvalidFrame) {
+		t.Fatalf("err = %v, want ErrInvalidFrame", err)
+	}
+}
@@ -0,0 +1,185 @@
+package codec
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 解码结果中与线格式相关的键
+const (
+	wireKey    = "_wire"    // 未映射消息或按消息定义解码失败时, 消息体的线格式结构
+	unknownKey = "_unknown" // 按消息定义解码时, 消息定义中不存在的字段
+)
+
+// maxWireDepth 猜测嵌套消息的最大深度
+const maxWireDepth = 32
+
+// WireField 不依赖消息定义、按 protobuf 线格式解析出的一个字段
+//
+// 线格式不区分有符号/无符号、整数/浮点、字符串/bytes/嵌套消息, 因此 Value 为猜测值:
+//   - varint: 无符号整数, 最高位为 1 时按 int64 解释(负数 int32/int64 的编码); 超过 2^53 时为十进制字符串
+//   - i32/i64: 无符号整数, Alt 为对应的 float/double 解释
+//   - len: 可打印 UTF-8 为字符串(Kind=string); 能完整解析为字段时为嵌套消息(Kind=message, 字段在 Fields);
+//     否则为十六进制(Kind=bytes)
+//   - group: 组内字段在 Fields
+type WireField struct {
+	Number   int32       `json:"number"`
+	WireType string      `json:"wireType"`
+	Kind     string      `json:"kind,omitempty"`
+	Value    any         `json:"value,omitempty"`
+	Alt      any         `json:"alt,omitempty"`
+	Fields   []WireField `json:"fields,omitempty"`
+}
+
+// DecodeWire 不依赖消息定义解析 protobuf 线格式, 用于路由映射错误或服务端消息定义变更时排查
+//
+// 参数：
+//   - data: protobuf 编码的消息体
+//
+// 返回值：
+//   - []WireField: 按出现顺序排列的字段
+//   - error: data 不是合法的线格式时返回错误
+func DecodeWire(data []byte) ([]WireField, error) {
+	return decodeWire(data, 0)
+}
+
+func decodeWire(data []byte, depth int) ([]WireField, error) {
+	var fields []WireField
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, fmt.Errorf("field tag: %w", protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		f := WireField{Number: int32(num)}
+		switch typ {
+		case protowire.VarintType:
+			v, m := protowire.ConsumeVarint(data)
+			if m < 0 {
+				return nil, fmt.Errorf("field %d: %w", num, protowire.ParseError(m))
+			}
+			f.WireType = "varint"
+			if v > math.MaxInt64 {
+				f.Value = wireInt(int64(v))
+			} else {
+				f.Value = wireUint(v)
+			}
+			n = m
+		case protowire.Fixed32Type:
+			v, m := protowire.ConsumeFixed32(data)
+			if m < 0 {
+				return nil, fmt.Errorf("field %d: %w", num, protowire.ParseError(m))
+			}
+			f.WireType = "i32"
+			f.Value = v
+			f.Alt = wireFloat(float64(math.Float32frombits(v)))
+			n = m
+		case protowire.Fixed64Type:
+			v, m := protowire.ConsumeFixed64(data)
+			if m < 0 {
+				return nil, fmt.Errorf("field %d: %w", num, protowire.ParseError(m))
+			}
+			f.WireType = "i64"
+			f.Value = wireUint(v)
+			f.Alt = wireFloat(math.Float64frombits(v))
+			n = m
+		case protowire.BytesType:
+			v, m := protowire.ConsumeBytes(data)
+			if m < 0 {
+				return nil, fmt.Errorf("field %d: %w", num, protowire.ParseError(m))
+			}
+			f.WireType = "len"
+			guessBytes(&f, v, depth)
+			n = m
+		case protowire.StartGroupType:
+			v, m := protowire.ConsumeGroup(num, data)
+			if m < 0 {
+				return nil, fmt.Errorf("field %d: %w", num, protowire.ParseError(m))
+			}
+			f.WireType = "group"
+			if depth < maxWireDepth {
+				if sub, err := decodeWire(v, depth+1); err == nil {
+					f.Fields = sub
+				}
+			}
+			n = m
+		default:
+			return nil, fmt.Errorf("field %d: unexpected wire type %d", num, typ)
+		}
+		data = data[n:]
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// guessBytes 猜测 length-delimited 字段的内容, 优先级为字符串 > 嵌套消息 > bytes
+//
+// 嵌套消息的 tag 通常含有控制字符, 因此可打印文本优先按字符串处理
+func guessBytes(f *WireField, v []byte, depth int) {
+	if len(v) > 0 && isPrintable(v) {
+		f.Kind = "string"
+		f.Value = string(v)
+		return
+	}
+	if len(v) > 0 && depth < maxWireDepth {
+		if sub, err := decodeWire(v, depth+1); err == nil {
+			f.Kind = "message"
+			f.Fields = sub
+			return
+		}
+	}
+	f.Kind = "bytes"
+	f.Value = hex.EncodeToString(v)
+}
+
+// isPrintable 返回 b 是否为不含控制字符(制表、换行除外)的 UTF-8 文本
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// wireUint 超过 2^53 的整数以十进制字符串表示, 与 ProtoJSONOptions 的 64 位整数一致
+func wireUint(v uint64) any {
+	if v > maxExactFloat {
+		return strconv.FormatUint(v, 10)
+	}
+	return v
+}
+
+func wireInt(v int64) any {
+	if v < -maxExactFloat || v > maxExactFloat {
+		return strconv.FormatInt(v, 10)
+	}
+	return v
+}
+
+// wireFloat NaN 和 Inf 无法表示为 JSON 数字, 以字符串表示
+func wireFloat(f float64) any {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return f
+}
+
+// wireValue 返回 data 的线格式结构作为解码结果中 _wire 的值, data 不是合法线格式时返回 false
+func wireValue(data []byte) ([]WireField, bool) {
+	if len(data) == 0 {
+		return nil, false
+	}
+	fields, err := DecodeWire(data)
+	return fields, err == nil
+}
@@ -0,0 +1,67 @@
+package codec
+
+import "testing"
+
+func TestDecodeWire(t *testing.T) {
+	data := []byte{
+		0x08, 0x96, 0x01, // 1: varint 150
+		0x12, 0x02, 'h', 'i', // 2: "hi"
+		0x1a, 0x02, 0x08, 0x01, // 3: {1: 1}
+		0x25, 0x00, 0x00, 0xc0, 0x3f, // 4: i32 1.5f
+		0x28, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, // 5: varint -1
+		0x32, 0x02, 0x00, 0xff, // 6: bytes
+	}
+	fields, err := DecodeWire(data)
+	if err != nil {
+		t.Fatalf("DecodeWire error: %v", err)
+	}
+	if len(fields) != 6 {
+		t.Fatalf("fields = %+v", fields)
+	}
+	if f := fields[0]; f.Number != 1 || f.WireType != "varint" || f.Value != uint64(150) {
+		t.Errorf("field 1 = %+v", f)
+	}
+	if f := fields[1]; f.Kind != "string" || f.Value != "hi" {
+		t.Errorf("field 2 = %+v", f)
+	}
+	if f := fields[2]; f.Kind != "message" || len(f.Fields) != 1 || f.Fields[0].Value != uint64(1) {
+		t.Errorf("field 3 = %+v", f)
+	}
+	if f := fields[3]; f.WireType != "i32" || f.Alt != 1.5 {
+		t.Errorf("field 4 = %+v", f)
+	}
+	if f := fields[4]; f.Value != int64(-1) {
+		t.Errorf("field 5 = %+v", f)
+	}
+	if f := fields[5]; f.Kind != "bytes" || f.Value != "00ff" {
+		t.Errorf("field 6 = %+v", f)
+	}
+
+	if _, err := DecodeWire([]byte{0x12, 0x05, 'h'}); err == nil {
+		t.Fatal("expected error for truncated field")
+	}
+}
+
+func TestDynamicDecodeUnknownFields(t *testing.T) {
+	proto := `syntax = "proto3";
+message TestMsg {
+  int32 id = 1;
+}`
+	md := compileProto(t, proto, "TestMsg")
+
+	// 字段 2 不在消息定义中
+	result, err := DynamicDecode([]byte{0x08, 0x07, 0x12, 0x02, 'o', 'k'}, md)
+	if err != nil {
+		t.Fatalf("DynamicDecode error: %v", err)
+	}
+	unknown, _ := result["_unknown"].([]WireField)
+	if result["id"] != int32(7) || len(unknown) != 1 || unknown[0].Number != 2 || unknown[0].Value != "ok" {
+		t.Fatalf("result = %#v", result)
+	}
+
+	// 未映射消息时附带线格式结构
+	result, _ = DynamicDecode([]byte{0x08, 0x07}, nil)
+	if wire, _ := result["_wire"].([]WireField); len(wire) != 1 || result["_hex"] != "0807" {
+		t.Fatalf("result = %#v", result)
+	}
+}
@@ -4,24 +4,28 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/flow-packet/server/internal/codec"
 )
 
 // SeqContext seq 分配与响应匹配
 type SeqContext struct {
 	mu      sync.Mutex
-	counter uint32
-	pending map[uint32]chan []byte
+	counter uint64
+	pending map[uint64]chan []byte
+	headers map[chan []byte]map[string]uint64 // 已匹配响应的帧头字段值
 }
 
 // NewSeqContext 创建 seq 上下文
 func NewSeqContext() *SeqContext {
 	return &SeqContext{
-		pending: make(map[uint32]chan []byte),
+		pending: make(map[uint64]chan []byte),
+		headers: make(map[chan []byte]map[string]uint64),
 	}
 }
 
 // NextSeq 分配下一个 seq 并注册等待通道
-func (c *SeqContext) NextSeq() (uint32, chan []byte) {
+func (c *SeqContext) NextSeq() (uint64, chan []byte) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -33,12 +37,9 @@ func (c *SeqContext) NextSeq() (uint32, chan []byte) {
 }
 
 // Resolve 收到响应后, 通过 seq 匹配到等待方
-func (c *SeqContext) Resolve(seq uint32, data []byte) bool {
+func (c *SeqContext) Resolve(seq uint64, data []byte) bool {
 	c.mu.Lock()
-	ch, ok := c.pending[seq]
-	if ok {
-		delete(c.pending, seq)
-	}
+	ch, ok := c.take(seq)
 	c.mu.Unlock()
 
 	if !ok {
@@ -53,7 +54,52 @@ func (c *SeqContext) Resolve(seq uint32, data []byte) bool {
 // 适用于服务端不回传 seq 的协议(响应 seq=0)
 func (c *SeqContext) ResolveFirst(data []byte) bool {
 	c.mu.Lock()
-	var minSeq uint32
+	ch, ok := c.takeFirst()
+	c.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	ch <- data
+	return true
+}
+
+// ResolvePacket 以响应包匹配等待方, 先按 seq 精确匹配, 失败时回退到最早的等待请求
+//
+// 响应包的自定义帧头字段值会被保留, 等待方可通过 ResponseHeader 读取
+func (c *SeqContext) ResolvePacket(pkt *codec.Packet) bool {
+	c.mu.Lock()
+	ch, ok := c.take(pkt.Seq)
+	if !ok {
+		// 服务端不回传 seq(seq=0) 时匹配最早的等待请求
+		ch, ok = c.takeFirst()
+	}
+	if ok && len(pkt.Fields) > 0 {
+		c.headers[ch] = pkt.Fields
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	ch <- pkt.Data
+	return true
+}
+
+// take 取出 seq 对应的等待通道, 调用方需持有 mu
+func (c *SeqContext) take(seq uint64) (chan []byte, bool) {
+	ch, ok := c.pending[seq]
+	if ok {
+		delete(c.pending, seq)
+	}
+	return ch, ok
+}
+
+// takeFirst 取出最早的等待通道, 调用方需持有 mu
+func (c *SeqContext) takeFirst() (chan []byte, bool) {
+	var minSeq uint64
 	var minCh chan []byte
 	for seq, ch := range c.pending {
 		if minCh == nil || seq < minSeq {
@@ -61,29 +107,47 @@ func (c *SeqContext) ResolveFirst(data []byte) bool {
 			minCh = ch
 		}
 	}
-	if minCh != nil {
-		delete(c.pending, minSeq)
-	}
-	c.mu.Unlock()
-
 	if minCh == nil {
-		return false
+		return nil, false
 	}
+	delete(c.pending, minSeq)
+	return minCh, true
+}
 
-	minCh <- data
-	return true
+// ResponseHeader 返回通道已收到响应的帧头字段值, 读取后释放
+func (c *SeqContext) ResponseHeader(ch chan []byte) map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	header := c.headers[ch]
+	delete(c.headers, ch)
+	return header
 }
 
 // WaitResponse 等待指定 seq 的响应, 超时返回错误
+//
+// 超时后注销该等待方并释放已保存的帧头, 迟到的响应不再匹配到该通道
 func (c *SeqContext) WaitResponse(ch chan []byte, timeout time.Duration) ([]byte, error) {
 	select {
 	case data := <-ch:
 		return data, nil
 	case <-time.After(timeout):
+		c.release(ch)
 		return nil, fmt.Errorf("response timeout")
 	}
 }
 
+// release 注销等待通道并删除其帧头
+func (c *SeqContext) release(ch chan []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for seq, pending := range c.pending {
+		if pending == ch {
+			delete(c.pending, seq)
+		}
+	}
+	delete(c.headers, ch)
+}
+
 // Reset 重置上下文
 func (c *SeqContext) Reset() {
 	c.mu.Lock()
@@ -93,4 +157,5 @@ func (c *SeqContext) Reset() {
 		close(ch)
 		delete(c.pending, seq)
 	}
+	clear(c.headers)
 }
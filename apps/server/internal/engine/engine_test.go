@@ -2,6 +2,8 @@ package engine
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
@@ -122,6 +124,28 @@ func TestSeqContextWaitTimeout(t *testing.T) {
 	}
 }
 
+func TestSeqContextWaitTimeoutReleasesWaiter(t *testing.T) {
+	ctx := NewSeqContext()
+	seq, ch := ctx.NextSeq()
+
+	// 已匹配但未被读取的响应帧头在超时后释放
+	ctx.mu.Lock()
+	ctx.headers[ch] = map[string]uint64{"serverId": 1}
+	ctx.mu.Unlock()
+
+	if _, err := ctx.WaitResponse(ch, 10*time.Millisecond); err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if ctx.Resolve(seq, []byte("late")) {
+		t.Fatal("late response should not match a timed-out waiter")
+	}
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if len(ctx.headers) != 0 || len(ctx.pending) != 0 {
+		t.Fatalf("headers = %v, pending = %v, want both empty", ctx.headers, ctx.pending)
+	}
+}
+
 func TestSeqContextWaitSuccess(t *testing.T) {
 	ctx := NewSeqContext()
 	seq, ch := ctx.NextSeq()
@@ -145,19 +169,55 @@ func TestSeqContextReset(t *testing.T) {
 	ctx.NextSeq()
 	ctx.NextSeq()
 
+	ctx.headers[make(chan []byte)] = map[string]uint64{"serverId": 1}
+
 	ctx.Reset()
 
 	seq, _ := ctx.NextSeq()
 	if seq != 1 {
 		t.Fatalf("seq after reset = %d, want 1", seq)
 	}
+	if len(ctx.headers) != 0 {
+		t.Fatalf("headers after reset = %v, want empty", ctx.headers)
+	}
+}
+
+func TestSeqContextResolvePacket(t *testing.T) {
+	ctx := NewSeqContext()
+	_, ch1 := ctx.NextSeq()
+	seq2, ch2 := ctx.NextSeq()
+
+	// 精确匹配 seq 并保留帧头字段
+	if !ctx.ResolvePacket(&codec.Packet{Seq: seq2, Data: []byte("resp2"), Fields: map[string]uint64{"serverId": 3}}) {
+		t.Fatal("ResolvePacket seq 2 returned false")
+	}
+	if data := <-ch2; string(data) != "resp2" {
+		t.Fatalf("ch2 data = %q, want resp2", data)
+	}
+	if header := ctx.ResponseHeader(ch2); header["serverId"] != 3 {
+		t.Fatalf("header = %v, want serverId=3", header)
+	}
+	if header := ctx.ResponseHeader(ch2); header != nil {
+		t.Fatalf("header should be released after read, got %v", header)
+	}
+
+	// seq=0 回退到最早的等待请求
+	if !ctx.ResolvePacket(&codec.Packet{Data: []byte("resp1")}) {
+		t.Fatal("ResolvePacket fallback returned false")
+	}
+	if data := <-ch1; string(data) != "resp1" {
+		t.Fatalf("ch1 data = %q, want resp1", data)
+	}
+	if ctx.ResolvePacket(&codec.Packet{Data: []byte("extra")}) {
+		t.Fatal("ResolvePacket with no pending requests should return false")
+	}
 }
 
 func TestRunnerStopCancelsExecution(t *testing.T) {
 	runner := NewRunner(defaultPacketConfig())
 
 	// Mock send that blocks
-	runner.SetSendFunc(func(data []byte) error {
+	runner.SetSendFunc(func(pkt *codec.Packet) error {
 		time.Sleep(5 * time.Second)
 		return nil
 	})
@@ -186,6 +246,34 @@ func TestRunnerStopCancelsExecution(t *testing.T) {
 	}
 }
 
+func TestRunnerJSONBody(t *testing.T) {
+	cfg := codec.PacketConfig{Pomelo: &codec.PomeloConfig{}, BodyFormat: codec.BodyJSON}
+	runner := NewRunner(cfg)
+	runner.SetSendFunc(func(req *codec.Packet) error {
+		if string(req.Data) != `{"name":"bob"}` {
+			return fmt.Errorf("request body = %s", req.Data)
+		}
+		go runner.SeqCtx().ResolvePacket(&codec.Packet{Seq: req.Seq, Data: []byte(`{"code":200}`)})
+		return nil
+	})
+
+	// JSON 消息体不需要 proto 定义
+	nodes := []FlowNode{{ID: "a", StringRoute: "connector.entry", Fields: map[string]any{"name": "bob"}}}
+	var result NodeResult
+	if err := runner.Execute(context.Background(), nodes, nil, func(r NodeResult) { result = r }); err != nil {
+		t.Fatalf("Execute error: %v (%s)", err, result.Error)
+	}
+	if result.Response["code"] != json.Number("200") {
+		t.Fatalf("response = %v", result.Response)
+	}
+
+	// 路由单独指定 proto 时仍要求消息定义
+	runner.SetBodyFormatResolver(func(uint64, string) string { return codec.BodyProto })
+	if err := runner.Execute(context.Background(), nodes, nil, nil); err == nil {
+		t.Fatal("expected error for proto route without message")
+	}
+}
+
 func defaultPacketConfig() codec.PacketConfig {
 	return codec.PacketConfig{RouteBytes: 2, SeqBytes: 2}
 }
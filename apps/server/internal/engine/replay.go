@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/flow-packet/server/internal/codec"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// RecordedMessage 录制会话中的一条已解码消息
+type RecordedMessage struct {
+	Time   time.Time
+	Sent   bool // true 为客户端发出, false 为服务端下发
+	Packet *codec.Packet
+}
+
+// RequestResolver 根据路由获取请求消息描述符
+type RequestResolver func(route uint64, stringRoute string) protoreflect.MessageDescriptor
+
+// BuildReplayFlow 将录制会话中客户端发出的消息还原为串行流程
+//
+// 节点按发送顺序串联, 每个节点的 Delay 为上一次交互(上一个请求的首个响应,
+// 无响应时为上一个请求本身)到本次发送的间隔, 即录制时的操作间隔;
+// 回放时响应耗时由服务端重新决定, 因此不计入 Delay
+//
+// 参数：
+//   - msgs: 按时间排序的消息, 心跳和控制包应由调用方预先过滤
+//   - resolve: 请求消息解析器, 用于将消息体解码为节点字段
+//   - format: 路由的消息体格式, 应与执行流程的 Runner 一致(见 BodyFormatResolver.Or)
+//   - opts: proto 消息体字段值的转换选项, 应与执行流程的 Runner 一致
+//   - maxDelay: 单个节点等待上限, 0 表示不限制
+//
+// 返回值：
+//   - []FlowNode, []FlowEdge: 可直接交给 Runner.Execute 的流程
+//   - error: 存在未映射请求消息的 proto 路由或消息体无法解码时返回错误
+func BuildReplayFlow(msgs []RecordedMessage, resolve RequestResolver, format BodyFormatResolver, opts codec.ProtoJSONOptions, maxDelay time.Duration) ([]FlowNode, []FlowEdge, error) {
+	var nodes []FlowNode
+	var edges []FlowEdge
+
+	var lastEvent time.Time
+	awaitingResponse := false
+
+	for _, m := range msgs {
+		if m.Packet == nil || m.Packet.Heartbeat {
+			continue
+		}
+
+		if !m.Sent {
+			// 上一个请求的首个响应作为操作间隔的起点
+			if awaitingResponse {
+				lastEvent = m.Time
+				awaitingResponse = false
+			}
+			continue
+		}
+
+		pkt := m.Packet
+		bodyFormat := format.Or("")(pkt.Route, pkt.StringRoute)
+		md := resolve(pkt.Route, pkt.StringRoute)
+		if md == nil && codec.BodyRequiresDescriptor(bodyFormat) {
+			return nil, nil, fmt.Errorf("no request message mapped for route %s", routeLabel(pkt.Route, pkt.StringRoute))
+		}
+
+		fields, err := codec.DecodeBody(bodyFormat, pkt.Data, md, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decode route %s: %w", routeLabel(pkt.Route, pkt.StringRoute), err)
+		}
+		var messageName string
+		if md != nil {
+			messageName = string(md.FullName())
+		}
+
+		var delay time.Duration
+		if !lastEvent.IsZero() && m.Time.After(lastEvent) {
+			delay = m.Time.Sub(lastEvent)
+		}
+		if maxDelay > 0 && delay > maxDelay {
+			delay = maxDelay
+		}
+
+		node := FlowNode{
+			ID:          fmt.Sprintf("replay_%d", len(nodes)+1),
+			MessageName: messageName,
+			Route:       pkt.Route,
+			StringRoute: pkt.StringRoute,
+			Fields:      fields,
+			Delay:       delay.Milliseconds(),
+			Header:      pkt.Fields,
+		}
+		if len(nodes) > 0 {
+			edges = append(edges, FlowEdge{Source: nodes[len(nodes)-1].ID, Target: node.ID})
+		}
+		nodes = append(nodes, node)
+
+		lastEvent = m.Time
+		awaitingResponse = true
+	}
+
+	if len(nodes) == 0 {
+		return nil, nil, fmt.Errorf("no client messages to replay")
+	}
+	return nodes, edges, nil
+}
+
+// routeLabel 返回路由的可读标识, 字符串路由优先
+func routeLabel(route uint64, stringRoute string) string {
+	if stringRoute != "" {
+		return stringRoute
+	}
+	return fmt.Sprintf("%d", route)
+}
@@ -0,0 +1,210 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/flow-packet/server/internal/codec"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// compileLoginProto 编译测试用的登录请求消息
+func compileLoginProto(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+	resolver := &protocompile.SourceResolver{
+		Accessor: protocompile.SourceAccessorFromMap(map[string]string{
+			"login.proto": `syntax = "proto3";
+package game;
+message LoginReq { string account = 1; int32 zone = 2; }`,
+		}),
+	}
+	compiler := &protocompile.Compiler{Resolver: resolver}
+	compiled, err := compiler.Compile(context.Background(), "login.proto")
+	if err != nil {
+		t.Fatalf("compile proto: %v", err)
+	}
+	return compiled[0].Messages().ByName("LoginReq")
+}
+
+func TestBuildReplayFlow(t *testing.T) {
+	md := compileLoginProto(t)
+	body, err := codec.DynamicEncode(md, map[string]any{"account": "qa", "zone": 3})
+	if err != nil {
+		t.Fatalf("DynamicEncode error: %v", err)
+	}
+
+	base := time.Unix(1700000000, 0)
+	msgs := []RecordedMessage{
+		{Time: base, Sent: true, Packet: &codec.Packet{Route: 1001, Seq: 1, Data: body}},
+		{Time: base.Add(40 * time.Millisecond), Sent: false, Packet: &codec.Packet{Route: 1001, Seq: 1}},
+		{Time: base.Add(50 * time.Millisecond), Sent: true, Packet: &codec.Packet{Heartbeat: true}},
+		{Time: base.Add(540 * time.Millisecond), Sent: true, Packet: &codec.Packet{Route: 1001, Seq: 2, Data: body}},
+		// 无响应的请求: 下一个节点的间隔从该请求发送时刻算起
+		{Time: base.Add(2540 * time.Millisecond), Sent: true, Packet: &codec.Packet{Route: 1001, Seq: 3, Data: body}},
+	}
+
+	resolve := func(route uint64, stringRoute string) protoreflect.MessageDescriptor {
+		if route == 1001 {
+			return md
+		}
+		return nil
+	}
+
+	nodes, edges, err := BuildReplayFlow(msgs, resolve, nil, codec.ProtoJSONOptions{}, 0)
+	if err != nil {
+		t.Fatalf("BuildReplayFlow error: %v", err)
+	}
+
+	if len(nodes) != 3 || len(edges) != 2 {
+		t.Fatalf("nodes/edges = %d/%d, want 3/2", len(nodes), len(edges))
+	}
+	if nodes[0].MessageName != "game.LoginReq" || nodes[0].Route != 1001 {
+		t.Fatalf("node[0] = %+v", nodes[0])
+	}
+	if nodes[0].Fields["account"] != "qa" {
+		t.Fatalf("node[0] fields = %v, want account=qa", nodes[0].Fields)
+	}
+
+	wantDelays := []int64{0, 500, 2000}
+	for i, want := range wantDelays {
+		if nodes[i].Delay != want {
+			t.Fatalf("node[%d].Delay = %d, want %d", i, nodes[i].Delay, want)
+		}
+	}
+
+	order, err := ResolveOrder(nodes, edges)
+	if err != nil {
+		t.Fatalf("ResolveOrder error: %v", err)
+	}
+	if order[0] != nodes[0].ID || order[2] != nodes[2].ID {
+		t.Fatalf("order = %v", order)
+	}
+
+	capped, _, err := BuildReplayFlow(msgs, resolve, nil, codec.ProtoJSONOptions{}, time.Second)
+	if err != nil {
+		t.Fatalf("BuildReplayFlow error: %v", err)
+	}
+	if capped[2].Delay != 1000 {
+		t.Fatalf("capped delay = %d, want 1000", capped[2].Delay)
+	}
+}
+
+func TestBuildReplayFlowUnmappedRoute(t *testing.T) {
+	msgs := []RecordedMessage{
+		{Time: time.Now(), Sent: true, Packet: &codec.Packet{Route: 42}},
+	}
+	_, _, err := BuildReplayFlow(msgs, func(uint64, string) protoreflect.MessageDescriptor { return nil }, nil, codec.ProtoJSONOptions{}, 0)
+	if err == nil {
+		t.Fatal("expected error for unmapped route")
+	}
+}
+
+func TestBuildReplayFlowJSONRoute(t *testing.T) {
+	msgs := []RecordedMessage{
+		{Time: time.Now(), Sent: true, Packet: &codec.Packet{StringRoute: "connector.entry", Seq: 1, Data: []byte(`{"name":"bob"}`)}},
+	}
+	noMessage := func(uint64, string) protoreflect.MessageDescriptor { return nil }
+
+	// 连接默认 JSON 消息体时无需 proto 定义
+	format := BodyFormatResolver(nil).Or(codec.BodyJSON)
+	nodes, _, err := BuildReplayFlow(msgs, noMessage, format, codec.ProtoJSONOptions{}, 0)
+	if err != nil {
+		t.Fatalf("BuildReplayFlow error: %v", err)
+	}
+	if nodes[0].MessageName != "" || nodes[0].Fields["name"] != "bob" {
+		t.Fatalf("node = %+v", nodes[0])
+	}
+
+	// 路由单独指定 proto 时优先于连接默认格式
+	protoRoute := BodyFormatResolver(func(uint64, string) string { return codec.BodyProto }).Or(codec.BodyJSON)
+	if _, _, err := BuildReplayFlow(msgs, noMessage, protoRoute, codec.ProtoJSONOptions{}, 0); err == nil {
+		t.Fatal("expected error for proto route without message")
+	}
+}
+
+func TestBuildReplayFlowHexRoute(t *testing.T) {
+	msgs := []RecordedMessage{
+		{Time: time.Now(), Sent: true, Packet: &codec.Packet{Route: 3001, Seq: 1, Data: []byte{0xde, 0xad}}},
+	}
+	noMessage := func(uint64, string) protoreflect.MessageDescriptor { return nil }
+	format := BodyFormatResolver(func(route uint64, _ string) string {
+		if route == 3001 {
+			return codec.BodyHex
+		}
+		return ""
+	}).Or(codec.BodyProto)
+
+	nodes, _, err := BuildReplayFlow(msgs, noMessage, format, codec.ProtoJSONOptions{}, 0)
+	if err != nil {
+		t.Fatalf("BuildReplayFlow error: %v", err)
+	}
+	// 回放时按同一格式重新编码, 得到原始消息体
+	body, err := codec.EncodeBody(codec.BodyHex, nil, nodes[0].Fields, codec.ProtoJSONOptions{})
+	if err != nil {
+		t.Fatalf("EncodeBody error: %v", err)
+	}
+	if string(body) != "\xde\xad" {
+		t.Fatalf("re-encoded body = %x, want dead", body)
+	}
+}
+
+func TestRunnerHeaderFields(t *testing.T) {
+	md := compileLoginProto(t)
+	fdCfg, err := codec.NewFieldDrivenConfig([]codec.FieldDef{
+		{Name: "len", Bytes: 2},
+		{Name: "cmd", Bytes: 2, IsRoute: true},
+		{Name: "seq", Bytes: 2, IsSeq: true},
+		{Name: "serverId", Bytes: 2},
+	})
+	if err != nil {
+		t.Fatalf("NewFieldDrivenConfig error: %v", err)
+	}
+	cfg := codec.PacketConfig{FieldDriven: fdCfg}
+
+	runner := NewRunner(cfg)
+	runner.SetResolver(func(string) protoreflect.MessageDescriptor { return md })
+	runner.SetSendFunc(func(req *codec.Packet) error {
+		if req.Fields["serverId"] != 7 {
+			return fmt.Errorf("request serverId = %d, want 7", req.Fields["serverId"])
+		}
+		// 按 serverId 路由的网关在响应中回填实际处理的服务器
+		resp, _ := codec.Encode(&codec.Packet{Route: req.Route, Seq: req.Seq, Fields: map[string]uint64{"serverId": 8}}, cfg)
+		decoded, _ := codec.DecodeBytes(resp, cfg)
+		go runner.SeqCtx().ResolvePacket(decoded)
+		return nil
+	})
+
+	nodes := []FlowNode{{ID: "a", MessageName: "game.LoginReq", Route: 1, Header: map[string]uint64{"serverId": 7}}}
+	var result NodeResult
+	if err := runner.Execute(context.Background(), nodes, nil, func(r NodeResult) { result = r }); err != nil {
+		t.Fatalf("Execute error: %v (%s)", err, result.Error)
+	}
+	if result.ResponseHeader["serverId"] != 8 {
+		t.Fatalf("response header = %v, want serverId=8", result.ResponseHeader)
+	}
+}
+
+func TestRunnerStopDuringDelay(t *testing.T) {
+	runner := NewRunner(defaultPacketConfig())
+	nodes := []FlowNode{{ID: "a", MessageName: "Test", Route: 1, Delay: 10000}}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- runner.Execute(context.Background(), nodes, nil, nil)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	runner.Stop()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected cancellation error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("delay did not honor Stop")
+	}
+}
@@ -15,9 +15,17 @@ import (
 type FlowNode struct {
 	ID          string         `json:"id"`
 	MessageName string         `json:"messageName"`
-	Route       uint32         `json:"route"`
+	Route       uint64         `json:"route"`
 	StringRoute string         `json:"stringRoute"`
 	Fields      map[string]any `json:"fields"`
+	Delay       int64          `json:"delay,omitempty"` // 发送前等待的毫秒数
+	// Header 字段驱动模式下自定义帧头字段(如 uid、serverId、flags)的值, 键为字段名
+	Header map[string]uint64 `json:"header,omitempty"`
+}
+
+// statsKey 返回节点在延迟统计中的路由标识, 字符串路由优先
+func (n *FlowNode) statsKey() string {
+	return routeLabel(n.Route, n.StringRoute)
 }
 
 // FlowEdge 流程边
@@ -36,6 +44,9 @@ type NodeResult struct {
 	Response    map[string]any `json:"response"`
 	Error       string         `json:"error,omitempty"`
 	Duration    int64          `json:"duration"` // 毫秒
+	Latency     int64          `json:"latency"`  // 发送到收到响应的耗时, 微秒
+	// ResponseHeader 响应包中自定义帧头字段的值
+	ResponseHeader map[string]uint64 `json:"responseHeader,omitempty"`
 }
 
 // NodeCallback 节点完成回调
@@ -45,11 +56,26 @@ type NodeCallback func(result NodeResult)
 type MessageResolver func(messageName string) protoreflect.MessageDescriptor
 
 // ResponseResolver 根据 route 获取响应消息描述符
-type ResponseResolver func(route uint32) protoreflect.MessageDescriptor
+type ResponseResolver func(route uint64) protoreflect.MessageDescriptor
 
 // StringRouteResponseResolver 根据字符串路由获取响应消息描述符
 type StringRouteResponseResolver func(route string) protoreflect.MessageDescriptor
 
+// BodyFormatResolver 返回路由单独设置的消息体格式, 未设置时返回空字符串
+type BodyFormatResolver func(route uint64, stringRoute string) string
+
+// Or 返回路由未单独设置格式时使用 def(连接的 PacketConfig.BodyFormat)的解析器, f 可为 nil
+func (f BodyFormatResolver) Or(def string) BodyFormatResolver {
+	return func(route uint64, stringRoute string) string {
+		if f != nil {
+			if format := f(route, stringRoute); format != "" {
+				return format
+			}
+		}
+		return def
+	}
+}
+
 // Runner 串行流程执行器
 type Runner struct {
 	mu                     sync.Mutex
@@ -58,10 +84,12 @@ type Runner struct {
 	seqCtx                 *SeqContext
 	packetCfg              codec.PacketConfig
 	timeout                time.Duration
-	sendFn                 func(data []byte) error
+	sendFn                 func(pkt *codec.Packet) error
 	resolver               MessageResolver
 	responseResolver       ResponseResolver
 	stringResponseResolver StringRouteResponseResolver
+	bodyFormatResolver     BodyFormatResolver
+	stats                  *RouteStats
 }
 
 // NewRunner 创建执行器
@@ -70,6 +98,7 @@ func NewRunner(packetCfg codec.PacketConfig) *Runner {
 		seqCtx:    NewSeqContext(),
 		packetCfg: packetCfg,
 		timeout:   5 * time.Second,
+		stats:     NewRouteStats(),
 	}
 }
 
@@ -78,8 +107,8 @@ func (r *Runner) SetPacketConfig(cfg codec.PacketConfig) {
 	r.packetCfg = cfg
 }
 
-// SetSendFunc 设置发送函数
-func (r *Runner) SetSendFunc(fn func(data []byte) error) {
+// SetSendFunc 设置发送函数, 数据包由连接按当前协议帧配置编码
+func (r *Runner) SetSendFunc(fn func(pkt *codec.Packet) error) {
 	r.sendFn = fn
 }
 
@@ -98,6 +127,25 @@ func (r *Runner) SetStringRouteResponseResolver(resolver StringRouteResponseReso
 	r.stringResponseResolver = resolver
 }
 
+// SetBodyFormatResolver 设置路由消息体格式解析器
+func (r *Runner) SetBodyFormatResolver(resolver BodyFormatResolver) {
+	r.bodyFormatResolver = resolver
+}
+
+// protoJSON 返回 proto 字段值的转换选项, 未指定 Any 类型解析器时使用消息解析器
+func (r *Runner) protoJSON() codec.ProtoJSONOptions {
+	opts := r.packetCfg.ProtoJSON
+	if opts.Resolver == nil {
+		opts.Resolver = r.resolver
+	}
+	return opts
+}
+
+// bodyFormat 返回节点使用的消息体格式: 路由设置优先, 其次为连接的 PacketConfig.BodyFormat
+func (r *Runner) bodyFormat(node *FlowNode) string {
+	return r.bodyFormatResolver.Or(r.packetCfg.BodyFormat)(node.Route, node.StringRoute)
+}
+
 // SetTimeout 设置响应超时
 func (r *Runner) SetTimeout(d time.Duration) {
 	r.timeout = d
@@ -108,6 +156,11 @@ func (r *Runner) SeqCtx() *SeqContext {
 	return r.seqCtx
 }
 
+// Stats 获取路由延迟统计
+func (r *Runner) Stats() *RouteStats {
+	return r.stats
+}
+
 // Running 返回是否正在执行
 func (r *Runner) Running() bool {
 	r.mu.Lock()
@@ -214,6 +267,14 @@ func (r *Runner) Execute(ctx context.Context, nodes []FlowNode, edges []FlowEdge
 		}
 
 		node := nodeMap[nodeID]
+		if node.Delay > 0 {
+			select {
+			case <-execCtx.Done():
+				return execCtx.Err()
+			case <-time.After(time.Duration(node.Delay) * time.Millisecond):
+			}
+		}
+
 		result := r.executeNode(execCtx, node)
 		if onNode != nil {
 			onNode(result)
@@ -246,22 +307,24 @@ func (r *Runner) executeNode(ctx context.Context, node *FlowNode) NodeResult {
 		Request:    node.Fields,
 	}
 
-	// 解析 message descriptor
-	if r.resolver == nil {
-		result.Error = "message resolver not configured"
-		result.Duration = time.Since(start).Milliseconds()
-		return result
+	// 解析 message descriptor, 仅 proto 消息体要求 proto 定义
+	format := r.bodyFormat(node)
+	var reqMd protoreflect.MessageDescriptor
+	if r.resolver != nil && node.MessageName != "" {
+		reqMd = r.resolver(node.MessageName)
 	}
-
-	reqMd := r.resolver(node.MessageName)
-	if reqMd == nil {
-		result.Error = fmt.Sprintf("message %q not found", node.MessageName)
+	if reqMd == nil && codec.BodyRequiresDescriptor(format) {
+		if r.resolver == nil {
+			result.Error = "message resolver not configured"
+		} else {
+			result.Error = fmt.Sprintf("message %q not found", node.MessageName)
+		}
 		result.Duration = time.Since(start).Milliseconds()
 		return result
 	}
 
-	// 动态编码
-	protoData, err := codec.DynamicEncode(reqMd, node.Fields)
+	// 按消息体格式编码
+	body, err := codec.EncodeBody(format, reqMd, node.Fields, r.protoJSON())
 	if err != nil {
 		result.Error = fmt.Sprintf("encode: %v", err)
 		result.Duration = time.Since(start).Milliseconds()
@@ -275,15 +338,9 @@ func (r *Runner) executeNode(ctx context.Context, node *FlowNode) NodeResult {
 	pkt := &codec.Packet{
 		Route:       node.Route,
 		Seq:         seq,
-		Data:        protoData,
+		Data:        body,
 		StringRoute: node.StringRoute,
-	}
-
-	frame, err := codec.Encode(pkt, r.packetCfg)
-	if err != nil {
-		result.Error = fmt.Sprintf("frame encode: %v", err)
-		result.Duration = time.Since(start).Milliseconds()
-		return result
+		Fields:      node.Header,
 	}
 
 	// 发送
@@ -293,21 +350,27 @@ func (r *Runner) executeNode(ctx context.Context, node *FlowNode) NodeResult {
 		return result
 	}
 
-	if err := r.sendFn(frame); err != nil {
+	if err := r.sendFn(pkt); err != nil {
 		result.Error = fmt.Sprintf("send: %v", err)
 		result.Duration = time.Since(start).Milliseconds()
 		return result
 	}
 
 	// 等待响应
+	sent := time.Now()
 	respData, err := r.seqCtx.WaitResponse(respCh, r.timeout)
 	if err != nil {
+		r.stats.RecordError(node.statsKey())
 		result.Error = fmt.Sprintf("wait response: %v", err)
 		result.Duration = time.Since(start).Milliseconds()
 		return result
 	}
+	latency := time.Since(sent)
+	result.Latency = latency.Microseconds()
+	result.ResponseHeader = r.seqCtx.ResponseHeader(respCh)
+	r.stats.Record(node.statsKey(), latency)
 
-	// 解码响应: 有 responseResolver 时尝试结构化解码, 否则退化为 hex
+	// 解码响应: 非 proto 消息体直接按格式解析; proto 有 responseResolver 时尝试结构化解码, 否则退化为 hex
 	var respMd protoreflect.MessageDescriptor
 	if node.StringRoute != "" && r.stringResponseResolver != nil {
 		respMd = r.stringResponseResolver(node.StringRoute)
@@ -318,9 +381,13 @@ func (r *Runner) executeNode(ctx context.Context, node *FlowNode) NodeResult {
 		result.ResponseMsg = string(respMd.FullName())
 	}
 
-	respFrame, err := codec.DynamicDecode(respData, respMd)
+	respFrame, err := codec.DecodeBody(format, respData, respMd, r.protoJSON())
 	if err != nil {
 		result.Error = fmt.Sprintf("decode response: %v", err)
+		// 响应消息定义与数据不符时, 按未映射消息返回十六进制和线格式结构
+		if codec.BodyRequiresDescriptor(format) {
+			result.Response, _ = codec.DynamicDecode(respData, nil)
+		}
 		result.Duration = time.Since(start).Milliseconds()
 		return result
 	}
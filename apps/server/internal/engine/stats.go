@@ -0,0 +1,216 @@
+package engine
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 直方图桶参数: 每个 2 的幂区间划分为 64 个子桶, 相对误差不超过 1/64
+const (
+	histSubBucketBits  = 7
+	histSubBucketCount = 1 << histSubBucketBits
+	histSubBucketHalf  = histSubBucketCount / 2
+)
+
+// Histogram HDR 风格的对数线性直方图
+//
+// 小于 128 的值精确记录, 更大的值按 2 的幂分段, 每段 64 个子桶,
+// 内存占用与记录次数无关
+type Histogram struct {
+	counts []uint64
+	total  uint64
+	sum    int64
+	min    int64
+	max    int64
+}
+
+// NewHistogram 创建直方图
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// histBucketIndex 计算值所在的桶索引
+func histBucketIndex(v int64) int {
+	if v < histSubBucketCount {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - histSubBucketBits
+	return histSubBucketCount + (shift-1)*histSubBucketHalf + int(v>>uint(shift)) - histSubBucketHalf
+}
+
+// histBucketUpper 返回桶内可表示的最大值
+func histBucketUpper(idx int) int64 {
+	if idx < histSubBucketCount {
+		return int64(idx)
+	}
+	k := idx - histSubBucketCount
+	shift := uint(k/histSubBucketHalf + 1)
+	sub := int64(k%histSubBucketHalf + histSubBucketHalf)
+	return (sub+1)<<shift - 1
+}
+
+// Record 记录一个非负值, 负值按 0 处理
+func (h *Histogram) Record(v int64) {
+	if v < 0 {
+		v = 0
+	}
+	idx := histBucketIndex(v)
+	if idx >= len(h.counts) {
+		grown := make([]uint64, idx+1)
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+	h.counts[idx]++
+
+	if h.total == 0 || v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	h.total++
+	h.sum += v
+}
+
+// Count 返回记录总次数
+func (h *Histogram) Count() uint64 {
+	return h.total
+}
+
+// Min 返回最小值
+func (h *Histogram) Min() int64 {
+	return h.min
+}
+
+// Max 返回最大值
+func (h *Histogram) Max() int64 {
+	return h.max
+}
+
+// Mean 返回平均值
+func (h *Histogram) Mean() int64 {
+	if h.total == 0 {
+		return 0
+	}
+	return h.sum / int64(h.total)
+}
+
+// Percentile 返回第 p 百分位的值(p 取值 0~100), 结果不超过实际最大值
+func (h *Histogram) Percentile(p float64) int64 {
+	if h.total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(p / 100 * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var acc uint64
+	for idx, c := range h.counts {
+		acc += c
+		if acc >= rank {
+			v := histBucketUpper(idx)
+			if v > h.max {
+				v = h.max
+			}
+			return v
+		}
+	}
+	return h.max
+}
+
+// RouteStat 单个路由的延迟统计快照, 时间单位均为微秒
+type RouteStat struct {
+	Route  string `json:"route"`
+	Count  uint64 `json:"count"`
+	Errors uint64 `json:"errors"` // 发送后未收到响应的次数
+	Min    int64  `json:"min"`
+	Mean   int64  `json:"mean"`
+	P50    int64  `json:"p50"`
+	P90    int64  `json:"p90"`
+	P99    int64  `json:"p99"`
+	Max    int64  `json:"max"`
+}
+
+// routeEntry 单个路由的累计数据
+type routeEntry struct {
+	hist   *Histogram
+	errors uint64
+}
+
+// RouteStats 按路由累计发送到响应的延迟, 跨多次流程执行保留
+type RouteStats struct {
+	mu     sync.Mutex
+	routes map[string]*routeEntry
+}
+
+// NewRouteStats 创建路由统计
+func NewRouteStats() *RouteStats {
+	return &RouteStats{
+		routes: make(map[string]*routeEntry),
+	}
+}
+
+// entry 获取路由对应的累计数据, 不存在则创建(调用方需持有锁)
+func (s *RouteStats) entry(route string) *routeEntry {
+	e, ok := s.routes[route]
+	if !ok {
+		e = &routeEntry{hist: NewHistogram()}
+		s.routes[route] = e
+	}
+	return e
+}
+
+// Record 记录一次成功响应的延迟
+func (s *RouteStats) Record(route string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entry(route).hist.Record(latency.Microseconds())
+}
+
+// RecordError 记录一次未收到响应的请求
+func (s *RouteStats) RecordError(route string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entry(route).errors++
+}
+
+// Snapshot 返回所有路由的统计快照, 按 p99 降序排列便于定位慢接口
+func (s *RouteStats) Snapshot() []RouteStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]RouteStat, 0, len(s.routes))
+	for route, e := range s.routes {
+		h := e.hist
+		result = append(result, RouteStat{
+			Route:  route,
+			Count:  h.Count(),
+			Errors: e.errors,
+			Min:    h.Min(),
+			Mean:   h.Mean(),
+			P50:    h.Percentile(50),
+			P90:    h.Percentile(90),
+			P99:    h.Percentile(99),
+			Max:    h.Max(),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].P99 != result[j].P99 {
+			return result[i].P99 > result[j].P99
+		}
+		return result[i].Route < result[j].Route
+	})
+	return result
+}
+
+// Reset 清空所有统计
+func (s *RouteStats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = make(map[string]*routeEntry)
+}
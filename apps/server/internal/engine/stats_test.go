@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogramExactSmallValues(t *testing.T) {
+	h := NewHistogram()
+	for v := int64(1); v <= 100; v++ {
+		h.Record(v)
+	}
+
+	if h.Count() != 100 {
+		t.Fatalf("count = %d, want 100", h.Count())
+	}
+	if h.Min() != 1 || h.Max() != 100 {
+		t.Fatalf("min/max = %d/%d, want 1/100", h.Min(), h.Max())
+	}
+	if p := h.Percentile(50); p != 50 {
+		t.Fatalf("p50 = %d, want 50", p)
+	}
+	if p := h.Percentile(99); p != 99 {
+		t.Fatalf("p99 = %d, want 99", p)
+	}
+	if p := h.Percentile(100); p != 100 {
+		t.Fatalf("p100 = %d, want 100", p)
+	}
+}
+
+func TestHistogramRelativeError(t *testing.T) {
+	h := NewHistogram()
+	for v := int64(1); v <= 1000000; v += 7 {
+		h.Record(v)
+	}
+
+	// 理论 p90 约为 900000, 子桶精度保证相对误差在 1/64 以内
+	p90 := h.Percentile(90)
+	want := int64(900000)
+	diff := p90 - want
+	if diff < 0 {
+		diff = -diff
+	}
+	if float64(diff)/float64(want) > 1.0/64 {
+		t.Fatalf("p90 = %d, want ~%d", p90, want)
+	}
+	if h.Percentile(100) != h.Max() {
+		t.Fatalf("p100 = %d, want max %d", h.Percentile(100), h.Max())
+	}
+}
+
+func TestHistogramBucketMonotonic(t *testing.T) {
+	prev := -1
+	for v := int64(0); v < 1<<16; v++ {
+		idx := histBucketIndex(v)
+		if idx < prev {
+			t.Fatalf("bucket index not monotonic at %d: %d < %d", v, idx, prev)
+		}
+		if upper := histBucketUpper(idx); upper < v {
+			t.Fatalf("bucket upper %d < value %d", upper, v)
+		}
+		prev = idx
+	}
+}
+
+func TestHistogramEmpty(t *testing.T) {
+	h := NewHistogram()
+	if h.Percentile(99) != 0 || h.Mean() != 0 {
+		t.Fatal("empty histogram should report zeros")
+	}
+}
+
+func TestRouteStatsSnapshot(t *testing.T) {
+	s := NewRouteStats()
+	s.Record("1001", 200*time.Microsecond)
+	s.Record("1001", 400*time.Microsecond)
+	s.Record("connector.entryHandler.enter", 5*time.Millisecond)
+	s.RecordError("1001")
+
+	snap := s.Snapshot()
+	if len(snap) != 2 {
+		t.Fatalf("snapshot len = %d, want 2", len(snap))
+	}
+
+	// 按 p99 降序: 慢路由在前
+	if snap[0].Route != "connector.entryHandler.enter" {
+		t.Fatalf("first route = %q, want slowest route first", snap[0].Route)
+	}
+	fast := snap[1]
+	if fast.Count != 2 || fast.Errors != 1 {
+		t.Fatalf("count/errors = %d/%d, want 2/1", fast.Count, fast.Errors)
+	}
+	if fast.Min != 200 || fast.Max != 400 || fast.Mean != 300 {
+		t.Fatalf("min/mean/max = %d/%d/%d, want 200/300/400", fast.Min, fast.Mean, fast.Max)
+	}
+
+	s.Reset()
+	if len(s.Snapshot()) != 0 {
+		t.Fatal("snapshot should be empty after reset")
+	}
+}
@@ -0,0 +1,181 @@
+package network
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrFaultDisconnect 故障注入主动断开连接
+var ErrFaultDisconnect = errors.New("fault injection: disconnect")
+
+// FaultProfile 单方向的故障注入配置, 零值表示不注入任何故障
+type FaultProfile struct {
+	Latency        int64   `json:"latency"`        // 固定延迟(毫秒)
+	Jitter         int64   `json:"jitter"`         // 额外随机延迟上限(毫秒)
+	Bandwidth      int     `json:"bandwidth"`      // 带宽上限(字节/秒), 0 表示不限
+	FragmentSize   int     `json:"fragmentSize"`   // 分片大小, 写入时拆分为多次写, 读取时限制单次读取长度; 0 表示不分片
+	FragmentDelay  int64   `json:"fragmentDelay"`  // 分片之间的间隔(毫秒)
+	DisconnectRate float64 `json:"disconnectRate"` // 每次读写后随机断开的概率
+	CorruptRate    float64 `json:"corruptRate"`    // 每个字节被随机翻转一位的概率
+}
+
+// Enabled 返回是否配置了任何故障
+func (p FaultProfile) Enabled() bool {
+	return p != FaultProfile{}
+}
+
+// FaultConfig 双向故障注入配置
+type FaultConfig struct {
+	Send    FaultProfile `json:"send"`    // 发往服务端方向
+	Receive FaultProfile `json:"receive"` // 从服务端接收方向
+	Seed    int64        `json:"seed"`    // 随机种子, 0 表示使用当前时间
+}
+
+// Enabled 返回是否配置了任何故障
+func (c FaultConfig) Enabled() bool {
+	return c.Send.Enabled() || c.Receive.Enabled()
+}
+
+// FaultConn 按 FaultConfig 注入延迟、限速、分片、断开和比特翻转的 net.Conn 包装
+type FaultConn struct {
+	net.Conn
+	cfg FaultConfig
+
+	rngMu sync.Mutex
+	rng   *rand.Rand
+
+	writeMu sync.Mutex
+	closeMu sync.Mutex
+	faulted bool
+}
+
+// NewFaultConn 包装连接
+func NewFaultConn(conn net.Conn, cfg FaultConfig) *FaultConn {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &FaultConn{Conn: conn, cfg: cfg, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Write 按发送方向配置写入, 分片时保证整块数据写完才返回
+func (c *FaultConn) Write(b []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.isFaulted() {
+		return 0, ErrFaultDisconnect
+	}
+
+	p := c.cfg.Send
+	c.delay(p)
+
+	data := b
+	if p.CorruptRate > 0 {
+		data = append([]byte(nil), b...)
+		c.corrupt(p, data)
+	}
+
+	for len(data) > 0 {
+		chunk := data
+		if p.FragmentSize > 0 && len(chunk) > p.FragmentSize {
+			chunk = chunk[:p.FragmentSize]
+		}
+		c.throttle(p, len(chunk))
+		if _, err := c.Conn.Write(chunk); err != nil {
+			return len(b) - len(data), err
+		}
+		data = data[len(chunk):]
+		if len(data) > 0 && p.FragmentDelay > 0 {
+			time.Sleep(time.Duration(p.FragmentDelay) * time.Millisecond)
+		}
+	}
+
+	c.maybeDisconnect(p)
+	return len(b), nil
+}
+
+// Read 按接收方向配置读取
+func (c *FaultConn) Read(b []byte) (int, error) {
+	if c.isFaulted() {
+		return 0, ErrFaultDisconnect
+	}
+
+	p := c.cfg.Receive
+	if p.FragmentSize > 0 && len(b) > p.FragmentSize {
+		b = b[:p.FragmentSize]
+	}
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.delay(p)
+		c.throttle(p, n)
+		c.corrupt(p, b[:n])
+		c.maybeDisconnect(p)
+	}
+	return n, err
+}
+
+// delay 固定延迟加随机抖动
+func (c *FaultConn) delay(p FaultProfile) {
+	d := time.Duration(p.Latency) * time.Millisecond
+	if p.Jitter > 0 {
+		d += time.Duration(c.int63n(p.Jitter * int64(time.Millisecond)))
+	}
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// throttle 按带宽上限等待 n 字节的传输时间
+func (c *FaultConn) throttle(p FaultProfile, n int) {
+	if p.Bandwidth > 0 {
+		time.Sleep(time.Duration(n) * time.Second / time.Duration(p.Bandwidth))
+	}
+}
+
+// corrupt 按概率翻转字节中的随机一位
+func (c *FaultConn) corrupt(p FaultProfile, data []byte) {
+	if p.CorruptRate <= 0 {
+		return
+	}
+	c.rngMu.Lock()
+	defer c.rngMu.Unlock()
+	for i := range data {
+		if c.rng.Float64() < p.CorruptRate {
+			data[i] ^= 1 << c.rng.Intn(8)
+		}
+	}
+}
+
+// maybeDisconnect 按概率关闭底层连接
+func (c *FaultConn) maybeDisconnect(p FaultProfile) {
+	if p.DisconnectRate <= 0 {
+		return
+	}
+	c.rngMu.Lock()
+	hit := c.rng.Float64() < p.DisconnectRate
+	c.rngMu.Unlock()
+	if !hit {
+		return
+	}
+
+	c.closeMu.Lock()
+	c.faulted = true
+	c.closeMu.Unlock()
+	c.Conn.Close()
+}
+
+func (c *FaultConn) isFaulted() bool {
+	c.closeMu.Lock()
+	defer c.closeMu.Unlock()
+	return c.faulted
+}
+
+func (c *FaultConn) int63n(n int64) int64 {
+	c.rngMu.Lock()
+	defer c.rngMu.Unlock()
+	return c.rng.Int63n(n)
+}
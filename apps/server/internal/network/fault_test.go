@@ -0,0 +1,147 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/flow-packet/server/internal/codec"
+)
+
+// recordConn 记录每次 Write 的 net.Conn 桩
+type recordConn struct {
+	net.Conn
+	writes [][]byte
+	closed bool
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	c.writes = append(c.writes, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func (c *recordConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestFaultConnFragmentsWrites(t *testing.T) {
+	rc := &recordConn{}
+	conn := NewFaultConn(rc, FaultConfig{Send: FaultProfile{FragmentSize: 3}})
+
+	data := []byte("abcdefgh")
+	n, err := conn.Write(data)
+	if err != nil || n != len(data) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if len(rc.writes) != 3 {
+		t.Fatalf("writes = %d, want 3", len(rc.writes))
+	}
+	if !bytes.Equal(bytes.Join(rc.writes, nil), data) {
+		t.Fatalf("joined writes = %q, want %q", bytes.Join(rc.writes, nil), data)
+	}
+}
+
+func TestFaultConnCorruptsDeterministically(t *testing.T) {
+	data := bytes.Repeat([]byte{0x55}, 64)
+	cfg := FaultConfig{Send: FaultProfile{CorruptRate: 1}, Seed: 42}
+
+	first, second := &recordConn{}, &recordConn{}
+	NewFaultConn(first, cfg).Write(data)
+	NewFaultConn(second, cfg).Write(data)
+
+	for i, b := range first.writes[0] {
+		if b == 0x55 {
+			t.Fatalf("byte %d not corrupted", i)
+		}
+	}
+	if !bytes.Equal(first.writes[0], second.writes[0]) {
+		t.Fatal("same seed should corrupt identically")
+	}
+	if !bytes.Equal(data, bytes.Repeat([]byte{0x55}, 64)) {
+		t.Fatal("caller buffer must not be modified")
+	}
+}
+
+func TestFaultConnDisconnect(t *testing.T) {
+	rc := &recordConn{}
+	conn := NewFaultConn(rc, FaultConfig{Send: FaultProfile{DisconnectRate: 1}})
+
+	if _, err := conn.Write([]byte("x")); err != nil {
+		t.Fatalf("first Write error: %v", err)
+	}
+	if !rc.closed {
+		t.Fatal("underlying conn should be closed")
+	}
+	if _, err := conn.Write([]byte("y")); !errors.Is(err, ErrFaultDisconnect) {
+		t.Fatalf("second Write error = %v, want ErrFaultDisconnect", err)
+	}
+}
+
+func TestFaultConnLatency(t *testing.T) {
+	conn := NewFaultConn(&recordConn{}, FaultConfig{Send: FaultProfile{Latency: 30, Bandwidth: 1000}})
+
+	start := time.Now()
+	conn.Write(make([]byte, 20)) // 30ms 延迟 + 20 字节 @ 1000B/s = 20ms
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("elapsed = %v, want >= 50ms", elapsed)
+	}
+}
+
+func TestFaultConnFragmentedReadsDecode(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	cfg := codec.DefaultPacketConfig()
+	frames := [][]byte{encodeTestFrame(t, 1, 1, "hello"), encodeTestFrame(t, 2, 2, "world!")}
+	go func() {
+		for _, f := range frames {
+			server.Write(f)
+		}
+	}()
+
+	conn := NewFaultConn(client, FaultConfig{Receive: FaultProfile{FragmentSize: 1}})
+	decoder := codec.NewDecoder(conn, cfg)
+	for i := range frames {
+		pkt, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("frame %d: Decode error: %v", i, err)
+		}
+		if pkt.Route != uint64(i+1) {
+			t.Fatalf("frame %d: route = %d", i, pkt.Route)
+		}
+	}
+}
+
+func TestTCPClientFaultInjection(t *testing.T) {
+	addr, closeServer := startEchoServer(t)
+	defer closeServer()
+
+	client := NewTCPClient(codec.DefaultPacketConfig())
+	client.SetFaultConfig(FaultConfig{
+		Send:    FaultProfile{FragmentSize: 2, FragmentDelay: 1},
+		Receive: FaultProfile{FragmentSize: 3},
+	})
+
+	received := make(chan *codec.Packet, 1)
+	client.OnReceive(func(conn Conn, pkt *codec.Packet) { received <- pkt })
+	if err := client.Connect(addr); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer client.Disconnect()
+
+	frame := encodeTestFrame(t, 1001, 1, "half packets")
+	client.Send(frame)
+
+	select {
+	case pkt := <-received:
+		if pkt.Route != 1001 || pkt.Seq != 1 || !bytes.Equal(pkt.Data, []byte("half packets")) {
+			t.Fatalf("echo = %+v", pkt)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for echo")
+	}
+}
@@ -27,8 +27,7 @@ func DefaultHeartbeatConfig() HeartbeatConfig {
 // Heartbeat 心跳模块, 独立 goroutine 定时发送心跳并监测超时
 type Heartbeat struct {
 	cfg       HeartbeatConfig
-	packetCfg codec.PacketConfig
-	sendFn    func(data []byte) error
+	sendFn    func(pkt *codec.Packet) error
 	onTimeout func()
 
 	mu           sync.Mutex
@@ -38,10 +37,9 @@ type Heartbeat struct {
 }
 
 // NewHeartbeat 创建心跳模块
-func NewHeartbeat(cfg HeartbeatConfig, packetCfg codec.PacketConfig) *Heartbeat {
+func NewHeartbeat(cfg HeartbeatConfig) *Heartbeat {
 	return &Heartbeat{
-		cfg:       cfg,
-		packetCfg: packetCfg,
+		cfg: cfg,
 	}
 }
 
@@ -50,13 +48,24 @@ func (h *Heartbeat) SetEnable(enable bool) {
 	h.cfg.Enable = enable
 }
 
-// SetPacketConfig 动态更新协议帧配置
-func (h *Heartbeat) SetPacketConfig(cfg codec.PacketConfig) {
-	h.packetCfg = cfg
+// SetInterval 按服务端下发的心跳间隔更新配置, 超时为间隔的 3 倍; 运行中时按新间隔重启
+func (h *Heartbeat) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	running := h.Running()
+	if running {
+		h.Stop()
+	}
+	h.cfg.Interval = interval
+	h.cfg.Timeout = 3 * interval
+	if running {
+		h.Start()
+	}
 }
 
-// OnSend 注册心跳发送函数
-func (h *Heartbeat) OnSend(fn func(data []byte) error) {
+// OnSend 注册心跳发送函数, 心跳包由连接按当前协议帧配置编码
+func (h *Heartbeat) OnSend(fn func(pkt *codec.Packet) error) {
 	h.sendFn = fn
 }
 
@@ -79,9 +88,10 @@ func (h *Heartbeat) Start() {
 	h.running = true
 	h.lastReceived = time.Now()
 	h.stopCh = make(chan struct{})
+	stopCh := h.stopCh
 	h.mu.Unlock()
 
-	go h.loop()
+	go h.loop(stopCh, h.cfg.Interval, h.cfg.Timeout)
 }
 
 // Stop 停止心跳
@@ -110,13 +120,13 @@ func (h *Heartbeat) Running() bool {
 	return h.running
 }
 
-func (h *Heartbeat) loop() {
-	ticker := time.NewTicker(h.cfg.Interval)
+func (h *Heartbeat) loop(stopCh chan struct{}, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-h.stopCh:
+		case <-stopCh:
 			return
 		case <-ticker.C:
 			// 发送心跳包
@@ -127,7 +137,7 @@ func (h *Heartbeat) loop() {
 			elapsed := time.Since(h.lastReceived)
 			h.mu.Unlock()
 
-			if elapsed > h.cfg.Timeout {
+			if elapsed > timeout {
 				fmt.Printf("[heartbeat] timeout, last received %v ago\n", elapsed)
 				if h.onTimeout != nil {
 					h.onTimeout()
@@ -151,15 +161,9 @@ func (h *Heartbeat) sendHeartbeat() {
 		ExtCode:   0,
 	}
 
-	data, err := codec.Encode(pkt, h.packetCfg)
-	if err != nil {
-		fmt.Printf("[heartbeat] encode error: %v\n", err)
-		return
-	}
-
-	if err := h.sendFn(data); err != nil {
+	if err := h.sendFn(pkt); err != nil {
 		fmt.Printf("[heartbeat] send error: %v\n", err)
 		return
 	}
-	fmt.Printf("[heartbeat] sent %d bytes\n", len(data))
+	fmt.Println("[heartbeat] sent")
 }
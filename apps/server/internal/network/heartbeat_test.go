@@ -14,10 +14,10 @@ func TestHeartbeatSendInterval(t *testing.T) {
 		Interval: 50 * time.Millisecond,
 		Timeout:  500 * time.Millisecond,
 	}
-	hb := NewHeartbeat(cfg, codec.DefaultPacketConfig())
+	hb := NewHeartbeat(cfg)
 
 	var sendCount int32
-	hb.OnSend(func(data []byte) error {
+	hb.OnSend(func(pkt *codec.Packet) error {
 		atomic.AddInt32(&sendCount, 1)
 		return nil
 	})
@@ -41,9 +41,9 @@ func TestHeartbeatTimeout(t *testing.T) {
 		Interval: 30 * time.Millisecond,
 		Timeout:  80 * time.Millisecond,
 	}
-	hb := NewHeartbeat(cfg, codec.DefaultPacketConfig())
+	hb := NewHeartbeat(cfg)
 
-	hb.OnSend(func(data []byte) error { return nil })
+	hb.OnSend(func(pkt *codec.Packet) error { return nil })
 
 	timedOut := make(chan struct{})
 	hb.OnTimeout(func() {
@@ -70,9 +70,9 @@ func TestHeartbeatFeedPreventsTimeout(t *testing.T) {
 		Interval: 30 * time.Millisecond,
 		Timeout:  80 * time.Millisecond,
 	}
-	hb := NewHeartbeat(cfg, codec.DefaultPacketConfig())
+	hb := NewHeartbeat(cfg)
 
-	hb.OnSend(func(data []byte) error { return nil })
+	hb.OnSend(func(pkt *codec.Packet) error { return nil })
 
 	timedOut := make(chan struct{})
 	hb.OnTimeout(func() {
@@ -114,10 +114,10 @@ func TestHeartbeatDisabled(t *testing.T) {
 		Interval: 10 * time.Millisecond,
 		Timeout:  30 * time.Millisecond,
 	}
-	hb := NewHeartbeat(cfg, codec.DefaultPacketConfig())
+	hb := NewHeartbeat(cfg)
 
 	var sendCount int32
-	hb.OnSend(func(data []byte) error {
+	hb.OnSend(func(pkt *codec.Packet) error {
 		atomic.AddInt32(&sendCount, 1)
 		return nil
 	})
@@ -139,9 +139,9 @@ func TestHeartbeatStopBeforeTimeout(t *testing.T) {
 		Interval: 20 * time.Millisecond,
 		Timeout:  60 * time.Millisecond,
 	}
-	hb := NewHeartbeat(cfg, codec.DefaultPacketConfig())
+	hb := NewHeartbeat(cfg)
 
-	hb.OnSend(func(data []byte) error { return nil })
+	hb.OnSend(func(pkt *codec.Packet) error { return nil })
 
 	timedOut := false
 	hb.OnTimeout(func() {
@@ -171,15 +171,12 @@ func TestHeartbeatSendsValidPacket(t *testing.T) {
 		Interval: 20 * time.Millisecond,
 		Timeout:  500 * time.Millisecond,
 	}
-	packetCfg := codec.DefaultPacketConfig()
-	hb := NewHeartbeat(cfg, packetCfg)
+	hb := NewHeartbeat(cfg)
 
-	received := make(chan []byte, 1)
-	hb.OnSend(func(data []byte) error {
-		cp := make([]byte, len(data))
-		copy(cp, data)
+	received := make(chan *codec.Packet, 1)
+	hb.OnSend(func(pkt *codec.Packet) error {
 		select {
-		case received <- cp:
+		case received <- pkt:
 		default:
 		}
 		return nil
@@ -189,16 +186,50 @@ func TestHeartbeatSendsValidPacket(t *testing.T) {
 	defer hb.Stop()
 
 	select {
-	case data := <-received:
-		// 验证是有效的心跳包：size(4) + header(1) = 5 bytes
-		if len(data) != 5 {
-			t.Fatalf("heartbeat packet len = %d, want 5", len(data))
+	case pkt := <-received:
+		if !pkt.IsHeartbeat() {
+			t.Fatalf("sent packet = %+v, want heartbeat", pkt)
 		}
-		// header: h=1 (0x80)
-		if data[4] != 0x80 {
-			t.Fatalf("heartbeat header = 0x%02X, want 0x80", data[4])
+		// 按默认协议帧配置编码: size(4) + header(1) = 5 bytes, header h=1 (0x80)
+		data, err := codec.Encode(pkt, codec.DefaultPacketConfig())
+		if err != nil {
+			t.Fatalf("Encode error: %v", err)
+		}
+		if len(data) != 5 || data[4] != 0x80 {
+			t.Fatalf("heartbeat frame = %x, want 5 bytes with header 0x80", data)
 		}
 	case <-time.After(2 * time.Second):
 		t.Fatal("timeout waiting for heartbeat packet")
 	}
 }
+
+func TestHeartbeatSetInterval(t *testing.T) {
+	cfg := HeartbeatConfig{
+		Enable:   true,
+		Interval: time.Hour,
+		Timeout:  time.Hour,
+	}
+	hb := NewHeartbeat(cfg)
+
+	var sendCount int32
+	hb.OnSend(func(pkt *codec.Packet) error {
+		atomic.AddInt32(&sendCount, 1)
+		// 模拟服务端回复心跳, 避免按新间隔计算的超时触发
+		hb.Feed()
+		return nil
+	})
+
+	hb.Start()
+	defer hb.Stop()
+
+	// 握手响应下发的间隔在运行中生效
+	hb.SetInterval(50 * time.Millisecond)
+	time.Sleep(180 * time.Millisecond)
+
+	if count := atomic.LoadInt32(&sendCount); count < 2 {
+		t.Fatalf("sendCount = %d, expected >= 2 after switching to 50ms interval", count)
+	}
+	if !hb.Running() {
+		t.Fatal("heartbeat stopped after SetInterval")
+	}
+}
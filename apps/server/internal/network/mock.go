@@ -0,0 +1,412 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/flow-packet/server/internal/codec"
+	"github.com/gorilla/websocket"
+)
+
+// MockConfig 模拟网关配置
+type MockConfig struct {
+	Protocol     string // tcp 或 ws
+	ListenAddr   string // 监听地址, 如 127.0.0.1:9000
+	PacketConfig codec.PacketConfig
+}
+
+// MockHandler 处理一个请求, 返回的响应包会以请求的 seq 编码后回复, 为 nil 表示不回复;
+// 返回的事件在响应发送后开始计时
+type MockHandler func(session *MockSession, req *codec.Packet) (*codec.Packet, []MockEvent, error)
+
+// MockAction 模拟网关事件类型
+type MockAction string
+
+const (
+	MockActionPush       MockAction = "push"       // 下发推送
+	MockActionKick       MockAction = "kick"       // 踢下线(Pomelo 下发 kick 包)后断开
+	MockActionDisconnect MockAction = "disconnect" // 直接断开连接
+)
+
+// MockEvent 会话上的定时事件
+type MockEvent struct {
+	Delay   time.Duration // 首次触发前的等待时间
+	Every   time.Duration // 重复间隔, 0 表示只触发一次
+	Action  MockAction
+	Packet  *codec.Packet // 推送内容, 仅 push 使用
+	Payload []byte        // kick 包内容, 仅 Pomelo kick 使用
+}
+
+// MockSession 模拟网关上的一个客户端会话
+type MockSession struct {
+	ID     uint64
+	Remote net.Addr
+
+	server    *MockServer
+	cfg       codec.PacketConfig // 会话的协议帧配置, 流加密的密钥流在会话内跨帧连续
+	writeMu   sync.Mutex
+	write     func(data []byte) error
+	close     func() error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Done 返回会话结束时关闭的 channel
+func (s *MockSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Push 以推送形式下发数据包(不携带 seq)
+func (s *MockSession) Push(pkt *codec.Packet) error {
+	if s.server.cfg.PacketConfig.IsPomelo() {
+		return s.SendRaw(codec.PomeloEncodePush(pkt.Route, pkt.StringRoute, pkt.Data))
+	}
+	push := *pkt
+	push.Seq = 0
+	return s.Send(&push)
+}
+
+// Kick 踢下线: Pomelo 模式先下发 kick 包, 随后断开连接
+func (s *MockSession) Kick(payload []byte) error {
+	if s.server.cfg.PacketConfig.IsPomelo() {
+		if err := s.SendRaw(codec.PomeloEncodeKick(payload)); err != nil {
+			return err
+		}
+	}
+	return s.Close()
+}
+
+// Schedule 在会话上调度事件, 会话结束后未触发的事件自动取消
+func (s *MockSession) Schedule(ev MockEvent) {
+	go func() {
+		timer := time.NewTimer(ev.Delay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-timer.C:
+			}
+			if err := s.fire(ev); err != nil {
+				s.server.reportError(s, fmt.Errorf("%s event: %w", ev.Action, err))
+				return
+			}
+			if ev.Every <= 0 || ev.Action != MockActionPush {
+				return
+			}
+			timer.Reset(ev.Every)
+		}
+	}()
+}
+
+// fire 执行一次事件
+func (s *MockSession) fire(ev MockEvent) error {
+	switch ev.Action {
+	case MockActionPush:
+		if ev.Packet == nil {
+			return errors.New("push event without packet")
+		}
+		return s.Push(ev.Packet)
+	case MockActionKick:
+		return s.Kick(ev.Payload)
+	case MockActionDisconnect:
+		return s.Close()
+	default:
+		return fmt.Errorf("unknown action %q", ev.Action)
+	}
+}
+
+// finish 标记会话结束
+func (s *MockSession) finish() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// Send 编码并发送一个数据包, 编码与写入在同一把锁内完成以保证密钥流顺序
+func (s *MockSession) Send(pkt *codec.Packet) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	data, err := s.encode(pkt)
+	if err != nil {
+		return err
+	}
+	return s.write(data)
+}
+
+// SendRaw 发送已编码的帧
+func (s *MockSession) SendRaw(data []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.write(data)
+}
+
+// Close 主动断开会话
+func (s *MockSession) Close() error {
+	return s.close()
+}
+
+// MockServer 模拟游戏网关: 按协议帧配置解码请求, 交给 MockHandler 生成响应
+//
+// 心跳包原样回复; Pomelo 模式下自动完成握手, 响应以 Response 消息类型编码
+type MockServer struct {
+	cfg     MockConfig
+	handler MockHandler
+
+	mu       sync.Mutex
+	listener net.Listener
+	sessions map[uint64]*MockSession
+	nextID   uint64
+	running  bool
+
+	onError   func(session *MockSession, err error)
+	onConnect func(session *MockSession)
+
+	upgrader websocket.Upgrader
+}
+
+// NewMockServer 创建模拟网关
+func NewMockServer(cfg MockConfig, handler MockHandler) *MockServer {
+	return &MockServer{
+		cfg:      cfg,
+		handler:  handler,
+		sessions: make(map[uint64]*MockSession),
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// OnError 注册请求处理错误回调, 错误不会中断会话
+func (m *MockServer) OnError(fn func(session *MockSession, err error)) {
+	m.mu.Lock()
+	m.onError = fn
+	m.mu.Unlock()
+}
+
+// OnConnect 注册会话建立回调, 可用于调度连接后的定时事件
+func (m *MockServer) OnConnect(fn func(session *MockSession)) {
+	m.mu.Lock()
+	m.onConnect = fn
+	m.mu.Unlock()
+}
+
+// Start 开始监听, 返回实际监听地址
+func (m *MockServer) Start() (net.Addr, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		return nil, errors.New("mock server already running")
+	}
+
+	ln, err := net.Listen("tcp", m.cfg.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	m.listener = ln
+	m.running = true
+
+	if m.cfg.Protocol == "ws" {
+		go http.Serve(ln, http.HandlerFunc(m.handleWebSocket))
+	} else {
+		go m.acceptTCP(ln)
+	}
+	return ln.Addr(), nil
+}
+
+// Stop 停止监听并断开所有会话
+func (m *MockServer) Stop() error {
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return nil
+	}
+	m.running = false
+	ln := m.listener
+	sessions := m.sessions
+	m.sessions = make(map[uint64]*MockSession)
+	m.mu.Unlock()
+
+	for _, s := range sessions {
+		s.Close()
+	}
+	return ln.Close()
+}
+
+// Sessions 返回当前所有会话
+func (m *MockServer) Sessions() []*MockSession {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*MockSession, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		list = append(list, s)
+	}
+	return list
+}
+
+// addSession 登记新会话, 服务已停止时返回 nil
+func (m *MockServer) addSession(remote net.Addr, write func([]byte) error, closeFn func() error) *MockSession {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.running {
+		return nil
+	}
+	m.nextID++
+	s := &MockSession{
+		ID:     m.nextID,
+		Remote: remote,
+		server: m,
+		cfg:    m.cfg.PacketConfig.WithCipherSession(),
+		write:  write,
+		close:  closeFn,
+		done:   make(chan struct{}),
+	}
+	m.sessions[s.ID] = s
+	return s
+}
+
+// startSession 触发会话建立回调
+func (m *MockServer) startSession(s *MockSession) {
+	m.mu.Lock()
+	h := m.onConnect
+	m.mu.Unlock()
+	if h != nil {
+		h(s)
+	}
+}
+
+func (m *MockServer) removeSession(s *MockSession) {
+	m.mu.Lock()
+	delete(m.sessions, s.ID)
+	m.mu.Unlock()
+	s.finish()
+}
+
+func (m *MockServer) reportError(s *MockSession, err error) {
+	m.mu.Lock()
+	h := m.onError
+	m.mu.Unlock()
+	if h != nil {
+		h(s, err)
+	}
+}
+
+// encode 按会话配置编码服务端下发的数据包
+func (s *MockSession) encode(pkt *codec.Packet) ([]byte, error) {
+	if s.cfg.IsPomelo() && !pkt.Heartbeat {
+		return codec.PomeloEncodeResponse(pkt.Seq, pkt.Data), nil
+	}
+	return codec.Encode(pkt, s.cfg)
+}
+
+// pomeloHandshakeResponse Pomelo 握手响应体
+var pomeloHandshakeResponse, _ = json.Marshal(map[string]any{
+	"code": 200,
+	"sys":  map[string]any{"heartbeat": 30, "dict": map[string]int{}},
+})
+
+// handle 处理一个已解码的请求包
+func (m *MockServer) handle(s *MockSession, req *codec.Packet) {
+	if req.IsHeartbeat() {
+		resp := &codec.Packet{Heartbeat: true}
+		if m.cfg.PacketConfig.ProtocolName() == codec.ProtocolDue {
+			// Due 网关的心跳响应携带服务器时间
+			resp = codec.DueHeartbeat(time.Now())
+		}
+		if err := s.Send(resp); err != nil {
+			m.reportError(s, err)
+		}
+		return
+	}
+	if m.cfg.PacketConfig.IsPomelo() && req.ExtCode != 0 {
+		if req.ExtCode == codec.PomeloPacketHandshake {
+			if err := s.SendRaw(codec.PomeloEncodeHandshake(pomeloHandshakeResponse)); err != nil {
+				m.reportError(s, err)
+			}
+		}
+		return
+	}
+
+	resp, events, err := m.handler(s, req)
+	if err != nil {
+		m.reportError(s, fmt.Errorf("handle seq %d: %w", req.Seq, err))
+		return
+	}
+	if resp != nil {
+		resp.Seq = req.Seq
+		if err := s.Send(resp); err != nil {
+			m.reportError(s, err)
+			return
+		}
+	}
+	for _, ev := range events {
+		s.Schedule(ev)
+	}
+}
+
+func (m *MockServer) acceptTCP(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go m.serveTCP(conn)
+	}
+}
+
+func (m *MockServer) serveTCP(conn net.Conn) {
+	defer conn.Close()
+	s := m.addSession(conn.RemoteAddr(), func(data []byte) error {
+		_, err := conn.Write(data)
+		return err
+	}, conn.Close)
+	if s == nil {
+		return
+	}
+	defer m.removeSession(s)
+	m.startSession(s)
+
+	decoder := codec.NewDecoder(conn, s.cfg)
+	for {
+		pkt, err := decoder.Decode()
+		if err != nil {
+			return
+		}
+		m.handle(s, pkt)
+	}
+}
+
+func (m *MockServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := m.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	s := m.addSession(conn.RemoteAddr(), func(data []byte) error {
+		return conn.WriteMessage(websocket.BinaryMessage, data)
+	}, conn.Close)
+	if s == nil {
+		return
+	}
+	defer m.removeSession(s)
+	m.startSession(s)
+
+	for {
+		msgType, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if msgType != websocket.BinaryMessage {
+			continue
+		}
+		pkt, err := codec.DecodeBytes(msg, s.cfg)
+		if err != nil {
+			m.reportError(s, err)
+			continue
+		}
+		m.handle(s, pkt)
+	}
+}
@@ -0,0 +1,202 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/flow-packet/server/internal/codec"
+)
+
+// startTestMock 启动回复 "re:" + 请求体的模拟网关
+func startTestMock(t *testing.T, protocol string, cfg codec.PacketConfig) (*MockServer, string) {
+	t.Helper()
+	server := NewMockServer(MockConfig{Protocol: protocol, ListenAddr: "127.0.0.1:0", PacketConfig: cfg},
+		func(session *MockSession, req *codec.Packet) (*codec.Packet, []MockEvent, error) {
+			return &codec.Packet{Route: req.Route, Data: append([]byte("re:"), req.Data...)}, nil, nil
+		})
+	addr, err := server.Start()
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	return server, addr.String()
+}
+
+func TestMockServerRepliesWithRequestSeq(t *testing.T) {
+	for _, protocol := range []string{"tcp", "ws"} {
+		t.Run(protocol, func(t *testing.T) {
+			cfg := codec.DefaultPacketConfig()
+			server, addr := startTestMock(t, protocol, cfg)
+			defer server.Stop()
+
+			var client Client
+			if protocol == "ws" {
+				client = NewWSClient(cfg)
+			} else {
+				client = NewTCPClient(cfg)
+			}
+			received := make(chan *codec.Packet, 2)
+			client.OnReceive(func(conn Conn, pkt *codec.Packet) { received <- pkt })
+			if err := client.Connect(addr); err != nil {
+				t.Fatalf("Connect error: %v", err)
+			}
+			defer client.Disconnect()
+
+			client.Send(encodeTestFrame(t, 1001, 9, "ping"))
+			heartbeat, _ := codec.Encode(&codec.Packet{Heartbeat: true}, cfg)
+			client.Send(heartbeat)
+
+			for i := 0; i < 2; i++ {
+				select {
+				case pkt := <-received:
+					if pkt.Heartbeat {
+						continue
+					}
+					if pkt.Route != 1001 || pkt.Seq != 9 || !bytes.Equal(pkt.Data, []byte("re:ping")) {
+						t.Fatalf("reply = %+v", pkt)
+					}
+				case <-time.After(2 * time.Second):
+					t.Fatal("timeout waiting for mock reply")
+				}
+			}
+		})
+	}
+}
+
+func TestMockServerPomeloHandshake(t *testing.T) {
+	cfg := codec.PacketConfig{Pomelo: &codec.PomeloConfig{UseRouteCompress: true}}
+	server, addr := startTestMock(t, "tcp", cfg)
+	defer server.Stop()
+
+	client := NewTCPClient(cfg)
+	received := make(chan *codec.Packet, 2)
+	client.OnReceive(func(conn Conn, pkt *codec.Packet) { received <- pkt })
+	if err := client.Connect(addr); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer client.Disconnect()
+
+	client.Send(codec.PomeloEncodeHandshake([]byte(`{}`)))
+	client.Send(codec.PomeloEncodeHandshakeAck())
+	req, _ := codec.Encode(&codec.Packet{StringRoute: "connector.entry", Seq: 1, Data: []byte("hi")}, cfg)
+	client.Send(req)
+
+	wait := func() *codec.Packet {
+		select {
+		case pkt := <-received:
+			return pkt
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for mock reply")
+			return nil
+		}
+	}
+
+	if hs := wait(); hs.ExtCode != codec.PomeloPacketHandshake {
+		t.Fatalf("first reply ext = %d, want handshake", hs.ExtCode)
+	}
+	if resp := wait(); resp.Seq != 1 || !bytes.Equal(resp.Data, []byte("re:hi")) {
+		t.Fatalf("response = %+v", resp)
+	}
+}
+
+func TestMockServerEvents(t *testing.T) {
+	cfg := codec.DefaultPacketConfig()
+	server := NewMockServer(MockConfig{ListenAddr: "127.0.0.1:0", PacketConfig: cfg},
+		func(session *MockSession, req *codec.Packet) (*codec.Packet, []MockEvent, error) {
+			// 响应后 50ms 推送, 再过 50ms 断开
+			return &codec.Packet{Route: req.Route}, []MockEvent{
+				{Delay: 50 * time.Millisecond, Action: MockActionPush, Packet: &codec.Packet{Route: 2001, Data: []byte("bag")}},
+				{Delay: 100 * time.Millisecond, Action: MockActionKick},
+			}, nil
+		})
+	connected := make(chan uint64, 1)
+	server.OnConnect(func(session *MockSession) { connected <- session.ID })
+	addr, err := server.Start()
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	defer server.Stop()
+
+	client := NewTCPClient(cfg)
+	client.SetReconnectConfig(ReconnectConfig{})
+	received := make(chan *codec.Packet, 4)
+	disconnected := make(chan struct{})
+	client.OnReceive(func(conn Conn, pkt *codec.Packet) { received <- pkt })
+	client.OnDisconnect(func(conn Conn, err error) { close(disconnected) })
+	if err := client.Connect(addr.String()); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer client.Disconnect()
+
+	select {
+	case <-connected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnConnect not called")
+	}
+
+	client.Send(encodeTestFrame(t, 1001, 3, ""))
+
+	var got []*codec.Packet
+	for len(got) < 2 {
+		select {
+		case pkt := <-received:
+			got = append(got, pkt)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout, got %d packets", len(got))
+		}
+	}
+	if got[0].Route != 1001 || got[0].Seq != 3 {
+		t.Fatalf("reply = %+v", got[0])
+	}
+	if got[1].Route != 2001 || got[1].Seq != 0 || string(got[1].Data) != "bag" {
+		t.Fatalf("push = %+v", got[1])
+	}
+
+	select {
+	case <-disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("kick did not disconnect client")
+	}
+}
+
+func TestMockSessionScheduleStopsOnClose(t *testing.T) {
+	cfg := codec.DefaultPacketConfig()
+	server := NewMockServer(MockConfig{ListenAddr: "127.0.0.1:0", PacketConfig: cfg},
+		func(session *MockSession, req *codec.Packet) (*codec.Packet, []MockEvent, error) {
+			return nil, nil, nil
+		})
+	sessions := make(chan *MockSession, 1)
+	server.OnConnect(func(session *MockSession) {
+		session.Schedule(MockEvent{Every: 10 * time.Millisecond, Action: MockActionPush, Packet: &codec.Packet{Route: 1}})
+		sessions <- session
+	})
+	addr, err := server.Start()
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	defer server.Stop()
+
+	client := NewTCPClient(cfg)
+	received := make(chan struct{}, 64)
+	client.OnReceive(func(conn Conn, pkt *codec.Packet) { received <- struct{}{} })
+	if err := client.Connect(addr.String()); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer client.Disconnect()
+
+	session := <-sessions
+	for i := 0; i < 3; i++ {
+		select {
+		case <-received:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for push %d", i)
+		}
+	}
+
+	session.Close()
+	select {
+	case <-session.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("session Done not closed")
+	}
+}
@@ -1,7 +1,11 @@
 // Package network 提供网络层统一接口与 TCP/WebSocket 实现
 package network
 
-import "net"
+import (
+	"net"
+
+	"github.com/flow-packet/server/internal/codec"
+)
 
 // Conn 连接接口, 抽象不同传输协议的连接
 type Conn interface {
@@ -49,8 +53,11 @@ type ConnectHandler func(conn Conn)
 // DisconnectHandler 连接断开回调
 type DisconnectHandler func(conn Conn, err error)
 
-// ReceiveHandler 数据接收回调
-type ReceiveHandler func(conn Conn, data []byte)
+// ReceiveHandler 数据接收回调, pkt 为按连接当前协议帧配置解码(含解压、解密)的数据包
+type ReceiveHandler func(conn Conn, pkt *codec.Packet)
+
+// ProtocolErrorHandler 协议错误回调(帧非法、超出长度上限等), err 为 *codec.ResyncError 时连接仍然保持
+type ProtocolErrorHandler func(conn Conn, err error)
 
 // Client 客户端接口, 管理到远端服务器的连接
 type Client interface {
@@ -58,8 +65,10 @@ type Client interface {
 	Connect(addr string) error
 	// Disconnect 断开连接
 	Disconnect() error
-	// Send 发送数据
+	// Send 发送已编码的数据
 	Send(data []byte) error
+	// SendPacket 按连接当前的协议帧配置编码并发送数据包
+	SendPacket(pkt *codec.Packet) error
 	// State 获取当前连接状态
 	State() ConnState
 	// OnConnect 注册连接建立回调
@@ -69,3 +78,25 @@ type Client interface {
 	// OnReceive 注册数据接收回调
 	OnReceive(handler ReceiveHandler)
 }
+
+// connConfig 一条连接上使用的协议帧配置, 流加密的密钥流在连接内跨帧连续
+type connConfig struct {
+	cipher *codec.CipherConfig // 创建密钥流所用的加密配置
+	cfg    codec.PacketConfig
+}
+
+// newConnConfig 为新连接创建配置, 密钥流从密钥(和 IV)开始
+func newConnConfig(cfg codec.PacketConfig) connConfig {
+	return connConfig{cipher: cfg.Cipher, cfg: cfg.WithCipherSession()}
+}
+
+// update 更新配置: 加密配置未变化(如握手下发路由字典)时沿用当前密钥流,
+// 否则(如密钥交换)从新密钥重新开始
+func (c *connConfig) update(cfg codec.PacketConfig) {
+	if cfg.Cipher != c.cipher {
+		*c = newConnConfig(cfg)
+		return
+	}
+	cfg.Cipher = c.cfg.Cipher
+	c.cfg = cfg
+}
@@ -3,6 +3,8 @@ package network
 import (
 	"net"
 	"testing"
+
+	"github.com/flow-packet/server/internal/codec"
 )
 
 // mockConn 用于验证 Conn 接口契约的 mock 实现
@@ -30,6 +32,7 @@ type mockClient struct {
 func (c *mockClient) Connect(addr string) error     { c.state = ConnStateConnected; return nil }
 func (c *mockClient) Disconnect() error              { c.state = ConnStateDisconnected; return nil }
 func (c *mockClient) Send(data []byte) error         { return nil }
+func (c *mockClient) SendPacket(pkt *codec.Packet) error { return nil }
 func (c *mockClient) State() ConnState               { return c.state }
 func (c *mockClient) OnConnect(h ConnectHandler)      { c.connectHandler = h }
 func (c *mockClient) OnDisconnect(h DisconnectHandler) { c.disconnectHandler = h }
@@ -96,7 +99,7 @@ func TestClientInterface(t *testing.T) {
 	connectCalled := false
 	client.OnConnect(func(conn Conn) { connectCalled = true })
 	client.OnDisconnect(func(conn Conn, err error) {})
-	client.OnReceive(func(conn Conn, data []byte) {})
+	client.OnReceive(func(conn Conn, pkt *codec.Packet) {})
 
 	mc := client.(*mockClient)
 	if mc.connectHandler == nil {
@@ -0,0 +1,319 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/flow-packet/server/internal/codec"
+	"github.com/gorilla/websocket"
+)
+
+// ProxyConfig 透明代理配置
+type ProxyConfig struct {
+	Protocol     string             // tcp 或 ws
+	ListenAddr   string             // 本地监听地址, 如 127.0.0.1:9000
+	Upstream     string             // 真实网关地址, 如 10.0.0.5:8000
+	PacketConfig codec.PacketConfig // 用于解码双向帧的协议帧配置
+}
+
+// ProxyMessage 代理捕获的一帧
+type ProxyMessage struct {
+	Session   uint64           // 会话 ID, 每个接入的客户端连接一个
+	Direction TrafficDirection // TrafficSent 为客户端 → 网关
+	Time      time.Time
+	Raw       []byte        // 帧原始字节
+	Packet    *codec.Packet // 解码结果, 解码失败时为 nil
+	Err       error         // 帧解码错误
+}
+
+// ProxySessionHandler 会话建立/结束回调, err 为 nil 表示新会话建立
+type ProxySessionHandler func(session uint64, client net.Addr, err error)
+
+// Proxy 透明录制代理: 监听本地端口, 将流量原样转发到真实网关,
+// 同时按协议帧配置解码双向消息
+//
+// TCP 模式下某方向解码失败后, 该方向退化为字节透传, 保证客户端不受影响
+type Proxy struct {
+	cfg ProxyConfig
+
+	mu        sync.Mutex
+	listener  net.Listener
+	conns     map[io.Closer]struct{}
+	nextID    uint64
+	running   bool
+	onMessage func(msg ProxyMessage)
+	onSession ProxySessionHandler
+
+	upgrader websocket.Upgrader
+}
+
+// NewProxy 创建透明代理
+func NewProxy(cfg ProxyConfig) *Proxy {
+	return &Proxy{
+		cfg:   cfg,
+		conns: make(map[io.Closer]struct{}),
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// OnMessage 注册帧捕获回调
+func (p *Proxy) OnMessage(fn func(msg ProxyMessage)) {
+	p.mu.Lock()
+	p.onMessage = fn
+	p.mu.Unlock()
+}
+
+// OnSession 注册会话回调
+func (p *Proxy) OnSession(fn ProxySessionHandler) {
+	p.mu.Lock()
+	p.onSession = fn
+	p.mu.Unlock()
+}
+
+// Start 开始监听, 返回实际监听地址
+func (p *Proxy) Start() (net.Addr, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running {
+		return nil, errors.New("proxy already running")
+	}
+
+	ln, err := net.Listen("tcp", p.cfg.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	p.listener = ln
+	p.running = true
+
+	if p.cfg.Protocol == "ws" {
+		go http.Serve(ln, http.HandlerFunc(p.handleWebSocket))
+	} else {
+		go p.acceptTCP(ln)
+	}
+	return ln.Addr(), nil
+}
+
+// Stop 停止监听并关闭所有会话
+func (p *Proxy) Stop() error {
+	p.mu.Lock()
+	if !p.running {
+		p.mu.Unlock()
+		return nil
+	}
+	p.running = false
+	ln := p.listener
+	conns := p.conns
+	p.conns = make(map[io.Closer]struct{})
+	p.mu.Unlock()
+
+	for c := range conns {
+		c.Close()
+	}
+	return ln.Close()
+}
+
+// Running 返回代理是否正在运行
+func (p *Proxy) Running() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.running
+}
+
+// track 登记连接以便 Stop 时关闭, 代理已停止时返回 false
+func (p *Proxy) track(closers ...io.Closer) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.running {
+		return false
+	}
+	for _, c := range closers {
+		p.conns[c] = struct{}{}
+	}
+	return true
+}
+
+// untrack 移除已关闭的连接
+func (p *Proxy) untrack(closers ...io.Closer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range closers {
+		delete(p.conns, c)
+	}
+}
+
+// newSession 分配会话 ID 并触发会话回调
+func (p *Proxy) newSession(client net.Addr) uint64 {
+	p.mu.Lock()
+	p.nextID++
+	id := p.nextID
+	h := p.onSession
+	p.mu.Unlock()
+
+	if h != nil {
+		h(id, client, nil)
+	}
+	return id
+}
+
+// endSession 触发会话结束回调
+func (p *Proxy) endSession(id uint64, client net.Addr, err error) {
+	if err == nil {
+		err = io.EOF
+	}
+	p.mu.Lock()
+	h := p.onSession
+	p.mu.Unlock()
+	if h != nil {
+		h(id, client, err)
+	}
+}
+
+// emit 触发帧捕获回调
+func (p *Proxy) emit(session uint64, dir TrafficDirection, raw []byte, pkt *codec.Packet, err error) {
+	p.mu.Lock()
+	h := p.onMessage
+	p.mu.Unlock()
+	if h != nil {
+		h(ProxyMessage{
+			Session:   session,
+			Direction: dir,
+			Time:      time.Now(),
+			Raw:       raw,
+			Packet:    pkt,
+			Err:       err,
+		})
+	}
+}
+
+// acceptTCP 接受客户端连接, 每个连接建立一条到网关的上游连接
+func (p *Proxy) acceptTCP(ln net.Listener) {
+	for {
+		client, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go p.serveTCP(client)
+	}
+}
+
+func (p *Proxy) serveTCP(client net.Conn) {
+	upstream, err := net.Dial("tcp", p.cfg.Upstream)
+	if err != nil {
+		client.Close()
+		p.endSession(p.newSession(client.RemoteAddr()), client.RemoteAddr(), fmt.Errorf("dial upstream: %w", err))
+		return
+	}
+	if !p.track(client, upstream) {
+		client.Close()
+		upstream.Close()
+		return
+	}
+
+	session := p.newSession(client.RemoteAddr())
+	errCh := make(chan error, 2)
+	go func() { errCh <- p.pipeTCP(session, TrafficSent, client, upstream) }()
+	go func() { errCh <- p.pipeTCP(session, TrafficReceived, upstream, client) }()
+
+	// 任一方向结束即关闭整条会话
+	err = <-errCh
+	client.Close()
+	upstream.Close()
+	<-errCh
+	p.untrack(client, upstream)
+	p.endSession(session, client.RemoteAddr(), err)
+}
+
+// pipeTCP 按帧读取 src 并原样写入 dst
+func (p *Proxy) pipeTCP(session uint64, dir TrafficDirection, src, dst net.Conn) error {
+	// 代理需要原样转发所有字节, 不能跳过数据重新同步; 流加密的密钥流在该方向上跨帧连续
+	cfg := p.cfg.PacketConfig.WithCipherSession()
+	cfg.Resync = nil
+	decoder := codec.NewDecoder(src, cfg)
+	for {
+		pkt, raw, err := decoder.DecodeFrame()
+		if len(raw) > 0 {
+			if _, werr := dst.Write(raw); werr != nil {
+				return werr
+			}
+		}
+		if err != nil {
+			if isConnClosedErr(err) {
+				return err
+			}
+			// 协议错误: 上报后退化为字节透传
+			p.emit(session, dir, raw, nil, err)
+			_, err = io.Copy(dst, src)
+			return err
+		}
+		p.emit(session, dir, raw, pkt, nil)
+	}
+}
+
+// isConnClosedErr 判断是否为连接关闭类错误(而非协议错误)
+func isConnClosedErr(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// handleWebSocket 升级客户端连接并以相同路径连接网关
+func (p *Proxy) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	u := url.URL{Scheme: "ws", Host: p.cfg.Upstream, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+	upstream, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("dial upstream: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	client, err := p.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	if !p.track(client, upstream) {
+		client.Close()
+		upstream.Close()
+		return
+	}
+
+	session := p.newSession(client.RemoteAddr())
+	errCh := make(chan error, 2)
+	go func() { errCh <- p.pipeWS(session, TrafficSent, client, upstream) }()
+	go func() { errCh <- p.pipeWS(session, TrafficReceived, upstream, client) }()
+
+	err = <-errCh
+	client.Close()
+	upstream.Close()
+	<-errCh
+	p.untrack(client, upstream)
+	p.endSession(session, client.RemoteAddr(), err)
+}
+
+// pipeWS 逐条转发 WebSocket 消息, 二进制消息按完整帧解码
+func (p *Proxy) pipeWS(session uint64, dir TrafficDirection, src, dst *websocket.Conn) error {
+	cfg := p.cfg.PacketConfig.WithCipherSession()
+	for {
+		msgType, msg, err := src.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if err := dst.WriteMessage(msgType, msg); err != nil {
+			return err
+		}
+		if msgType != websocket.BinaryMessage {
+			continue
+		}
+		pkt, err := codec.DecodeBytes(msg, cfg)
+		p.emit(session, dir, msg, pkt, err)
+	}
+}
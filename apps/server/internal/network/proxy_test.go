@@ -0,0 +1,150 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/flow-packet/server/internal/codec"
+)
+
+// startTestProxy 启动指向 upstream 的 TCP 代理, 捕获的消息写入返回的 channel
+func startTestProxy(t *testing.T, upstream string) (*Proxy, string, chan ProxyMessage) {
+	t.Helper()
+	proxy := NewProxy(ProxyConfig{
+		Protocol:     "tcp",
+		ListenAddr:   "127.0.0.1:0",
+		Upstream:     upstream,
+		PacketConfig: codec.DefaultPacketConfig(),
+	})
+	msgs := make(chan ProxyMessage, 16)
+	proxy.OnMessage(func(msg ProxyMessage) { msgs <- msg })
+
+	addr, err := proxy.Start()
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	return proxy, addr.String(), msgs
+}
+
+func waitProxyMessage(t *testing.T, msgs chan ProxyMessage) ProxyMessage {
+	t.Helper()
+	select {
+	case msg := <-msgs:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for proxy message")
+		return ProxyMessage{}
+	}
+}
+
+func TestProxyForwardsAndDecodes(t *testing.T) {
+	upstream, closeServer := startEchoServer(t)
+	defer closeServer()
+
+	proxy, addr, msgs := startTestProxy(t, upstream)
+	defer proxy.Stop()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	defer conn.Close()
+
+	frame := encodeTestFrame(t, 1001, 5, "hello")
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	echo := make([]byte, len(frame))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadFull(conn, echo); err != nil {
+		t.Fatalf("read echo: %v", err)
+	}
+	if !bytes.Equal(echo, frame) {
+		t.Fatalf("echo = %x, want %x", echo, frame)
+	}
+
+	sent := waitProxyMessage(t, msgs)
+	recv := waitProxyMessage(t, msgs)
+	if sent.Direction != TrafficSent || recv.Direction != TrafficReceived {
+		t.Fatalf("directions = %s/%s, want sent/received", sent.Direction, recv.Direction)
+	}
+	if sent.Packet == nil || sent.Packet.Route != 1001 || sent.Packet.Seq != 5 {
+		t.Fatalf("sent packet = %+v", sent.Packet)
+	}
+	if !bytes.Equal(recv.Raw, frame) || sent.Session != recv.Session {
+		t.Fatalf("recv = %+v", recv)
+	}
+}
+
+func TestProxyPassthroughOnDecodeError(t *testing.T) {
+	upstream, closeServer := startEchoServer(t)
+	defer closeServer()
+
+	proxy, addr, msgs := startTestProxy(t, upstream)
+	defer proxy.Stop()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	defer conn.Close()
+
+	// payload size 为 0 的非法帧, 之后的字节应被原样透传
+	data := append([]byte{0, 0, 0, 0}, "raw"...)
+	if _, err := conn.Write(data); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	echo := make([]byte, len(data))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadFull(conn, echo); err != nil {
+		t.Fatalf("read echo: %v", err)
+	}
+	if !bytes.Equal(echo, data) {
+		t.Fatalf("echo = %x, want %x", echo, data)
+	}
+
+	msg := waitProxyMessage(t, msgs)
+	if msg.Err == nil || msg.Packet != nil {
+		t.Fatalf("expected decode error, got %+v", msg)
+	}
+}
+
+func TestProxyStopClosesSessions(t *testing.T) {
+	upstream, closeServer := startEchoServer(t)
+	defer closeServer()
+
+	proxy, addr, _ := startTestProxy(t, upstream)
+	ended := make(chan error, 2)
+	proxy.OnSession(func(session uint64, client net.Addr, err error) {
+		if err != nil {
+			ended <- err
+		}
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	defer conn.Close()
+
+	// 等待会话建立后再停止
+	conn.Write(encodeTestFrame(t, 1, 1, ""))
+	time.Sleep(100 * time.Millisecond)
+
+	if err := proxy.Stop(); err != nil {
+		t.Fatalf("Stop error: %v", err)
+	}
+	select {
+	case <-ended:
+	case <-time.After(2 * time.Second):
+		t.Fatal("session did not end after Stop")
+	}
+	if proxy.Running() {
+		t.Fatal("proxy should not be running after Stop")
+	}
+}
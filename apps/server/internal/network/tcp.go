@@ -1,6 +1,8 @@
 package network
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"sync"
 
@@ -16,13 +18,19 @@ type TCPClient struct {
 	addr  string // 目标地址, 用于重连
 
 	packetCfg    codec.PacketConfig
+	session      connConfig // 当前连接的协议帧配置
+	cfgVersion   uint64     // 每次更新 session 递增, 读循环据此更新解码器
 	reconnectCfg ReconnectConfig
 	reconnector  *Reconnector
+	recorder     *TrafficRecorder
+	faultCfg     FaultConfig
 
 	connectHandler    ConnectHandler
 	disconnectHandler DisconnectHandler
 	receiveHandler    ReceiveHandler
+	protocolHandler   ProtocolErrorHandler
 
+	sendMu sync.Mutex // 保证帧的编码顺序与写入顺序一致
 	sendCh chan []byte
 	done   chan struct{}
 }
@@ -37,9 +45,30 @@ func NewTCPClient(cfg codec.PacketConfig) *TCPClient {
 	}
 }
 
-// SetPacketConfig 动态更新协议帧配置
+// SetPacketConfig 动态更新协议帧配置, 已建立的连接从下一帧开始使用
 func (c *TCPClient) SetPacketConfig(cfg codec.PacketConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.packetCfg = cfg
+	c.session.update(cfg)
+	c.cfgVersion++
+}
+
+// config 返回当前连接的协议帧配置及其版本
+func (c *TCPClient) config() (codec.PacketConfig, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.session.cfg, c.cfgVersion
+}
+
+// SetRecorder 设置流量记录器, 为 nil 时不记录
+func (c *TCPClient) SetRecorder(rec *TrafficRecorder) {
+	c.recorder = rec
+}
+
+// SetFaultConfig 设置故障注入配置, 下次建立连接时生效
+func (c *TCPClient) SetFaultConfig(cfg FaultConfig) {
+	c.faultCfg = cfg
 }
 
 // SetReconnectConfig 设置重连配置
@@ -64,11 +93,16 @@ func (c *TCPClient) Connect(addr string) error {
 		c.mu.Unlock()
 		return err
 	}
+	if c.faultCfg.Enabled() {
+		conn = NewFaultConn(conn, c.faultCfg)
+	}
 
 	c.mu.Lock()
 	c.conn = conn
 	c.addr = addr
 	c.state = ConnStateConnected
+	c.session = newConnConfig(c.packetCfg)
+	c.cfgVersion++
 	c.done = make(chan struct{})
 	// drain sendCh from previous session
 	c.drainSendCh()
@@ -76,6 +110,10 @@ func (c *TCPClient) Connect(addr string) error {
 
 	tcpConn := &tcpConnWrapper{conn: conn}
 
+	if c.recorder != nil {
+		c.recorder.SetEndpoints(tcpConn.LocalAddr(), tcpConn.RemoteAddr())
+	}
+
 	if h := c.connectHandler; h != nil {
 		h(tcpConn)
 	}
@@ -133,6 +171,20 @@ func (c *TCPClient) Send(data []byte) error {
 	}
 }
 
+// SendPacket 按连接当前的协议帧配置编码并发送数据包
+//
+// 流加密的密钥流跨帧连续, 编码与入队在同一把锁内完成, 保证帧的加密顺序与写入顺序一致
+func (c *TCPClient) SendPacket(pkt *codec.Packet) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	cfg, _ := c.config()
+	data, err := codec.Encode(pkt, cfg)
+	if err != nil {
+		return fmt.Errorf("frame encode: %w", err)
+	}
+	return c.Send(data)
+}
+
 // State 获取当前连接状态
 func (c *TCPClient) State() ConnState {
 	c.mu.RLock()
@@ -155,10 +207,15 @@ func (c *TCPClient) OnReceive(handler ReceiveHandler) {
 	c.receiveHandler = handler
 }
 
-// readLoop 读 goroutine, 使用 codec.Decoder 解码帧
+// OnProtocolError 注册协议错误回调
+func (c *TCPClient) OnProtocolError(handler ProtocolErrorHandler) {
+	c.protocolHandler = handler
+}
+
+// readLoop 读 goroutine, 使用 codec.Decoder 解码帧, 接收回调直接获得解码后的数据包
 func (c *TCPClient) readLoop(conn *tcpConnWrapper) {
-	decoder := codec.NewDecoder(conn.conn, c.packetCfg)
-	pomelo := c.packetCfg.IsPomelo()
+	cfg, version := c.config()
+	decoder := codec.NewDecoder(conn.conn, cfg)
 
 	for {
 		select {
@@ -167,27 +224,34 @@ func (c *TCPClient) readLoop(conn *tcpConnWrapper) {
 		default:
 		}
 
-		var data []byte
-		var err error
-
-		if pomelo {
-			// Pomelo 模式: 直接透传原始字节, 避免 decode-reencode 丢失控制包信息
-			data, err = decoder.DecodeRaw()
-		} else {
-			var pkt *codec.Packet
-			pkt, err = decoder.Decode()
-			if err == nil {
-				data, err = codec.Encode(pkt, c.packetCfg)
-			}
+		// 密钥交换和 Pomelo 握手在接收回调中更新配置, 对下一帧生效
+		if cfg, v := c.config(); v != version {
+			decoder.SetConfig(cfg)
+			version = v
 		}
 
+		pkt, raw, err := decoder.DecodeFrame()
 		if err != nil {
+			if codec.IsProtocolError(err) {
+				if h := c.protocolHandler; h != nil {
+					h(conn, err)
+				}
+				// 已重新同步, 继续读取下一帧
+				var resyncErr *codec.ResyncError
+				if errors.As(err, &resyncErr) {
+					continue
+				}
+			}
 			c.handleDisconnect(conn, err)
 			return
 		}
 
+		if c.recorder != nil {
+			c.recorder.Record(TrafficReceived, raw)
+		}
+
 		if h := c.receiveHandler; h != nil {
-			h(conn, data)
+			h(conn, pkt)
 		}
 	}
 }
@@ -199,6 +263,10 @@ func (c *TCPClient) writeLoop(conn *tcpConnWrapper) {
 		case <-c.done:
 			return
 		case data := <-c.sendCh:
+			// 写入前记录, 保证发送帧先于其响应出现在记录中
+			if c.recorder != nil {
+				c.recorder.Record(TrafficSent, data)
+			}
 			if _, err := conn.Write(data); err != nil {
 				c.handleDisconnect(conn, err)
 				return
@@ -2,6 +2,7 @@ package network
 
 import (
 	"bytes"
+	"errors"
 	"net"
 	"sync"
 	"testing"
@@ -185,11 +186,9 @@ func TestTCPClientSendReceive(t *testing.T) {
 	cfg := codec.DefaultPacketConfig()
 	client := NewTCPClient(cfg)
 
-	received := make(chan []byte, 1)
-	client.OnReceive(func(conn Conn, data []byte) {
-		cp := make([]byte, len(data))
-		copy(cp, data)
-		received <- cp
+	received := make(chan *codec.Packet, 1)
+	client.OnReceive(func(conn Conn, pkt *codec.Packet) {
+		received <- pkt
 	})
 
 	if err := client.Connect(addr); err != nil {
@@ -210,9 +209,9 @@ func TestTCPClientSendReceive(t *testing.T) {
 
 	// 等待接收回调触发
 	select {
-	case data := <-received:
-		if !bytes.Equal(data, frame) {
-			t.Fatalf("received data mismatch:\n  got:  %v\n  want: %v", data, frame)
+	case got := <-received:
+		if got.Route != pkt.Route || got.Seq != pkt.Seq || !bytes.Equal(got.Data, pkt.Data) {
+			t.Fatalf("received packet mismatch:\n  got:  %+v\n  want: %+v", got, pkt)
 		}
 	case <-time.After(2 * time.Second):
 		t.Fatal("timeout waiting for receive callback")
@@ -231,11 +230,9 @@ func TestTCPClientReceiveFromServer(t *testing.T) {
 
 	client := NewTCPClient(cfg)
 
-	received := make(chan []byte, 1)
-	client.OnReceive(func(conn Conn, data []byte) {
-		cp := make([]byte, len(data))
-		copy(cp, data)
-		received <- cp
+	received := make(chan *codec.Packet, 1)
+	client.OnReceive(func(conn Conn, pkt *codec.Packet) {
+		received <- pkt
 	})
 
 	if err := client.Connect(addr); err != nil {
@@ -244,15 +241,122 @@ func TestTCPClientReceiveFromServer(t *testing.T) {
 	defer client.Disconnect()
 
 	select {
-	case data := <-received:
-		if !bytes.Equal(data, hbFrame) {
-			t.Fatalf("received heartbeat mismatch:\n  got:  %v\n  want: %v", data, hbFrame)
+	case got := <-received:
+		if !got.Heartbeat {
+			t.Fatalf("received packet = %+v, want heartbeat", got)
 		}
 	case <-time.After(2 * time.Second):
 		t.Fatal("timeout waiting for heartbeat receive")
 	}
 }
 
+func TestTCPClientConfigChangeAppliesToNextFrame(t *testing.T) {
+	cfg := codec.DefaultPacketConfig()
+	zcfg := cfg
+	zcfg.Compression = &codec.CompressionConfig{Algorithm: codec.CompressZlib}
+
+	// 第一帧明文, 第二帧按回调中切换后的配置压缩
+	first, _ := codec.Encode(&codec.Packet{Route: 1, Data: []byte("plain")}, cfg)
+	second, _ := codec.Encode(&codec.Packet{Route: 2, Data: []byte("zipped")}, zcfg)
+	addr, closeServer := startSendServer(t, append(first, second...))
+	defer closeServer()
+
+	client := NewTCPClient(cfg)
+	received := make(chan *codec.Packet, 2)
+	client.OnReceive(func(conn Conn, pkt *codec.Packet) {
+		if pkt.Route == 1 {
+			client.SetPacketConfig(zcfg)
+		}
+		received <- pkt
+	})
+	if err := client.Connect(addr); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer client.Disconnect()
+
+	for _, want := range []string{"plain", "zipped"} {
+		select {
+		case pkt := <-received:
+			if string(pkt.Data) != want {
+				t.Fatalf("body = %q, want %q", pkt.Data, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+	}
+}
+
+func TestTCPClientCipherStreamSpansFrames(t *testing.T) {
+	cfg := codec.DefaultPacketConfig()
+	cfg.Cipher = &codec.CipherConfig{Algorithm: codec.CipherAESCTR, Key: []byte("0123456789abcdef")}
+	pkt := &codec.Packet{Route: 1001, Data: []byte("same body")}
+
+	// 期望的密文: 同一条密钥流依次加密两帧
+	session := cfg.WithCipherSession()
+	var want [2][]byte
+	for i := range want {
+		want[i], _ = codec.Encode(pkt, session)
+	}
+	if bytes.Equal(want[0], want[1]) {
+		t.Fatal("expected frames to differ when the keystream continues")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	defer ln.Close()
+	frames := make(chan []byte, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		decoder := codec.NewDecoder(conn, codec.DefaultPacketConfig())
+		for i := 0; i < 2; i++ {
+			raw, err := decoder.DecodeRaw()
+			if err != nil {
+				return
+			}
+			frames <- raw
+			// 原样回显, 客户端按解密密钥流依次解密
+			conn.Write(raw)
+		}
+		time.Sleep(time.Second)
+	}()
+
+	client := NewTCPClient(cfg)
+	received := make(chan *codec.Packet, 2)
+	client.OnReceive(func(conn Conn, pkt *codec.Packet) { received <- pkt })
+	if err := client.Connect(ln.Addr().String()); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer client.Disconnect()
+
+	for i := range want {
+		if err := client.SendPacket(pkt); err != nil {
+			t.Fatalf("SendPacket error: %v", err)
+		}
+		select {
+		case raw := <-frames:
+			if !bytes.Equal(raw, want[i]) {
+				t.Fatalf("frame %d = %x, want %x", i, raw, want[i])
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for frame %d", i)
+		}
+		select {
+		case got := <-received:
+			if !bytes.Equal(got.Data, pkt.Data) {
+				t.Fatalf("echo %d body = %q, want %q", i, got.Data, pkt.Data)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for echo %d", i)
+		}
+	}
+}
+
 func TestTCPClientConnectCallback(t *testing.T) {
 	addr, closeServer := startEchoServer(t)
 	defer closeServer()
@@ -337,3 +441,42 @@ func TestTCPClientImplementsInterface(t *testing.T) {
 	// 编译期验证 TCPClient 实现了 Client 接口
 	var _ Client = (*TCPClient)(nil)
 }
+
+func TestTCPClientProtocolError(t *testing.T) {
+	cfg := codec.DefaultPacketConfig()
+	cfg.MaxFrameSize = 64
+
+	// 第二帧声明的长度超出上限
+	bad := []byte{0x00, 0x01, 0x00, 0x00}
+	data := append(encodeTestFrame(t, 1, 1, "ok"), bad...)
+	addr, closeServer := startSendServer(t, data)
+	defer closeServer()
+
+	client := NewTCPClient(cfg)
+	client.SetReconnectConfig(ReconnectConfig{})
+	protoErrs := make(chan error, 1)
+	disconnected := make(chan struct{})
+	client.OnProtocolError(func(conn Conn, err error) { protoErrs <- err })
+	client.OnDisconnect(func(conn Conn, err error) { close(disconnected) })
+
+	if err := client.Connect(addr); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer client.Disconnect()
+
+	select {
+	case err := <-protoErrs:
+		if !errors.Is(err, codec.ErrFrameTooLarge) {
+			t.Fatalf("protocol error = %v, want ErrFrameTooLarge", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("protocol error not reported")
+	}
+
+	// 未配置重新同步时断开连接
+	select {
+	case <-disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("client should disconnect after protocol error")
+	}
+}
@@ -0,0 +1,218 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/flow-packet/server/internal/codec"
+)
+
+// DefaultTrafficCapacity 流量记录环形缓冲区默认容量(帧数)
+const DefaultTrafficCapacity = 10000
+
+// TrafficDirection 帧传输方向
+type TrafficDirection string
+
+const (
+	TrafficSent     TrafficDirection = "sent"     // 客户端 → 服务端
+	TrafficReceived TrafficDirection = "received" // 服务端 → 客户端
+)
+
+// TrafficHeader 按当前协议帧配置解码出的帧头摘要
+type TrafficHeader struct {
+	Heartbeat   bool   `json:"heartbeat,omitempty"`
+	ExtCode     uint8  `json:"extCode,omitempty"`
+	Route       uint64 `json:"route"`
+	Seq         uint64 `json:"seq"`
+	StringRoute string `json:"stringRoute,omitempty"`
+	BodySize    int    `json:"bodySize"`
+	// Fields 字段驱动模式下的自定义帧头字段值
+	Fields map[string]uint64 `json:"fields,omitempty"`
+}
+
+// TrafficFrame 一条被记录的帧
+type TrafficFrame struct {
+	ID        uint64           `json:"id"`
+	Direction TrafficDirection `json:"direction"`
+	Time      time.Time        `json:"time"`
+	Raw       []byte           `json:"-"`
+	Header    *TrafficHeader   `json:"header,omitempty"`
+	Error     string           `json:"error,omitempty"` // 帧头解码失败原因
+	// Packet 记录时按连接的密钥流解码出的数据包; 流加密的帧无法脱离前序帧单独解码
+	Packet *codec.Packet `json:"-"`
+}
+
+// MarshalJSON 原始字节以十六进制字符串输出, 与 DynamicDecode 的 _hex 保持一致
+func (f TrafficFrame) MarshalJSON() ([]byte, error) {
+	type alias TrafficFrame
+	return json.Marshal(struct {
+		alias
+		Raw string `json:"raw"`
+	}{alias(f), hex.EncodeToString(f.Raw)})
+}
+
+// UnmarshalJSON 解析 MarshalJSON 输出的格式
+func (f *TrafficFrame) UnmarshalJSON(data []byte) error {
+	type alias TrafficFrame
+	var v struct {
+		alias
+		Raw string `json:"raw"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	raw, err := hex.DecodeString(v.Raw)
+	if err != nil {
+		return err
+	}
+	*f = TrafficFrame(v.alias)
+	f.Raw = raw
+	return nil
+}
+
+// TrafficRecorder 流量记录器, 以环形缓冲区保存最近收发的帧
+type TrafficRecorder struct {
+	mu        sync.Mutex
+	packetCfg codec.PacketConfig
+	sent      connConfig // 解码发送帧, 密钥流与客户端的加密流同步
+	received  connConfig // 解码接收帧
+	frames    []TrafficFrame
+	head      int // 最早一帧在 frames 中的位置
+	size      int
+	nextID    uint64
+	onFrame   func(frame TrafficFrame)
+
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+// NewTrafficRecorder 创建流量记录器, capacity <= 0 时使用默认容量
+func NewTrafficRecorder(capacity int, cfg codec.PacketConfig) *TrafficRecorder {
+	if capacity <= 0 {
+		capacity = DefaultTrafficCapacity
+	}
+	return &TrafficRecorder{
+		packetCfg: cfg,
+		sent:      newConnConfig(cfg),
+		received:  newConnConfig(cfg),
+		frames:    make([]TrafficFrame, capacity),
+		nextID:    1,
+	}
+}
+
+// SetPacketConfig 动态更新用于解码帧头的协议帧配置
+func (r *TrafficRecorder) SetPacketConfig(cfg codec.PacketConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.packetCfg = cfg
+	r.sent.update(cfg)
+	r.received.update(cfg)
+}
+
+// OnFrame 注册新帧回调, 用于实时推送
+func (r *TrafficRecorder) OnFrame(fn func(frame TrafficFrame)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onFrame = fn
+}
+
+// SetEndpoints 记录当前连接的两端地址, 导出 pcapng 时使用; 新连接的密钥流从头开始
+func (r *TrafficRecorder) SetEndpoints(local, remote net.Addr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.localAddr = local
+	r.remoteAddr = remote
+	r.sent = newConnConfig(r.packetCfg)
+	r.received = newConnConfig(r.packetCfg)
+}
+
+// Endpoints 返回最近一次连接的两端地址
+func (r *TrafficRecorder) Endpoints() (local, remote net.Addr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.localAddr, r.remoteAddr
+}
+
+// Record 记录一帧, 缓冲区满时覆盖最早的帧
+func (r *TrafficRecorder) Record(dir TrafficDirection, data []byte) TrafficFrame {
+	raw := make([]byte, len(data))
+	copy(raw, data)
+
+	r.mu.Lock()
+	frame := TrafficFrame{
+		ID:        r.nextID,
+		Direction: dir,
+		Time:      time.Now(),
+		Raw:       raw,
+	}
+	r.nextID++
+
+	cfg := r.received.cfg
+	if dir == TrafficSent {
+		cfg = r.sent.cfg
+	}
+	if pkt, err := codec.DecodeBytes(raw, cfg); err != nil {
+		frame.Error = err.Error()
+	} else {
+		frame.Packet = pkt
+		frame.Header = &TrafficHeader{
+			Heartbeat:   pkt.Heartbeat,
+			ExtCode:     pkt.ExtCode,
+			Route:       pkt.Route,
+			Seq:         pkt.Seq,
+			StringRoute: pkt.StringRoute,
+			BodySize:    len(pkt.Data),
+			Fields:      pkt.Fields,
+		}
+	}
+
+	capacity := len(r.frames)
+	if r.size < capacity {
+		r.frames[(r.head+r.size)%capacity] = frame
+		r.size++
+	} else {
+		r.frames[r.head] = frame
+		r.head = (r.head + 1) % capacity
+	}
+	fn := r.onFrame
+	r.mu.Unlock()
+
+	if fn != nil {
+		fn(frame)
+	}
+	return frame
+}
+
+// List 返回 ID 大于 afterID 的帧(从旧到新), limit <= 0 表示不限制数量
+func (r *TrafficRecorder) List(afterID uint64, limit int) []TrafficFrame {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]TrafficFrame, 0)
+	capacity := len(r.frames)
+	for i := 0; i < r.size; i++ {
+		f := r.frames[(r.head+i)%capacity]
+		if f.ID <= afterID {
+			continue
+		}
+		result = append(result, f)
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+	}
+	return result
+}
+
+// Clear 清空已记录的帧, ID 继续递增
+func (r *TrafficRecorder) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.head = 0
+	r.size = 0
+	for i := range r.frames {
+		r.frames[i] = TrafficFrame{}
+	}
+}
@@ -0,0 +1,262 @@
+package network
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"time"
+)
+
+// WriteTrafficJSONLines 以 JSON Lines 格式导出帧, 每行一帧
+func WriteTrafficJSONLines(w io.Writer, frames []TrafficFrame) error {
+	enc := json.NewEncoder(w)
+	for _, f := range frames {
+		if err := enc.Encode(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadTrafficJSONLines 读取 WriteTrafficJSONLines 导出的帧
+func ReadTrafficJSONLines(r io.Reader) ([]TrafficFrame, error) {
+	dec := json.NewDecoder(r)
+	var frames []TrafficFrame
+	for {
+		var f TrafficFrame
+		if err := dec.Decode(&f); err != nil {
+			if err == io.EOF {
+				return frames, nil
+			}
+			return nil, err
+		}
+		frames = append(frames, f)
+	}
+}
+
+// pcapng 块类型与常量
+const (
+	pcapngBlockSHB     uint32 = 0x0A0D0D0A
+	pcapngBlockIDB     uint32 = 0x00000001
+	pcapngBlockEPB     uint32 = 0x00000006
+	pcapngByteOrder    uint32 = 0x1A2B3C4D
+	pcapngLinkEthernet uint16 = 1
+
+	// pcapMSS 合成 TCP 报文的最大分段长度, 大帧会拆成多个报文段
+	pcapMSS = 1460
+)
+
+// TCP 标志位
+const (
+	tcpFlagSYN = 0x02
+	tcpFlagPSH = 0x08
+	tcpFlagACK = 0x10
+)
+
+// 地址不可用时使用的合成端点
+var (
+	pcapDefaultClient = &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 50000}
+	pcapDefaultServer = &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 8000}
+)
+
+// WriteTrafficPcapng 以 pcapng 格式导出帧, 每帧封装在合成的 Ethernet/IPv4/TCP 报文中,
+// 可直接用 Wireshark 打开并按 TCP 流重组
+//
+// 参数：
+//   - client, server: 连接两端地址; 为 nil 或非 IPv4 时使用合成地址
+func WriteTrafficPcapng(w io.Writer, frames []TrafficFrame, client, server net.Addr) error {
+	pw := &pcapWriter{
+		w:      w,
+		client: pcapEndpoint(client, pcapDefaultClient),
+		server: pcapEndpoint(server, pcapDefaultServer),
+		// 初始序列号任意取值, Wireshark 默认显示相对序列号
+		clientSeq: 1000,
+		serverSeq: 5000,
+	}
+
+	if err := pw.writeSectionHeader(); err != nil {
+		return err
+	}
+	if err := pw.writeInterface(); err != nil {
+		return err
+	}
+
+	if len(frames) == 0 {
+		return nil
+	}
+
+	// 合成三次握手, 使抓包工具识别完整的 TCP 会话
+	start := frames[0].Time
+	if err := pw.writeSegment(start, true, tcpFlagSYN, nil); err != nil {
+		return err
+	}
+	pw.clientSeq++
+	if err := pw.writeSegment(start, false, tcpFlagSYN|tcpFlagACK, nil); err != nil {
+		return err
+	}
+	pw.serverSeq++
+	if err := pw.writeSegment(start, true, tcpFlagACK, nil); err != nil {
+		return err
+	}
+
+	for _, f := range frames {
+		fromClient := f.Direction == TrafficSent
+		data := f.Raw
+		for len(data) > 0 {
+			n := len(data)
+			if n > pcapMSS {
+				n = pcapMSS
+			}
+			if err := pw.writeSegment(f.Time, fromClient, tcpFlagPSH|tcpFlagACK, data[:n]); err != nil {
+				return err
+			}
+			if fromClient {
+				pw.clientSeq += uint32(n)
+			} else {
+				pw.serverSeq += uint32(n)
+			}
+			data = data[n:]
+		}
+	}
+	return nil
+}
+
+// pcapEndpoint 将 net.Addr 转换为 IPv4 TCP 地址, 无法转换时返回 fallback
+func pcapEndpoint(addr net.Addr, fallback *net.TCPAddr) *net.TCPAddr {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok || tcpAddr == nil || tcpAddr.IP.To4() == nil {
+		return fallback
+	}
+	return &net.TCPAddr{IP: tcpAddr.IP.To4(), Port: tcpAddr.Port}
+}
+
+// pcapWriter pcapng 写入器, 维护两个方向的 TCP 序列号
+type pcapWriter struct {
+	w         io.Writer
+	client    *net.TCPAddr
+	server    *net.TCPAddr
+	clientSeq uint32
+	serverSeq uint32
+}
+
+// writeBlock 写入一个 pcapng 块: type + length + body(4 字节对齐) + length
+func (p *pcapWriter) writeBlock(blockType uint32, body []byte) error {
+	padded := (len(body) + 3) &^ 3
+	total := 12 + padded
+	buf := make([]byte, total)
+	binary.LittleEndian.PutUint32(buf[0:4], blockType)
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(total))
+	copy(buf[8:], body)
+	binary.LittleEndian.PutUint32(buf[total-4:], uint32(total))
+	_, err := p.w.Write(buf)
+	return err
+}
+
+// writeSectionHeader 写入 Section Header Block
+func (p *pcapWriter) writeSectionHeader() error {
+	body := make([]byte, 16)
+	binary.LittleEndian.PutUint32(body[0:4], pcapngByteOrder)
+	binary.LittleEndian.PutUint16(body[4:6], 1) // major version
+	binary.LittleEndian.PutUint16(body[6:8], 0) // minor version
+	// section length 未知
+	binary.LittleEndian.PutUint64(body[8:16], 0xFFFFFFFFFFFFFFFF)
+	return p.writeBlock(pcapngBlockSHB, body)
+}
+
+// writeInterface 写入 Interface Description Block, 时间戳精度使用默认的微秒
+func (p *pcapWriter) writeInterface() error {
+	body := make([]byte, 8)
+	binary.LittleEndian.PutUint16(body[0:2], pcapngLinkEthernet)
+	binary.LittleEndian.PutUint32(body[4:8], 0) // snaplen 不限制
+	return p.writeBlock(pcapngBlockIDB, body)
+}
+
+// writeSegment 写入一个携带 TCP 报文的 Enhanced Packet Block
+func (p *pcapWriter) writeSegment(ts time.Time, fromClient bool, flags byte, payload []byte) error {
+	src, dst := p.server, p.client
+	seq, ack := p.serverSeq, p.clientSeq
+	if fromClient {
+		src, dst = p.client, p.server
+		seq, ack = p.clientSeq, p.serverSeq
+	}
+	if flags&tcpFlagACK == 0 {
+		ack = 0
+	}
+
+	packet := buildEthernetFrame(fromClient, src, dst, seq, ack, flags, payload)
+
+	body := make([]byte, 20+len(packet))
+	us := uint64(ts.UnixMicro())
+	binary.LittleEndian.PutUint32(body[0:4], 0) // interface id
+	binary.LittleEndian.PutUint32(body[4:8], uint32(us>>32))
+	binary.LittleEndian.PutUint32(body[8:12], uint32(us))
+	binary.LittleEndian.PutUint32(body[12:16], uint32(len(packet))) // captured length
+	binary.LittleEndian.PutUint32(body[16:20], uint32(len(packet))) // original length
+	copy(body[20:], packet)
+	return p.writeBlock(pcapngBlockEPB, body)
+}
+
+// buildEthernetFrame 构造 Ethernet + IPv4 + TCP 报文
+func buildEthernetFrame(fromClient bool, src, dst *net.TCPAddr, seq, ack uint32, flags byte, payload []byte) []byte {
+	const ethLen, ipLen, tcpLen = 14, 20, 20
+	buf := make([]byte, ethLen+ipLen+tcpLen+len(payload))
+
+	// Ethernet: 合成的本地管理 MAC 地址, 客户端 02:..:01, 服务端 02:..:02
+	clientMAC := []byte{0x02, 0, 0, 0, 0, 0x01}
+	serverMAC := []byte{0x02, 0, 0, 0, 0, 0x02}
+	if fromClient {
+		copy(buf[0:6], serverMAC)
+		copy(buf[6:12], clientMAC)
+	} else {
+		copy(buf[0:6], clientMAC)
+		copy(buf[6:12], serverMAC)
+	}
+	binary.BigEndian.PutUint16(buf[12:14], 0x0800)
+
+	// IPv4
+	ip := buf[ethLen : ethLen+ipLen]
+	ip[0] = 0x45 // version 4, IHL 5
+	binary.BigEndian.PutUint16(ip[2:4], uint16(ipLen+tcpLen+len(payload)))
+	binary.BigEndian.PutUint16(ip[6:8], 0x4000) // don't fragment
+	ip[8] = 64                                  // TTL
+	ip[9] = 6                                   // TCP
+	copy(ip[12:16], src.IP.To4())
+	copy(ip[16:20], dst.IP.To4())
+	binary.BigEndian.PutUint16(ip[10:12], internetChecksum(ip, 0))
+
+	// TCP
+	tcp := buf[ethLen+ipLen:]
+	binary.BigEndian.PutUint16(tcp[0:2], uint16(src.Port))
+	binary.BigEndian.PutUint16(tcp[2:4], uint16(dst.Port))
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	binary.BigEndian.PutUint32(tcp[8:12], ack)
+	tcp[12] = (tcpLen / 4) << 4
+	tcp[13] = flags
+	binary.BigEndian.PutUint16(tcp[14:16], 0xFFFF) // window
+	copy(tcp[tcpLen:], payload)
+
+	// TCP 校验和包含伪首部: src ip + dst ip + zero + proto + tcp length
+	var pseudo uint32
+	pseudo += uint32(binary.BigEndian.Uint16(ip[12:14])) + uint32(binary.BigEndian.Uint16(ip[14:16]))
+	pseudo += uint32(binary.BigEndian.Uint16(ip[16:18])) + uint32(binary.BigEndian.Uint16(ip[18:20]))
+	pseudo += 6 + uint32(len(tcp))
+	binary.BigEndian.PutUint16(tcp[16:18], internetChecksum(tcp, pseudo))
+
+	return buf
+}
+
+// internetChecksum 计算 RFC 1071 校验和, initial 为预先累加的部分和
+func internetChecksum(data []byte, initial uint32) uint16 {
+	sum := initial
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xFFFF) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
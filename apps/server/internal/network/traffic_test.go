@@ -0,0 +1,275 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/flow-packet/server/internal/codec"
+)
+
+func encodeTestFrame(t *testing.T, route, seq uint64, data string) []byte {
+	t.Helper()
+	frame, err := codec.Encode(&codec.Packet{Route: route, Seq: seq, Data: []byte(data)}, codec.DefaultPacketConfig())
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	return frame
+}
+
+func TestTrafficRecorderDecodesHeader(t *testing.T) {
+	rec := NewTrafficRecorder(8, codec.DefaultPacketConfig())
+	frame := rec.Record(TrafficSent, encodeTestFrame(t, 1001, 7, "abc"))
+
+	if frame.ID != 1 || frame.Direction != TrafficSent {
+		t.Fatalf("frame id/direction = %d/%s, want 1/sent", frame.ID, frame.Direction)
+	}
+	if frame.Header == nil {
+		t.Fatalf("header not decoded: %s", frame.Error)
+	}
+	if frame.Header.Route != 1001 || frame.Header.Seq != 7 || frame.Header.BodySize != 3 {
+		t.Fatalf("header = %+v, want route 1001 seq 7 body 3", frame.Header)
+	}
+
+	bad := rec.Record(TrafficReceived, []byte{0x01})
+	if bad.Header != nil || bad.Error == "" {
+		t.Fatal("short frame should record a decode error")
+	}
+}
+
+func TestTrafficRecorderRingBuffer(t *testing.T) {
+	rec := NewTrafficRecorder(3, codec.DefaultPacketConfig())
+	for i := 0; i < 5; i++ {
+		rec.Record(TrafficSent, encodeTestFrame(t, uint64(i), 0, ""))
+	}
+
+	frames := rec.List(0, 0)
+	if len(frames) != 3 {
+		t.Fatalf("frames len = %d, want 3", len(frames))
+	}
+	for i, f := range frames {
+		if f.ID != uint64(i+3) {
+			t.Fatalf("frames[%d].ID = %d, want %d", i, f.ID, i+3)
+		}
+	}
+
+	after := rec.List(3, 1)
+	if len(after) != 1 || after[0].ID != 4 {
+		t.Fatalf("List(3, 1) = %+v, want frame 4", after)
+	}
+
+	rec.Clear()
+	if len(rec.List(0, 0)) != 0 {
+		t.Fatal("frames should be empty after Clear")
+	}
+	if f := rec.Record(TrafficSent, nil); f.ID != 6 {
+		t.Fatalf("ID after Clear = %d, want 6", f.ID)
+	}
+}
+
+func TestTrafficRecorderOnFrame(t *testing.T) {
+	rec := NewTrafficRecorder(0, codec.DefaultPacketConfig())
+	var got []TrafficFrame
+	rec.OnFrame(func(frame TrafficFrame) { got = append(got, frame) })
+
+	rec.Record(TrafficSent, encodeTestFrame(t, 1, 1, ""))
+	rec.Record(TrafficReceived, encodeTestFrame(t, 1, 1, ""))
+
+	if len(got) != 2 || got[1].Direction != TrafficReceived {
+		t.Fatalf("OnFrame calls = %+v", got)
+	}
+}
+
+func TestTrafficJSONLinesRoundTrip(t *testing.T) {
+	rec := NewTrafficRecorder(0, codec.DefaultPacketConfig())
+	rec.Record(TrafficSent, encodeTestFrame(t, 1001, 1, "ping"))
+	rec.Record(TrafficReceived, encodeTestFrame(t, 1001, 1, "pong"))
+
+	var buf bytes.Buffer
+	if err := WriteTrafficJSONLines(&buf, rec.List(0, 0)); err != nil {
+		t.Fatalf("WriteTrafficJSONLines error: %v", err)
+	}
+	if lines := bytes.Count(buf.Bytes(), []byte("\n")); lines != 2 {
+		t.Fatalf("lines = %d, want 2", lines)
+	}
+
+	frames, err := ReadTrafficJSONLines(&buf)
+	if err != nil {
+		t.Fatalf("ReadTrafficJSONLines error: %v", err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("frames len = %d, want 2", len(frames))
+	}
+	if !bytes.Equal(frames[1].Raw, encodeTestFrame(t, 1001, 1, "pong")) {
+		t.Fatalf("raw mismatch: %x", frames[1].Raw)
+	}
+	if frames[0].Header == nil || frames[0].Header.Route != 1001 {
+		t.Fatalf("header not preserved: %+v", frames[0].Header)
+	}
+}
+
+func TestTrafficPcapngFormat(t *testing.T) {
+	big := make([]byte, pcapMSS*2+10)
+	frames := []TrafficFrame{
+		{ID: 1, Direction: TrafficSent, Time: time.Unix(1700000000, 0), Raw: []byte("hello")},
+		{ID: 2, Direction: TrafficReceived, Time: time.Unix(1700000001, 0), Raw: big},
+	}
+	server := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9000}
+
+	var buf bytes.Buffer
+	if err := WriteTrafficPcapng(&buf, frames, nil, server); err != nil {
+		t.Fatalf("WriteTrafficPcapng error: %v", err)
+	}
+
+	// 遍历所有块, 校验首尾长度一致
+	data := buf.Bytes()
+	var types []uint32
+	var packets [][]byte
+	for off := 0; off < len(data); {
+		blockType := binary.LittleEndian.Uint32(data[off:])
+		total := int(binary.LittleEndian.Uint32(data[off+4:]))
+		if total%4 != 0 || off+total > len(data) {
+			t.Fatalf("invalid block length %d at offset %d", total, off)
+		}
+		if trailer := int(binary.LittleEndian.Uint32(data[off+total-4:])); trailer != total {
+			t.Fatalf("block trailer length %d != %d", trailer, total)
+		}
+		types = append(types, blockType)
+		if blockType == pcapngBlockEPB {
+			capLen := int(binary.LittleEndian.Uint32(data[off+20:]))
+			packets = append(packets, data[off+28:off+28+capLen])
+		}
+		off += total
+	}
+
+	if types[0] != pcapngBlockSHB || types[1] != pcapngBlockIDB {
+		t.Fatalf("leading blocks = %x, want SHB + IDB", types[:2])
+	}
+	// 三次握手 3 个 + 小帧 1 个 + 大帧拆分 3 个
+	if len(packets) != 7 {
+		t.Fatalf("packet blocks = %d, want 7", len(packets))
+	}
+
+	payload := packets[3]
+	ip := payload[14:34]
+	if internetChecksum(ip, 0) != 0 {
+		t.Fatal("invalid IPv4 header checksum")
+	}
+	if dst := net.IP(ip[16:20]); !dst.Equal(server.IP) {
+		t.Fatalf("dst ip = %v, want %v", dst, server.IP)
+	}
+	tcp := payload[34:]
+	if port := binary.BigEndian.Uint16(tcp[2:4]); port != 9000 {
+		t.Fatalf("dst port = %d, want 9000", port)
+	}
+	if !bytes.Equal(tcp[20:], []byte("hello")) {
+		t.Fatalf("tcp payload = %q, want hello", tcp[20:])
+	}
+
+	// 服务端方向的序列号应连续递增
+	seq1 := binary.BigEndian.Uint32(packets[4][34+4:])
+	seq2 := binary.BigEndian.Uint32(packets[5][34+4:])
+	if seq2-seq1 != pcapMSS {
+		t.Fatalf("segment seq delta = %d, want %d", seq2-seq1, pcapMSS)
+	}
+}
+
+func TestTCPClientRecordsTraffic(t *testing.T) {
+	addr, closeServer := startEchoServer(t)
+	defer closeServer()
+
+	cfg := codec.DefaultPacketConfig()
+	rec := NewTrafficRecorder(0, cfg)
+	client := NewTCPClient(cfg)
+	client.SetRecorder(rec)
+
+	received := make(chan struct{}, 1)
+	client.OnReceive(func(conn Conn, pkt *codec.Packet) { received <- struct{}{} })
+
+	if err := client.Connect(addr); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer client.Disconnect()
+
+	if err := client.Send(encodeTestFrame(t, 1001, 1, "hello")); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for echo")
+	}
+
+	frames := rec.List(0, 0)
+	if len(frames) != 2 {
+		t.Fatalf("recorded %d frames, want 2", len(frames))
+	}
+	if frames[0].Direction != TrafficSent || frames[1].Direction != TrafficReceived {
+		t.Fatalf("directions = %s/%s, want sent/received", frames[0].Direction, frames[1].Direction)
+	}
+	if _, remote := rec.Endpoints(); remote == nil || remote.String() != addr {
+		t.Fatalf("remote endpoint = %v, want %s", remote, addr)
+	}
+}
+
+func TestTCPClientRecordsRawReceivedBytes(t *testing.T) {
+	fdCfg, err := codec.NewFieldDrivenConfig([]codec.FieldDef{
+		{Name: "len", Bytes: 2},
+		{Name: "cmd", Bytes: 2, IsRoute: true},
+		{Name: "flags", Bytes: 1},
+	})
+	if err != nil {
+		t.Fatalf("NewFieldDrivenConfig error: %v", err)
+	}
+	cfg := codec.PacketConfig{FieldDriven: fdCfg}
+
+	// 自定义字段 flags 不在 Packet 中, 解码后重新编码会写入 0
+	frame, err := codec.Encode(&codec.Packet{Route: 7, Data: []byte("hi")}, cfg)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	frame[4] = 0x5A
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(frame)
+		time.Sleep(time.Second)
+	}()
+
+	rec := NewTrafficRecorder(0, cfg)
+	client := NewTCPClient(cfg)
+	client.SetRecorder(rec)
+	received := make(chan *codec.Packet, 1)
+	client.OnReceive(func(conn Conn, pkt *codec.Packet) { received <- pkt })
+
+	if err := client.Connect(ln.Addr().String()); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer client.Disconnect()
+
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for frame")
+	}
+
+	frames := rec.List(0, 0)
+	if len(frames) != 1 {
+		t.Fatalf("recorded %d frames, want 1", len(frames))
+	}
+	if !bytes.Equal(frames[0].Raw, frame) {
+		t.Fatalf("recorded raw = %x, want %x", frames[0].Raw, frame)
+	}
+}
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"net/url"
@@ -19,13 +20,18 @@ type WSClient struct {
 	addr  string // 目标地址, 用于重连
 
 	packetCfg    codec.PacketConfig
+	session      connConfig // 当前连接的协议帧配置
 	reconnectCfg ReconnectConfig
 	reconnector  *Reconnector
+	recorder     *TrafficRecorder
+	faultCfg     FaultConfig
 
 	connectHandler    ConnectHandler
 	disconnectHandler DisconnectHandler
 	receiveHandler    ReceiveHandler
+	protocolHandler   ProtocolErrorHandler
 
+	sendMu sync.Mutex // 保证帧的编码顺序与写入顺序一致
 	sendCh chan []byte
 	done   chan struct{}
 }
@@ -40,9 +46,29 @@ func NewWSClient(cfg codec.PacketConfig) *WSClient {
 	}
 }
 
-// SetPacketConfig 动态更新协议帧配置
+// SetPacketConfig 动态更新协议帧配置, 已建立的连接从下一帧开始使用
 func (c *WSClient) SetPacketConfig(cfg codec.PacketConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.packetCfg = cfg
+	c.session.update(cfg)
+}
+
+// config 返回当前连接的协议帧配置
+func (c *WSClient) config() codec.PacketConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.session.cfg
+}
+
+// SetRecorder 设置流量记录器, 为 nil 时不记录
+func (c *WSClient) SetRecorder(rec *TrafficRecorder) {
+	c.recorder = rec
+}
+
+// SetFaultConfig 设置故障注入配置, 下次建立连接时生效
+func (c *WSClient) SetFaultConfig(cfg FaultConfig) {
+	c.faultCfg = cfg
 }
 
 // SetReconnectConfig 设置重连配置
@@ -61,7 +87,18 @@ func (c *WSClient) Connect(addr string) error {
 	c.mu.Unlock()
 
 	u := url.URL{Scheme: "ws", Host: addr}
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	dialer := *websocket.DefaultDialer
+	if faultCfg := c.faultCfg; faultCfg.Enabled() {
+		// 在 TCP 层注入故障, WebSocket 帧同样会被分片或损坏
+		dialer.NetDial = func(network, addr string) (net.Conn, error) {
+			conn, err := net.Dial(network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return NewFaultConn(conn, faultCfg), nil
+		}
+	}
+	conn, _, err := dialer.Dial(u.String(), nil)
 	if err != nil {
 		c.mu.Lock()
 		c.state = ConnStateDisconnected
@@ -69,16 +106,25 @@ func (c *WSClient) Connect(addr string) error {
 		return err
 	}
 
+	if limit := c.packetCfg.EffectiveMaxFrameSize(); limit > 0 {
+		conn.SetReadLimit(int64(limit))
+	}
+
 	c.mu.Lock()
 	c.conn = conn
 	c.addr = addr
 	c.state = ConnStateConnected
+	c.session = newConnConfig(c.packetCfg)
 	c.done = make(chan struct{})
 	c.drainSendCh()
 	c.mu.Unlock()
 
 	wsConn := &wsConnWrapper{conn: conn}
 
+	if c.recorder != nil {
+		c.recorder.SetEndpoints(wsConn.LocalAddr(), wsConn.RemoteAddr())
+	}
+
 	if h := c.connectHandler; h != nil {
 		h(wsConn)
 	}
@@ -134,6 +180,19 @@ func (c *WSClient) Send(data []byte) error {
 	}
 }
 
+// SendPacket 按连接当前的协议帧配置编码并发送数据包
+//
+// 流加密的密钥流跨帧连续, 编码与入队在同一把锁内完成, 保证帧的加密顺序与写入顺序一致
+func (c *WSClient) SendPacket(pkt *codec.Packet) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	data, err := codec.Encode(pkt, c.config())
+	if err != nil {
+		return fmt.Errorf("frame encode: %w", err)
+	}
+	return c.Send(data)
+}
+
 // State 获取当前连接状态
 func (c *WSClient) State() ConnState {
 	c.mu.RLock()
@@ -156,6 +215,11 @@ func (c *WSClient) OnReceive(handler ReceiveHandler) {
 	c.receiveHandler = handler
 }
 
+// OnProtocolError 注册协议错误回调
+func (c *WSClient) OnProtocolError(handler ProtocolErrorHandler) {
+	c.protocolHandler = handler
+}
+
 // readLoop 读 goroutine, 每条 Binary Message 是一个完整协议帧
 func (c *WSClient) readLoop(conn *wsConnWrapper) {
 	for {
@@ -165,8 +229,13 @@ func (c *WSClient) readLoop(conn *wsConnWrapper) {
 		default:
 		}
 
-		msgType, msg, err := c.conn.ReadMessage()
+		msgType, msg, err := conn.conn.ReadMessage()
 		if err != nil {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				if h := c.protocolHandler; h != nil {
+					h(conn, fmt.Errorf("%w: websocket message exceeds %d bytes", codec.ErrFrameTooLarge, c.config().EffectiveMaxFrameSize()))
+				}
+			}
 			c.handleDisconnect(conn, err)
 			return
 		}
@@ -175,22 +244,20 @@ func (c *WSClient) readLoop(conn *wsConnWrapper) {
 			continue
 		}
 
-		if c.packetCfg.IsPomelo() {
-			// Pomelo 模式: 直接透传原始字节
-			if h := c.receiveHandler; h != nil {
-				h(conn, msg)
-			}
-		} else {
-			pkt, err := codec.DecodeBytes(msg, c.packetCfg)
-			if err != nil {
-				continue
-			}
-			if h := c.receiveHandler; h != nil {
-				encoded, encErr := codec.Encode(pkt, c.packetCfg)
-				if encErr == nil {
-					h(conn, encoded)
-				}
+		if c.recorder != nil {
+			c.recorder.Record(TrafficReceived, msg)
+		}
+
+		pkt, err := codec.DecodeBytes(msg, c.config())
+		if err != nil {
+			// 每条消息独立成帧, 丢弃坏帧即可继续
+			if h := c.protocolHandler; h != nil {
+				h(conn, &codec.ResyncError{Err: err})
 			}
+			continue
+		}
+		if h := c.receiveHandler; h != nil {
+			h(conn, pkt)
 		}
 	}
 }
@@ -202,7 +269,11 @@ func (c *WSClient) writeLoop(conn *wsConnWrapper) {
 		case <-c.done:
 			return
 		case data := <-c.sendCh:
-			if err := c.conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+			// 写入前记录, 保证发送帧先于其响应出现在记录中
+			if c.recorder != nil {
+				c.recorder.Record(TrafficSent, data)
+			}
+			if err := conn.conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
 				c.handleDisconnect(conn, err)
 				return
 			}